@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 
 	"vanish/internal/helpers"
 	"vanish/internal/types"
@@ -13,9 +14,14 @@ import (
 
 // ParsedArgs holds the result of parsing CLI arguments
 type ParsedArgs struct {
-	Operation string
-	Filenames []string
-	NoConfirm bool
+	Operation      string
+	Filenames      []string
+	NoConfirm      bool
+	ForceProtected bool
+	IncludePinned  bool
+	Permanent      bool
+	Jobs           int  // Items deleted or restored at once, 0 uses cache.jobs
+	FailFast       bool // Stop at the first item that fails
 }
 
 // ParseArgs parses the command-line arguments and returns the operation, filenames, and flags
@@ -23,6 +29,61 @@ func ParseArgs(args []string, cfg types.Config) ParsedArgs {
 	var operation string
 	var filenames []string
 	var noConfirm bool
+	var forceProtected bool
+	var includePinned bool
+	var permanent bool
+	var jobs int
+	var failFast bool
+
+	// Subcommands are only recognised as the first argument so that
+	// `vx -- <name>` can still delete a file with the same name.
+	if len(args) > 0 {
+		switch args[0] {
+		case "completion":
+			if len(args) < 2 {
+				log.Fatal("Error: completion requires a shell (bash, zsh, fish)")
+			}
+			if err := ShowCompletion(args[1]); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			os.Exit(0)
+		case "pin":
+			finish(PinItems(args[1:], cfg), cfg)
+		case "unpin":
+			finish(UnpinItems(args[1:], cfg), cfg)
+		case "shred":
+			finish(ShredItems(args[1:], cfg), cfg)
+		case "purge":
+			finish(PurgeExpiredItems(args[1:], cfg), cfg)
+		case "offload":
+			finish(OffloadItems(args[1:], cfg), cfg)
+		case "diff":
+			if err := DiffItems(args[1:], cfg); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			os.Exit(0)
+		case "stats":
+			if err := StatsCommand(args[1:], cfg); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			os.Exit(0)
+		case "serve":
+			if err := Serve(args[1:], cfg); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			os.Exit(0)
+		case "service":
+			if err := ManageService(args[1:], cfg); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			os.Exit(0)
+		case "__complete":
+			if len(args) > 1 {
+				ShowCompletionCandidates(args[1], cfg)
+			}
+			os.Exit(0)
+		}
+	}
 
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
@@ -32,7 +93,13 @@ func ParseArgs(args []string, cfg types.Config) ParsedArgs {
 			os.Exit(0)
 		case "-t", "--themes":
 			displayer := &MainThemeDisplayer{}
-			ShowThemesWithTuiPreview(displayer)
+			if i+1 < len(args) {
+				if err := ShowThemePreview(displayer, args[i+1]); err != nil {
+					log.Fatalf("Error: %v", err)
+				}
+			} else {
+				ShowThemesWithTuiPreview(displayer)
+			}
 			os.Exit(0)
 		case "-p", "--path":
 			fmt.Println(helpers.ExpandPath(cfg.Cache.Directory))
@@ -41,15 +108,12 @@ func ParseArgs(args []string, cfg types.Config) ParsedArgs {
 			fmt.Println(helpers.GetConfigPath())
 			os.Exit(0)
 		case "-l", "--list":
-			if err := ShowList(cfg); err != nil {
-				log.Fatalf("Error: %v", err)
-			}
-			os.Exit(0)
+			finish(ShowList(cfg), cfg)
 		case "-v", "--version":
 			ShowVersion()
 			os.Exit(0)
 		case "-s", "--stats":
-			if err := ShowStats(cfg); err != nil {
+			if err := StatsCommand(args[i+1:], cfg); err != nil {
 				log.Fatalf("Error: %v", err)
 			}
 			os.Exit(0)
@@ -58,6 +122,25 @@ func ParseArgs(args []string, cfg types.Config) ParsedArgs {
 			filenames = []string{""}
 		case "-f", "--noconfirm":
 			noConfirm = true
+		case "--force-protected":
+			forceProtected = true
+		case "--include-pinned":
+			includePinned = true
+		case "--permanent":
+			permanent = true
+		case "-j", "--jobs":
+			jobs = parseJobs(args, i)
+			i++ // skip value
+		case "--fail-fast":
+			failFast = true
+		case "--":
+			// Everything after "--" is a file to delete, even if it
+			// looks like a flag or a subcommand
+			if operation == "" {
+				operation = "delete"
+				filenames = args[i+1:]
+				i = len(args)
+			}
 		case "-r", "--restore":
 			operation = "restore"
 			if i+1 < len(args) {
@@ -84,10 +167,30 @@ func ParseArgs(args []string, cfg types.Config) ParsedArgs {
 				log.Fatal("Error: --purge requires number of days")
 			}
 		default:
-			// If no operation is set yet, assume delete
+			// If no operation is set yet, assume delete. Option flags may
+			// still follow the filenames until a "--" is seen.
 			if operation == "" {
 				operation = "delete"
-				filenames = args[i:]
+				for j := i; j < len(args); j++ {
+					switch args[j] {
+					case "-f", "--noconfirm":
+						noConfirm = true
+					case "--force-protected":
+						forceProtected = true
+					case "--permanent":
+						permanent = true
+					case "-j", "--jobs":
+						jobs = parseJobs(args, j)
+						j++
+					case "--fail-fast":
+						failFast = true
+					case "--":
+						filenames = append(filenames, args[j+1:]...)
+						j = len(args)
+					default:
+						filenames = append(filenames, args[j])
+					}
+				}
 				i = len(args) // consume all
 			}
 		}
@@ -107,10 +210,37 @@ func ParseArgs(args []string, cfg types.Config) ParsedArgs {
 	}
 
 	return ParsedArgs{
-		Operation: operation,
-		Filenames: filenames,
-		NoConfirm: noConfirm,
+		Operation:      operation,
+		Filenames:      filenames,
+		NoConfirm:      noConfirm,
+		ForceProtected: forceProtected,
+		IncludePinned:  includePinned,
+		Permanent:      permanent,
+		Jobs:           jobs,
+		FailFast:       failFast,
+	}
+}
+
+// finish ends a subcommand that changes the cache: it writes the metrics
+// textfile once for the whole command, then exits or reports err.
+func finish(err error, cfg types.Config) {
+	helpers.FlushMetrics(cfg)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	os.Exit(0)
+}
+
+// parseJobs returns the value of the --jobs flag at args[i].
+func parseJobs(args []string, i int) int {
+	if i+1 >= len(args) {
+		log.Fatal("Error: --jobs requires a number")
+	}
+	jobs, err := strconv.Atoi(args[i+1])
+	if err != nil || jobs < 1 {
+		log.Fatalf("Error: invalid --jobs value %q, expected a number of at least 1", args[i+1])
 	}
+	return jobs
 }
 
 // FUTURE Case
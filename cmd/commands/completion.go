@@ -0,0 +1,360 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"vanish/internal/config"
+	"vanish/internal/types"
+	"vanish/pkg/vanish"
+)
+
+// completionFlags lists every flag offered by shell completion.
+var completionFlags = []string{
+	"-h", "--help",
+	"-t", "--themes",
+	"-p", "--path",
+	"-cp", "--config-path",
+	"-l", "--list",
+	"-v", "--version",
+	"-s", "--stats",
+	"-c", "--clear",
+	"--include-pinned",
+	"-f", "--noconfirm",
+	"--force-protected",
+	"--permanent",
+	"-j", "--jobs",
+	"--fail-fast",
+	"-r", "--restore",
+	"-i", "--info",
+	"-pr", "--purge",
+}
+
+// completionCommands lists the subcommands offered by shell completion.
+// The hidden __complete entry point is intentionally left out.
+var completionCommands = []string{"completion", "pin", "unpin", "shred", "offload", "diff", "purge", "stats", "service", "serve"}
+
+// completionShells lists the shells a completion script can be generated for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// ShowCompletion prints the completion script for the given shell.
+func ShowCompletion(shell string) error {
+	flags := strings.Join(completionFlags, " ")
+	commands := strings.Join(completionCommands, " ")
+	shells := strings.Join(completionShells, " ")
+
+	switch shell {
+	case "bash":
+		fmt.Printf(bashCompletion, flags, commands, shells)
+	case "zsh":
+		fmt.Printf(zshCompletion, flags, commands, shells)
+	case "fish":
+		fmt.Printf(fishCompletion, shells)
+	default:
+		return fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+// ShowCompletionCandidates prints dynamic completion candidates of the given
+// kind, one per line. It backs the hidden `vx __complete <kind>` command that
+// the generated shell scripts call. Errors are swallowed so that a broken
+// index never spills output into the user's shell.
+func ShowCompletionCandidates(kind string, cfg types.Config) {
+	for _, candidate := range completionCandidates(kind, cfg) {
+		fmt.Println(candidate)
+	}
+}
+
+func completionCandidates(kind string, cfg types.Config) []string {
+	switch kind {
+	case "items":
+		items, err := vanish.New(cfg).List(vanish.Filter{})
+		if err != nil {
+			return nil
+		}
+		seen := make(map[string]bool)
+		var candidates []string
+		for _, item := range items {
+			for _, candidate := range []string{filepath.Base(item.OriginalPath), item.ID} {
+				if candidate != "" && !seen[candidate] {
+					seen[candidate] = true
+					candidates = append(candidates, candidate)
+				}
+			}
+		}
+		sort.Strings(candidates)
+		return candidates
+	case "themes":
+		var names []string
+		for name := range config.GetDefaultThemes() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	case "shells":
+		return completionShells
+	}
+	return nil
+}
+
+const bashCompletion = `# bash completion for vx                                   -*- shell-script -*-
+
+_vx() {
+    local cur prev word i
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    local IFS=$'\n'
+
+    # pin, unpin, shred, offload and diff take cached item patterns
+    if [[ "${COMP_WORDS[1]}" == "pin" || "${COMP_WORDS[1]}" == "unpin" || "${COMP_WORDS[1]}" == "shred" || "${COMP_WORDS[1]}" == "offload" || "${COMP_WORDS[1]}" == "diff" ]] && [[ $COMP_CWORD -gt 1 ]]; then
+        case "$prev" in
+            --days|--until) return ;;
+        esac
+        if [[ "$cur" == -* && "${COMP_WORDS[1]}" == "pin" ]]; then
+            COMPREPLY=($(compgen -W "$(printf '%%s\n' --days --until)" -- "$cur"))
+            return
+        fi
+        if [[ "$cur" == -* && "${COMP_WORDS[1]}" == "shred" ]]; then
+            COMPREPLY=($(compgen -W "$(printf '%%s\n' -f --noconfirm)" -- "$cur"))
+            return
+        fi
+        COMPREPLY=($(compgen -W "$(vx __complete items 2>/dev/null)" -- "$cur"))
+        return
+    fi
+
+    case "${COMP_WORDS[1]}" in
+        purge)
+            [[ $COMP_CWORD -eq 2 ]] && COMPREPLY=($(compgen -W "--expired" -- "$cur"))
+            return
+            ;;
+        stats)
+            if [[ "$prev" == "--by" ]]; then
+                COMPREPLY=($(compgen -W "$(printf '%%s\n' ext dir age size)" -- "$cur"))
+            else
+                COMPREPLY=($(compgen -W "$(printf '%%s\n' --by --prometheus)" -- "$cur"))
+            fi
+            return
+            ;;
+        serve)
+            if [[ "$prev" == "--socket" ]]; then
+                COMPREPLY=($(compgen -f -- "$cur"))
+            else
+                COMPREPLY=($(compgen -W "--socket" -- "$cur"))
+            fi
+            return
+            ;;
+        service)
+            if [[ $COMP_CWORD -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "install uninstall" -- "$cur"))
+            elif [[ "${COMP_WORDS[2]}" == "install" ]]; then
+                COMPREPLY=($(compgen -W "--cron" -- "$cur"))
+            fi
+            return
+            ;;
+    esac
+
+    # --restore consumes every remaining argument as a pattern
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        if [[ "$word" == "-r" || "$word" == "--restore" ]]; then
+            COMPREPLY=($(compgen -W "$(vx __complete items 2>/dev/null)" -- "$cur"))
+            return
+        fi
+    done
+
+    case "$prev" in
+        -i|--info)
+            COMPREPLY=($(compgen -W "$(vx __complete items 2>/dev/null)" -- "$cur"))
+            return
+            ;;
+        -t|--themes)
+            COMPREPLY=($(compgen -W "$(vx __complete themes 2>/dev/null)" -- "$cur"))
+            return
+            ;;
+        -pr|--purge|-j|--jobs)
+            return
+            ;;
+        --by)
+            COMPREPLY=($(compgen -W "$(printf '%%s\n' ext dir age size)" -- "$cur"))
+            return
+            ;;
+        completion)
+            if [[ $COMP_CWORD -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "$(printf '%%s\n' %[3]s)" -- "$cur"))
+                return
+            fi
+            ;;
+    esac
+
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "$(printf '%%s\n' %[1]s)" -- "$cur"))
+        return
+    fi
+
+    if [[ $COMP_CWORD -eq 1 ]]; then
+        COMPREPLY=($(compgen -W "$(printf '%%s\n' %[2]s)" -- "$cur"))
+    fi
+    COMPREPLY+=($(compgen -f -- "$cur"))
+}
+
+complete -o filenames -F _vx vx
+`
+
+const zshCompletion = `#compdef vx
+
+_vx() {
+    local -a flags commands
+    flags=(%[1]s)
+    commands=(%[2]s)
+
+    # pin, unpin, shred, offload and diff take cached item patterns
+    if [[ "${words[2]}" == (pin|unpin|shred|offload|diff) ]] && (( CURRENT > 2 )); then
+        case "${words[CURRENT-1]}" in
+            --days|--until) return ;;
+        esac
+        if [[ "${words[CURRENT]}" == -* && "${words[2]}" == pin ]]; then
+            compadd -- --days --until
+            return
+        fi
+        if [[ "${words[CURRENT]}" == -* && "${words[2]}" == shred ]]; then
+            compadd -- -f --noconfirm
+            return
+        fi
+        compadd -- ${(f)"$(vx __complete items 2>/dev/null)"}
+        return
+    fi
+
+    case "${words[2]}" in
+        purge)
+            (( CURRENT == 3 )) && compadd -- --expired
+            return
+            ;;
+        stats)
+            if [[ "${words[CURRENT-1]}" == --by ]]; then
+                compadd -- ext dir age size
+            else
+                compadd -- --by --prometheus
+            fi
+            return
+            ;;
+        serve)
+            if [[ "${words[CURRENT-1]}" == --socket ]]; then
+                _files
+            else
+                compadd -- --socket
+            fi
+            return
+            ;;
+        service)
+            if (( CURRENT == 3 )); then
+                compadd -- install uninstall
+            elif [[ "${words[3]}" == install ]]; then
+                compadd -- --cron
+            fi
+            return
+            ;;
+    esac
+
+    # --restore consumes every remaining argument as a pattern
+    if (( ${words[(I)-r|--restore]} > 0 && ${words[(I)-r|--restore]} < CURRENT )); then
+        compadd -- ${(f)"$(vx __complete items 2>/dev/null)"}
+        return
+    fi
+
+    case "${words[CURRENT-1]}" in
+        -i|--info)
+            compadd -- ${(f)"$(vx __complete items 2>/dev/null)"}
+            return
+            ;;
+        -t|--themes)
+            compadd -- ${(f)"$(vx __complete themes 2>/dev/null)"}
+            return
+            ;;
+        -pr|--purge|-j|--jobs)
+            return
+            ;;
+        --by)
+            compadd -- ext dir age size
+            return
+            ;;
+        completion)
+            if (( CURRENT == 3 )); then
+                compadd -- %[3]s
+                return
+            fi
+            ;;
+    esac
+
+    if [[ "${words[CURRENT]}" == -* ]]; then
+        compadd -- $flags
+        return
+    fi
+
+    if (( CURRENT == 2 )); then
+        compadd -- $commands
+    fi
+    _files
+}
+
+compdef _vx vx
+`
+
+const fishCompletion = `# fish completion for vx
+
+function __vx_restoring
+    set -l tokens (commandline -opc)
+    contains -- -r $tokens; or contains -- --restore $tokens
+end
+
+complete -c vx -f
+
+# Subcommands
+complete -c vx -n '__fish_use_subcommand' -a completion -d 'Generate shell completion script'
+complete -c vx -n '__fish_seen_subcommand_from completion' -a '%[1]s'
+complete -c vx -n '__fish_use_subcommand' -a pin -d 'Keep cached items past expiry'
+complete -c vx -n '__fish_use_subcommand' -a unpin -d 'Remove the pin from cached items'
+complete -c vx -n '__fish_use_subcommand' -a shred -d 'Overwrite and destroy cached items'
+complete -c vx -n '__fish_use_subcommand' -a offload -d 'Upload cached items to the remote tier'
+complete -c vx -n '__fish_use_subcommand' -a diff -d 'Compare cached items with what is on disk now'
+complete -c vx -n '__fish_seen_subcommand_from pin unpin shred offload diff' -xa '(vx __complete items 2>/dev/null)'
+complete -c vx -n '__fish_use_subcommand' -a purge -d 'Purge expired items without the TUI'
+complete -c vx -n '__fish_seen_subcommand_from purge' -l expired -d 'Purge items past their expiry'
+complete -c vx -n '__fish_use_subcommand' -a stats -d 'Show cache statistics or a breakdown'
+complete -c vx -n '__fish_seen_subcommand_from stats; or __fish_contains_opt -s s stats' -l by -d 'Break the cache down' -xa 'ext dir age size'
+complete -c vx -n '__fish_seen_subcommand_from stats; or __fish_contains_opt -s s stats' -l prometheus -d 'Print Prometheus metrics'
+complete -c vx -n '__fish_use_subcommand' -a service -d 'Schedule a daily purge'
+complete -c vx -n '__fish_seen_subcommand_from service; and not __fish_seen_subcommand_from install uninstall' -a 'install uninstall'
+complete -c vx -n '__fish_seen_subcommand_from install' -l cron -d 'Print a crontab line instead'
+complete -c vx -n '__fish_use_subcommand' -a serve -d 'Serve a JSON API on a Unix socket'
+complete -c vx -n '__fish_seen_subcommand_from serve' -l socket -d 'Socket path' -rF
+complete -c vx -n '__fish_seen_subcommand_from pin' -l days -d 'Pin for N days' -x
+complete -c vx -n '__fish_seen_subcommand_from pin' -l until -d 'Pin until YYYY-MM-DD' -x
+
+# Flags
+complete -c vx -s h -l help -d 'Show help message'
+complete -c vx -s t -l themes -d 'Preview themes' -xa '(vx __complete themes 2>/dev/null)'
+complete -c vx -s p -l path -d 'Print cache directory path'
+complete -c vx -o cp -l config-path -d 'Print config file path'
+complete -c vx -s l -l list -d 'Browse cached files'
+complete -c vx -s v -l version -d 'Show version information'
+complete -c vx -s s -l stats -d 'Show cache statistics'
+complete -c vx -s c -l clear -d 'Clear all cached files'
+complete -c vx -l include-pinned -d 'Let --clear remove pinned items'
+complete -c vx -s f -l noconfirm -d 'Skip confirmation prompts'
+complete -c vx -l force-protected -d 'Allow deleting protected paths'
+complete -c vx -l permanent -d 'Delete without caching (unrecoverable)'
+complete -c vx -s j -l jobs -d 'Items deleted or restored at once' -x
+complete -c vx -l fail-fast -d 'Stop at the first item that fails'
+complete -c vx -s r -l restore -d 'Restore files matching patterns' -xa '(vx __complete items 2>/dev/null)'
+complete -c vx -s i -l info -d 'Show info about cached items' -xa '(vx __complete items 2>/dev/null)'
+complete -c vx -o pr -l purge -d 'Delete files older than N days' -x
+
+# Every pattern after --restore is a cached item
+complete -c vx -n '__vx_restoring' -xa '(vx __complete items 2>/dev/null)'
+
+# Anything else is a file to delete
+complete -c vx -n 'not __vx_restoring; and not __fish_seen_subcommand_from completion pin unpin shred offload diff purge stats service serve' -F
+`
@@ -0,0 +1,69 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+	"vanish/pkg/vanish"
+)
+
+// DiffItems compares the cached items matching the patterns with what is
+// now at their original paths, like d in the cache browser. It backs
+// `vx diff <pattern>...`.
+func DiffItems(patterns []string, config types.Config) error {
+	if len(patterns) == 0 {
+		return fmt.Errorf("diff requires at least one pattern")
+	}
+	items, err := vanish.New(config).List(vanish.Filter{Patterns: patterns})
+	if err != nil {
+		return fmt.Errorf("error loading index: %v", err)
+	}
+	styles := helpers.CreateThemeStyles(config)
+	if len(items) == 0 {
+		fmt.Println(styles.Warning.Render("No matching items found in cache"))
+		return nil
+	}
+	fmt.Print(renderDiffs(items, config, styles))
+	return nil
+}
+
+// renderDiffs shows, for each item, how its cached payload differs from
+// what is at its original path.
+func renderDiffs(items []types.DeletedItem, config types.Config, styles types.ThemeStyles) string {
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color(config.UI.Colors.Muted))
+	removed := lipgloss.NewStyle().Foreground(lipgloss.Color(config.UI.Colors.Error))
+	added := lipgloss.NewStyle().Foreground(lipgloss.Color(config.UI.Colors.Success))
+	changed := lipgloss.NewStyle().Foreground(lipgloss.Color(config.UI.Colors.Warning))
+
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString(styles.Filename.Render(item.OriginalPath) + "\n")
+		diff, err := helpers.DiffItem(item, config)
+		if err != nil {
+			b.WriteString(styles.StatusBad.Render("Cannot diff: "+err.Error()) + "\n\n")
+			continue
+		}
+		b.WriteString(styles.Info.Render(diff.Summary) + "\n")
+		if len(diff.Lines) > 0 {
+			b.WriteString(removed.Render("--- cached") + "\n" + added.Render("+++ on disk") + "\n")
+		}
+		for _, line := range diff.Lines {
+			switch {
+			case strings.HasPrefix(line, "@@"):
+				line = muted.Render(line)
+			case strings.HasPrefix(line, "-"):
+				line = removed.Render(line)
+			case strings.HasPrefix(line, "+"):
+				line = added.Render(line)
+			case strings.HasPrefix(line, "~"):
+				line = changed.Render(line)
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,352 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// treeNode is a directory of the virtual tree of original locations drawn
+// by the grouped view. Its totals cover every item below it.
+type treeNode struct {
+	path     string // Original directory
+	name     string // Shown name, several path elements once merged
+	parent   *treeNode
+	children []*treeNode
+	items    []types.DeletedItem // Items deleted from this directory
+	count    int
+	size     int64
+	newest   time.Time // Latest deletion below
+	soonest  time.Time // Earliest expiry below, zero when everything is pinned
+}
+
+// treeRow is one line of the grouped view: a directory, or an item when
+// node is nil.
+type treeRow struct {
+	node   *treeNode
+	item   types.DeletedItem
+	parent *treeNode // Directory holding the row, nil at the top
+	depth  int
+}
+
+func (r treeRow) FilterValue() string {
+	if r.node != nil {
+		return r.node.path
+	}
+	return r.item.OriginalPath
+}
+
+// buildTree groups items by the directory they were deleted from. Chains
+// of directories holding nothing but one subdirectory are merged into one
+// node, so the tree starts where the items branch out.
+func buildTree(items []types.DeletedItem, config types.Config) *treeNode {
+	root := &treeNode{path: "/"}
+	nodes := map[string]*treeNode{"/": root}
+	var dirNode func(dir string) *treeNode
+	dirNode = func(dir string) *treeNode {
+		if node, ok := nodes[dir]; ok {
+			return node
+		}
+		parent := root
+		if up := filepath.Dir(dir); up != dir {
+			parent = dirNode(up)
+		}
+		node := &treeNode{path: dir, name: filepath.Base(dir), parent: parent}
+		parent.children = append(parent.children, node)
+		nodes[dir] = node
+		return node
+	}
+	for _, item := range items {
+		node := dirNode(filepath.Dir(item.OriginalPath))
+		node.items = append(node.items, item)
+	}
+
+	top := root
+	for len(top.items) == 0 && len(top.children) == 1 {
+		top = top.children[0]
+	}
+	top.name, top.parent = tildePath(top.path), nil
+	top.merge()
+	top.total(config)
+	return top
+}
+
+// merge folds the children of n that hold a single subdirectory and no
+// items into that subdirectory.
+func (n *treeNode) merge() {
+	for i, child := range n.children {
+		for len(child.items) == 0 && len(child.children) == 1 {
+			only := child.children[0]
+			only.name = child.name + "/" + only.name
+			child = only
+		}
+		child.parent = n
+		n.children[i] = child
+		child.merge()
+	}
+}
+
+// total adds up the items below n.
+func (n *treeNode) total(config types.Config) {
+	add := func(count int, size int64, newest, soonest time.Time) {
+		n.count += count
+		n.size += size
+		if newest.After(n.newest) {
+			n.newest = newest
+		}
+		if !soonest.IsZero() && (n.soonest.IsZero() || soonest.Before(n.soonest)) {
+			n.soonest = soonest
+		}
+	}
+	for _, child := range n.children {
+		child.total(config)
+		add(child.count, child.size, child.newest, child.soonest)
+	}
+	for _, item := range n.items {
+		var expiry time.Time
+		if !item.IsPinned() {
+			expiry = helpers.ExpiryDate(item, config)
+		}
+		add(1, item.Size, item.DeleteDate, expiry)
+	}
+}
+
+// sortTree orders the subdirectories of n by the list's sort key. Items
+// keep the order of the list they were taken from.
+func (n *treeNode) sortTree(sortBy int, reversed bool) {
+	less := func(a, b *treeNode) bool {
+		switch sortBy {
+		case sortBySize:
+			return a.size > b.size
+		case sortByPath:
+			return a.name < b.name
+		case sortByExpiry:
+			// Directories of pinned items never expire and go last
+			if a.soonest.IsZero() != b.soonest.IsZero() {
+				return b.soonest.IsZero()
+			}
+			return a.soonest.Before(b.soonest)
+		}
+		return a.newest.After(b.newest)
+	}
+	sort.SliceStable(n.children, func(i, j int) bool {
+		if reversed {
+			return less(n.children[j], n.children[i])
+		}
+		return less(n.children[i], n.children[j])
+	})
+	for _, child := range n.children {
+		child.sortTree(sortBy, reversed)
+	}
+}
+
+// flatten lists the rows of n and of its expanded subdirectories, or of
+// all of them when all is set.
+func (n *treeNode) flatten(expanded map[string]bool, all bool, depth int, rows []list.Item) []list.Item {
+	rows = append(rows, treeRow{node: n, parent: n.parent, depth: depth})
+	if !all && !expanded[n.path] {
+		return rows
+	}
+	for _, child := range n.children {
+		rows = child.flatten(expanded, all, depth+1, rows)
+	}
+	for _, item := range n.items {
+		rows = append(rows, treeRow{item: item, parent: n, depth: depth + 1})
+	}
+	return rows
+}
+
+// allItems returns every item below n.
+func (n *treeNode) allItems() []types.DeletedItem {
+	items := append([]types.DeletedItem(nil), n.items...)
+	for _, child := range n.children {
+		items = append(items, child.allItems()...)
+	}
+	return items
+}
+
+// tildePath shortens paths in the home directory to ~/...
+func tildePath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" || home == "/" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(path, home+"/"); ok {
+		return "~/" + rest
+	}
+	return path
+}
+
+// setRows hands the items to the list, as a flat table or as the grouped
+// tree. While a search is in effect the whole tree is searched.
+func (m *listModel) setRows() tea.Cmd {
+	if !m.tree || len(m.items) == 0 {
+		entries := make([]list.Item, len(m.items))
+		for i, item := range m.items {
+			entries[i] = listEntry{item: item}
+		}
+		return m.list.SetItems(entries)
+	}
+
+	root := buildTree(m.items, m.config)
+	root.sortTree(m.sortBy, m.reversed)
+	if m.expanded == nil {
+		m.expanded = map[string]bool{root.path: true}
+	}
+	return m.list.SetItems(root.flatten(m.expanded, m.list.FilterState() != list.Unfiltered, 0, nil))
+}
+
+// handleTreeKey expands and collapses directories of the grouped view, and
+// reports whether msg did either.
+func (m *listModel) handleTreeKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	row, ok := m.list.SelectedItem().(treeRow)
+	if !ok {
+		return nil, false
+	}
+	switch {
+	case row.node != nil && msg.String() == "enter":
+		if m.expanded[row.node.path] {
+			delete(m.expanded, row.node.path)
+		} else {
+			m.expanded[row.node.path] = true
+		}
+		return m.selectDir(row.node.path), true
+
+	case key.Matches(msg, m.keys.expand):
+		if row.node != nil {
+			m.expanded[row.node.path] = true
+			return m.selectDir(row.node.path), true
+		}
+		return nil, true
+
+	case key.Matches(msg, m.keys.collapse):
+		if row.node != nil && m.expanded[row.node.path] {
+			delete(m.expanded, row.node.path)
+			return m.selectDir(row.node.path), true
+		}
+		if row.parent != nil {
+			return m.selectDir(row.parent.path), true
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+// selectDir rebuilds the rows and puts the cursor on a directory.
+func (m *listModel) selectDir(path string) tea.Cmd {
+	cmd := m.setRows()
+	for i, v := range m.list.VisibleItems() {
+		if row, ok := v.(treeRow); ok && row.node != nil && row.node.path == path {
+			m.list.Select(i)
+			break
+		}
+	}
+	return cmd
+}
+
+// rowItems returns the items a row stands for: the item itself, or every
+// item below a directory.
+func rowItems(v list.Item) []types.DeletedItem {
+	switch row := v.(type) {
+	case listEntry:
+		return []types.DeletedItem{row.item}
+	case treeRow:
+		if row.node != nil {
+			return row.node.allItems()
+		}
+		return []types.DeletedItem{row.item}
+	}
+	return nil
+}
+
+// formatTreeRow renders a row of the grouped view: a directory with its
+// totals, or an item under it.
+func (m *listModel) formatTreeRow(row treeRow, isCursor bool, width int) string {
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color(m.config.UI.Colors.Text))
+	if isCursor {
+		style = style.Background(lipgloss.Color(m.config.UI.Colors.Highlight)).Bold(true)
+	}
+	indent := strings.Repeat("  ", row.depth)
+
+	if row.node == nil {
+		mark := " "
+		if m.selected[row.item.ID] {
+			mark = "●"
+		}
+		status, _, color := m.itemStatus(row.item)
+		name := filepath.Base(row.item.OriginalPath)
+		if row.item.IsDirectory {
+			name += "/"
+		}
+		prefix := fmt.Sprintf("%s %-8s | ", mark, helpers.FormatBytes(row.item.Size))
+		return style.Render(prefix) + style.Foreground(color).Render(fmt.Sprintf("%-9s", status)) +
+			style.Render(truncate(" | "+indent+"  "+name, width-lipgloss.Width(prefix)-9))
+	}
+
+	items := row.node.allItems()
+	selected := 0
+	for _, item := range items {
+		if m.selected[item.ID] {
+			selected++
+		}
+	}
+	mark := " "
+	switch {
+	case selected == len(items):
+		mark = "●"
+	case selected > 0:
+		mark = "◐"
+	}
+	arrow := "▸ "
+	if m.expanded[row.node.path] || m.list.FilterState() != list.Unfiltered {
+		arrow = "▾ "
+	}
+	count := fmt.Sprintf("%d items", row.node.count)
+	if row.node.count == 1 {
+		count = "1 item"
+	}
+
+	muted := style.Foreground(lipgloss.Color(m.config.UI.Colors.Muted))
+	dir := style.Foreground(lipgloss.Color(m.config.UI.Colors.Primary)).Bold(true)
+	prefix := fmt.Sprintf("%s %-8s | ", mark, helpers.FormatBytes(row.node.size))
+	name := truncateLeft(row.node.name+"/", width-lipgloss.Width(prefix)-len(indent)-16)
+	return style.Render(prefix) + muted.Render(fmt.Sprintf("%-9s", count)) +
+		style.Render(" | "+indent+arrow) + dir.Render(name)
+}
+
+// dirPreview sums up a directory of the grouped view in the preview pane:
+// the largest items below it.
+func (m *listModel) dirPreview(node *treeNode, width, height int) string {
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color(m.config.UI.Colors.Muted))
+	items := node.allItems()
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Size > items[j].Size })
+
+	lines := []string{
+		muted.Render(truncate(fmt.Sprintf("%d item(s), %s under %s", node.count, helpers.FormatBytes(node.size), tildePath(node.path)), width)),
+		"",
+	}
+	for _, item := range items {
+		if len(lines) >= height {
+			break
+		}
+		rel, err := filepath.Rel(node.path, item.OriginalPath)
+		if err != nil {
+			rel = item.OriginalPath
+		}
+		lines = append(lines, fmt.Sprintf("%-8s %s", helpers.FormatBytes(item.Size), truncateLeft(rel, width-9)))
+	}
+	return strings.Join(lines, "\n")
+}
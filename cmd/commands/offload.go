@@ -0,0 +1,28 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+	"vanish/pkg/vanish"
+)
+
+// OffloadItems uploads cached items to the remote tier and removes their
+// local copies. Without patterns it uploads the items due by
+// remote.after_days or remote.min_size; with patterns it uploads every
+// local item matching them. It backs `vx offload [<pattern>...]`.
+func OffloadItems(args []string, config types.Config) error {
+	items, err := vanish.New(config).Offload(context.Background(), args)
+	var size int64
+	for _, item := range items {
+		size += item.Size
+		fmt.Printf("offloaded %s -> %s\n", item.OriginalPath, item.CachePath)
+	}
+	if err != nil {
+		return fmt.Errorf("error offloading items: %v", err)
+	}
+	fmt.Printf("%d item(s) offloaded, %s freed locally\n", len(items), helpers.FormatBytes(size))
+	return nil
+}
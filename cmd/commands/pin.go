@@ -0,0 +1,97 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+)
+
+// PinItems pins every cached item matching the given arguments so that it
+// survives cleanup, purge and quota eviction. The arguments are patterns,
+// optionally mixed with --days <n> or --until <YYYY-MM-DD> to let the pin
+// lapse at a given date.
+func PinItems(args []string, config types.Config) error {
+	var patterns []string
+	var until time.Time
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--days":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--days requires a number of days")
+			}
+			days, err := strconv.Atoi(args[i+1])
+			if err != nil || days <= 0 {
+				return fmt.Errorf("invalid days value: %s", args[i+1])
+			}
+			until = time.Now().Add(time.Duration(days) * 24 * time.Hour)
+			i++
+		case "--until":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--until requires a date (YYYY-MM-DD)")
+			}
+			date, err := time.ParseInLocation("2006-01-02", args[i+1], time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", args[i+1])
+			}
+			until = date
+			i++
+		default:
+			patterns = append(patterns, args[i])
+		}
+	}
+
+	if len(patterns) == 0 {
+		return fmt.Errorf("pin requires at least one pattern")
+	}
+
+	items, err := helpers.SetPinned(patterns, true, until, config)
+	if err != nil {
+		return fmt.Errorf("error updating index: %v", err)
+	}
+	printPinResult(items, "Pinned", config)
+	return nil
+}
+
+// UnpinItems removes the pin from every cached item matching the patterns.
+// The items then expire according to the normal retention rules.
+func UnpinItems(patterns []string, config types.Config) error {
+	if len(patterns) == 0 {
+		return fmt.Errorf("unpin requires at least one pattern")
+	}
+
+	items, err := helpers.SetPinned(patterns, false, time.Time{}, config)
+	if err != nil {
+		return fmt.Errorf("error updating index: %v", err)
+	}
+	printPinResult(items, "Unpinned", config)
+	return nil
+}
+
+func printPinResult(items []types.DeletedItem, action string, config types.Config) {
+	styles := helpers.CreateThemeStyles(config)
+	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(config.UI.Colors.Muted))
+
+	if len(items) == 0 {
+		fmt.Println(styles.Warning.Render("No matching items found in cache"))
+		return
+	}
+
+	for _, item := range items {
+		detail := ""
+		if item.Pinned {
+			detail = "(until unpinned)"
+			if !item.PinnedUntil.IsZero() {
+				detail = fmt.Sprintf("(until %s)", item.PinnedUntil.Format("2006-01-02 15:04"))
+			}
+		}
+		fmt.Printf("%s %s %s\n",
+			styles.StatusGood.Render(action),
+			styles.Filename.Render(item.OriginalPath),
+			mutedStyle.Render(detail))
+	}
+}
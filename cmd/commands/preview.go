@@ -0,0 +1,205 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// previewModel renders what a cached item holds in a box of a given size,
+// shared by the list's preview pane and --info.
+type previewModel struct {
+	config types.Config
+	styles types.ThemeStyles
+}
+
+// render lays out preview in width by height cells, header included.
+func (p previewModel) render(preview helpers.Preview, err error, width, height int) string {
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color(p.config.UI.Colors.Muted))
+	header := muted.Render(truncate("Preview • "+preview.Source, width))
+	if err != nil {
+		return lipgloss.JoinVertical(lipgloss.Left, header, "",
+			muted.Render(lipgloss.NewStyle().Width(width).Render("No preview: "+err.Error())))
+	}
+
+	height = max(1, height-1)
+	var lines []string
+	switch preview.Kind {
+	case helpers.PreviewText:
+		lines = p.textLines(preview, height)
+	case helpers.PreviewTree:
+		lines = p.treeLines(preview, height)
+	case helpers.PreviewSymlink:
+		lines = p.symlinkLines(preview)
+	case helpers.PreviewBinary:
+		lines = p.binaryLines(preview, width, height)
+	default:
+		lines = []string{muted.Render("Special file, nothing to show")}
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+
+	body := lipgloss.NewStyle().MaxWidth(width).Render(strings.Join(lines, "\n"))
+	return lipgloss.JoinVertical(lipgloss.Left, header, body)
+}
+
+// textLines returns the first lines of a text file, highlighted when the
+// configuration asks for it and the file name tells the language.
+func (p previewModel) textLines(preview helpers.Preview, height int) []string {
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color(p.config.UI.Colors.Muted))
+	if len(preview.Head) == 0 {
+		return []string{muted.Render("(empty file)")}
+	}
+
+	text := strings.ReplaceAll(string(preview.Head), "\t", "    ")
+	if preview.Size > int64(len(preview.Head)) {
+		// The last line was cut by the read limit
+		if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+			text = text[:i]
+		}
+	}
+
+	lines := p.highlight(preview.Name, text)
+	if lines == nil {
+		lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	}
+	if preview.Size > int64(len(preview.Head)) && len(lines) < height {
+		lines = append(lines, muted.Render(fmt.Sprintf("… %s more", helpers.FormatBytes(preview.Size-int64(len(preview.Head))))))
+	}
+	return lines
+}
+
+// highlight colours text line by line, or returns nil when highlighting is
+// off or no lexer matches name.
+func (p previewModel) highlight(name, text string) []string {
+	if !p.config.Preview.Highlight {
+		return nil
+	}
+	lexer := lexers.Match(name)
+	if lexer == nil {
+		return nil
+	}
+	tokens, err := chroma.Coalesce(lexer).Tokenise(nil, text)
+	if err != nil {
+		return nil
+	}
+	formatter := formatters.Get("terminal256")
+	style := styles.Get(p.config.Preview.Style)
+
+	var lines []string
+	for _, line := range chroma.SplitTokensIntoLines(tokens.Tokens()) {
+		// Each line is formatted on its own so colours never span lines
+		for i := range line {
+			line[i].Value = strings.TrimSuffix(line[i].Value, "\n")
+		}
+		var b strings.Builder
+		if err := formatter.Format(&b, style, chroma.Literator(line...)); err != nil {
+			return nil
+		}
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+// treeLines draws a directory with the total size of every entry, as far
+// as height allows.
+func (p previewModel) treeLines(preview helpers.Preview, height int) []string {
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color(p.config.UI.Colors.Muted))
+	dir := lipgloss.NewStyle().Foreground(lipgloss.Color(p.config.UI.Colors.Primary)).Bold(true)
+
+	root := preview.Tree
+	lines := []string{dir.Render(root.Name+"/") + " " + muted.Render(treeTotals(root))}
+	var walk func(node *helpers.PreviewNode, indent string)
+	walk = func(node *helpers.PreviewNode, indent string) {
+		for i, child := range node.Children {
+			if len(lines) >= height {
+				return
+			}
+			branch, next := "├── ", "│   "
+			if i == len(node.Children)-1 {
+				branch, next = "└── ", "    "
+			}
+			if len(lines) == height-1 && i < len(node.Children)-1 {
+				lines = append(lines, muted.Render(fmt.Sprintf("%s└── … %d more", indent, len(node.Children)-i)))
+				return
+			}
+
+			name := child.Name
+			switch {
+			case child.Mode.IsDir():
+				name = dir.Render(name+"/") + " " + muted.Render(treeTotals(child))
+			case child.Link != "":
+				name += muted.Render(" → " + child.Link)
+			default:
+				name += " " + muted.Render(helpers.FormatBytes(child.Size))
+			}
+			lines = append(lines, muted.Render(indent+branch)+name)
+			if child.Mode.IsDir() {
+				walk(child, indent+next)
+			}
+		}
+	}
+	walk(root, "")
+
+	if preview.Truncated && len(lines) < height {
+		lines = append(lines, muted.Render("(too many entries, totals cover the first ones)"))
+	}
+	return lines
+}
+
+// treeTotals sums up a directory of a preview tree.
+func treeTotals(node *helpers.PreviewNode) string {
+	files := "files"
+	if node.Files == 1 {
+		files = "file"
+	}
+	return fmt.Sprintf("%s, %d %s", helpers.FormatBytes(node.Size), node.Files, files)
+}
+
+// symlinkLines shows where a symlink points and whether restoring it would
+// bring back a working link.
+func (p previewModel) symlinkLines(preview helpers.Preview) []string {
+	lines := []string{"→ " + p.styles.Filename.Render(preview.Link)}
+	if preview.Resolves {
+		return append(lines, p.styles.StatusGood.Render("✓ Resolves to "+preview.Resolved))
+	}
+	return append(lines, p.styles.StatusBad.Render("✗ Dangling, nothing is at "+preview.Resolved))
+}
+
+// binaryLines names the type of a binary file and dumps its first bytes,
+// as many per row as width allows.
+func (p previewModel) binaryLines(preview helpers.Preview, width, height int) []string {
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color(p.config.UI.Colors.Muted))
+	lines := []string{
+		fmt.Sprintf("%s, %s", preview.FileType, helpers.FormatBytes(preview.Size)),
+		"",
+	}
+
+	// Each byte takes three cells in hex and one in text, after an
+	// 8-digit offset and separators
+	perRow := min(16, max(4, (width-13)/4/4*4))
+	head := preview.Head
+	for offset := 0; offset < len(head) && len(lines) < height; offset += perRow {
+		row := head[offset:min(offset+perRow, len(head))]
+		var hex, text strings.Builder
+		for _, c := range row {
+			fmt.Fprintf(&hex, "%02x ", c)
+			if c >= 0x20 && c < 0x7f {
+				text.WriteByte(c)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s  %-*s %s",
+			muted.Render(fmt.Sprintf("%08x", offset)), perRow*3, hex.String(), muted.Render("|"+text.String()+"|")))
+	}
+	return lines
+}
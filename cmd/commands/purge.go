@@ -0,0 +1,48 @@
+package command
+
+import (
+	"fmt"
+
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+	"vanish/pkg/vanish"
+)
+
+// PurgeExpiredItems offloads items due for the remote tier, then removes
+// every cached item past its expiry date without starting the TUI. It
+// backs `vx purge --expired`, which is meant to run from a systemd timer or
+// cron, so it prints plain lines and never prompts.
+func PurgeExpiredItems(args []string, config types.Config) error {
+	expired := false
+	for _, arg := range args {
+		switch arg {
+		case "--expired":
+			expired = true
+		default:
+			return fmt.Errorf("unknown purge argument %q (use --purge <days> to purge by age)", arg)
+		}
+	}
+	if !expired {
+		return fmt.Errorf("purge requires --expired (use --purge <days> to purge by age)")
+	}
+
+	result, err := vanish.New(config).Purge(vanish.PurgePolicy{Offload: true, Expired: true})
+	if err != nil {
+		return fmt.Errorf("error purging expired items: %v", err)
+	}
+	for _, item := range result.Offloaded {
+		fmt.Printf("offloaded %s\n", item.OriginalPath)
+	}
+	if result.OffloadErr != nil {
+		fmt.Printf("offload failed: %v\n", result.OffloadErr)
+	}
+	items := result.Expired
+
+	var size int64
+	for _, item := range items {
+		size += item.Size
+		fmt.Printf("purged %s\n", item.OriginalPath)
+	}
+	fmt.Printf("%d expired item(s) purged, %s freed\n", len(items), helpers.FormatBytes(size))
+	return nil
+}
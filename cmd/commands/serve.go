@@ -0,0 +1,36 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"vanish/internal/server"
+	"vanish/internal/types"
+)
+
+// Serve runs the JSON API on a Unix socket until interrupted. The socket
+// defaults to $XDG_RUNTIME_DIR/vanish.sock and can be set with --socket.
+func Serve(args []string, config types.Config) error {
+	socketPath := server.DefaultSocketPath()
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--socket":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--socket requires a path")
+			}
+			socketPath = args[i+1]
+			i++
+		default:
+			return fmt.Errorf("unknown serve argument %q", args[i])
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("vanish API listening on %s\n", socketPath)
+	return server.New(config).ListenAndServe(ctx, socketPath)
+}
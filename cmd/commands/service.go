@@ -0,0 +1,159 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+)
+
+// serviceName is the base name of the generated systemd units.
+const serviceName = "vanish-purge"
+
+const serviceUnit = `[Unit]
+Description=Purge expired items from the vanish cache
+
+[Service]
+Type=oneshot
+ExecStart=%s purge --expired
+`
+
+const timerUnit = `[Unit]
+Description=Purge expired items from the vanish cache daily
+
+[Timer]
+OnCalendar=daily
+Persistent=true
+RandomizedDelaySec=1h
+
+[Install]
+WantedBy=timers.target
+`
+
+// ManageService handles `vx service install [--cron]` and
+// `vx service uninstall`, which schedule `vx purge --expired` to run daily.
+func ManageService(args []string, config types.Config) error {
+	if len(args) == 0 {
+		return fmt.Errorf("service requires install or uninstall")
+	}
+
+	cron := false
+	for _, arg := range args[1:] {
+		switch arg {
+		case "--cron":
+			cron = true
+		default:
+			return fmt.Errorf("unknown service argument %q", arg)
+		}
+	}
+
+	switch args[0] {
+	case "install":
+		if cron {
+			return printCronLine()
+		}
+		return installSystemdUnits(config)
+	case "uninstall":
+		return uninstallSystemdUnits(config)
+	}
+	return fmt.Errorf("unknown service action %q (options: install, uninstall)", args[0])
+}
+
+// systemdUserDir returns the directory holding systemd user units.
+func systemdUserDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "systemd", "user")
+	}
+	return helpers.ExpandPath(".config/systemd/user")
+}
+
+// executablePath returns the absolute path of the running vx binary, so the
+// scheduled job does not depend on PATH.
+func executablePath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate the vx binary: %v", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return exe, nil
+}
+
+func installSystemdUnits(config types.Config) error {
+	exe, err := executablePath()
+	if err != nil {
+		return err
+	}
+
+	dir := systemdUserDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	units := []struct{ name, content string }{
+		{serviceName + ".service", fmt.Sprintf(serviceUnit, quoteExecArg(exe))},
+		{serviceName + ".timer", timerUnit},
+	}
+	styles := helpers.CreateThemeStyles(config)
+	for _, unit := range units {
+		path := filepath.Join(dir, unit.name)
+		if err := os.WriteFile(path, []byte(unit.content), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s\n", styles.StatusGood.Render("Wrote"), styles.Filename.Render(path))
+	}
+
+	fmt.Println()
+	fmt.Println("Enable the daily purge with:")
+	fmt.Printf("  systemctl --user daemon-reload && systemctl --user enable --now %s.timer\n", serviceName)
+	return nil
+}
+
+func uninstallSystemdUnits(config types.Config) error {
+	styles := helpers.CreateThemeStyles(config)
+	dir := systemdUserDir()
+
+	// Stop the timer while its unit still exists. Failing here is fine,
+	// the timer may never have been enabled or systemd may be missing.
+	if systemctl, err := exec.LookPath("systemctl"); err == nil {
+		exec.Command(systemctl, "--user", "disable", "--now", serviceName+".timer").Run()
+	}
+
+	for _, name := range []string{serviceName + ".timer", serviceName + ".service"} {
+		path := filepath.Join(dir, name)
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		fmt.Printf("%s %s\n", styles.StatusGood.Render("Removed"), styles.Filename.Render(path))
+	}
+	return nil
+}
+
+// printCronLine prints a crontab entry for systems without systemd. The
+// crontab is left untouched so that existing entries are never clobbered.
+func printCronLine() error {
+	exe, err := executablePath()
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("@daily %s purge --expired >/dev/null 2>&1", exe)
+	fmt.Println("Add this line to your crontab (crontab -e):")
+	fmt.Printf("  %s\n", line)
+	return nil
+}
+
+// quoteExecArg quotes a path for an ExecStart line when it contains spaces.
+func quoteExecArg(arg string) string {
+	if strings.ContainsAny(arg, " \t\"") {
+		return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+	}
+	return arg
+}
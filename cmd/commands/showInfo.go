@@ -9,14 +9,19 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"vanish/internal/helpers"
 	"vanish/internal/types"
+	"vanish/pkg/vanish"
 )
 
+// infoPreviewLines is the height of the preview under each item.
+const infoPreviewLines = 12
+
 type infoModel struct {
 	config        types.Config
 	pattern       string
 	index         types.Index
 	styles        types.ThemeStyles
 	matchingItems []types.DeletedItem
+	previews      map[string]previewResult
 	width         int
 	height        int
 	currentPage   int
@@ -34,8 +39,8 @@ func (m *infoModel) Init() tea.Cmd {
 
 func loadInfoCmd(config types.Config) tea.Cmd {
 	return func() tea.Msg {
-		index, err := helpers.LoadIndex(config)
-		return infoLoaded{index: index, err: err}
+		items, err := vanish.New(config).List(vanish.Filter{})
+		return infoLoaded{index: types.Index{Items: items}, err: err}
 	}
 }
 
@@ -52,7 +57,32 @@ func (m *infoModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.index = msg.index
 		m.findMatches()
-		return m, tea.Quit
+
+		// Viewing an item counts as a use for LRU eviction
+		ids := make([]string, len(m.matchingItems))
+		for i, item := range m.matchingItems {
+			ids[i] = item.ID
+		}
+		helpers.TouchItems(ids, m.config)
+
+		// Payloads are read in the background, one item each, and only for
+		// the page shown
+		m.previews = make(map[string]previewResult, m.itemsPerPage)
+		var cmds []tea.Cmd
+		for _, item := range m.pageItems() {
+			cmds = append(cmds, loadInfoPreview(item, m.config))
+		}
+		if len(cmds) == 0 {
+			return m, tea.Quit
+		}
+		return m, tea.Batch(cmds...)
+
+	case previewMsg:
+		m.previews[msg.id] = msg.result
+		if len(m.previews) == len(m.pageItems()) {
+			return m, tea.Quit
+		}
+		return m, nil
 
 	case tea.KeyMsg:
 		switch msg.String() {
@@ -71,15 +101,18 @@ func (m *infoModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m *infoModel) findMatches() {
-	m.matchingItems = []types.DeletedItem{}
-	for _, item := range m.index.Items {
-		if strings.Contains(strings.ToLower(item.OriginalPath), strings.ToLower(m.pattern)) {
-			m.matchingItems = append(m.matchingItems, item)
-		}
+// loadInfoPreview reads the payload of an item for its preview.
+func loadInfoPreview(item types.DeletedItem, config types.Config) tea.Cmd {
+	return func() tea.Msg {
+		preview, err := helpers.PreviewItem(item, config)
+		return previewMsg{id: item.ID, result: previewResult{preview: preview, err: err}}
 	}
 }
 
+func (m *infoModel) findMatches() {
+	m.matchingItems = helpers.FindMatchingItems(m.index, []string{m.pattern})
+}
+
 func (m *infoModel) View() string {
 	if len(m.matchingItems) == 0 {
 		return m.renderNotFound()
@@ -143,21 +176,29 @@ func (m *infoModel) renderSummary() string {
 	return fmt.Sprintf("%s %s", icon, summary)
 }
 
-func (m *infoModel) renderItems() string {
+// pageItems returns the matching items of the current page.
+func (m *infoModel) pageItems() []types.DeletedItem {
 	start := m.currentPage * m.itemsPerPage
 	end := start + m.itemsPerPage
 	if end > len(m.matchingItems) {
 		end = len(m.matchingItems)
 	}
+	return m.matchingItems[start:end]
+}
+
+func (m *infoModel) renderItems() string {
+	page := m.pageItems()
 
 	var items []string
-	for i := start; i < end; i++ {
-		item := m.matchingItems[i]
+	for i, item := range page {
 		itemView := m.renderSingleItem(item)
 		items = append(items, itemView)
+		if result, ok := m.previews[item.ID]; ok {
+			items = append(items, "", m.renderPreview(result))
+		}
 
 		// Add separator between items (but not after the last one)
-		if i < end-1 {
+		if i < len(page)-1 {
 			items = append(items, "")
 		}
 	}
@@ -188,6 +229,38 @@ func (m *infoModel) renderSingleItem(item types.DeletedItem) string {
 	cacheValue := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Secondary)).Italic(true).Render(item.CachePath)
 	rows = append(rows, fmt.Sprintf("  %s %s", cacheLabel, cacheValue))
 
+	// Storage backend, only shown when it is not the directory layout
+	if item.Storage != "" {
+		storageLabel := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Muted)).Render("Storage:")
+		storageValue := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Secondary)).Render(item.Storage)
+		rows = append(rows, fmt.Sprintf("  %s %s", storageLabel, storageValue))
+	}
+
+	// How the content crossed filesystems, only shown when it was copied
+	if item.CopyMethod != "" {
+		copyLabel := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Muted)).Render("Copied via:")
+		copyValue := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Secondary)).Render(item.CopyMethod)
+		rows = append(rows, fmt.Sprintf("  %s %s", copyLabel, copyValue))
+	}
+
+	// Metadata the cache copy could not keep, e.g. on a filesystem without xattrs
+	if len(item.MetadataLost) > 0 {
+		lostLabel := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Muted)).Render("Not preserved:")
+		lostValue := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Warning)).Render(strings.Join(item.MetadataLost, ", "))
+		rows = append(rows, fmt.Sprintf("  %s %s", lostLabel, lostValue))
+	}
+
+	// Special files left out, such as sockets
+	if len(item.Skipped) > 0 {
+		skippedLabel := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Muted)).Render("Left out:")
+		skipped := item.Skipped
+		if len(skipped) > 3 {
+			skipped = append(skipped[:3:3], fmt.Sprintf("+%d more", len(item.Skipped)-3))
+		}
+		skippedValue := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Warning)).Render(strings.Join(skipped, ", "))
+		rows = append(rows, fmt.Sprintf("  %s %s", skippedLabel, skippedValue))
+	}
+
 	rows = append(rows, "")
 
 	// Type and Size
@@ -224,10 +297,19 @@ func (m *infoModel) renderSingleItem(item types.DeletedItem) string {
 	rows = append(rows, fmt.Sprintf("  %s %s %s", deletedLabel, deletedValue, deletedAgo))
 
 	// Expiry status
-	expiryDate := item.DeleteDate.Add(time.Duration(m.config.Cache.Days) * 24 * time.Hour)
+	expiryDate := helpers.ExpiryDate(item, m.config)
 	daysLeft := int(time.Until(expiryDate).Hours() / 24)
 
-	if daysLeft > 0 {
+	if item.IsPinned() {
+		pinIcon := "📌"
+		pinLabel := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Muted)).Render("Pinned:")
+		pinText := "until unpinned"
+		if !item.PinnedUntil.IsZero() {
+			pinText = "until " + item.PinnedUntil.Format("2006-01-02 15:04:05")
+		}
+		pinValue := m.styles.StatusGood.Render(pinText)
+		rows = append(rows, fmt.Sprintf("  %s %s %s", pinIcon, pinLabel, pinValue))
+	} else if daysLeft > 0 {
 		expiryIcon := "⏰"
 		expiryLabel := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Muted)).Render("Expires:")
 		expiryValue := m.styles.StatusGood.Render(expiryDate.Format("2006-01-02 15:04:05"))
@@ -241,6 +323,12 @@ func (m *infoModel) renderSingleItem(item types.DeletedItem) string {
 		rows = append(rows, fmt.Sprintf("  %s %s %s %s", expiryIcon, statusLabel, statusText, expiryHint))
 	}
 
+	if helpers.ShouldShred(item, m.config) {
+		shredLabel := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Muted)).Render("Removal:")
+		shredValue := m.styles.Warning.Render(fmt.Sprintf("shredded (%d passes)", helpers.ShredPasses(m.config)))
+		rows = append(rows, fmt.Sprintf("  %s %s %s", "🔒", shredLabel, shredValue))
+	}
+
 	rows = append(rows, "")
 
 	// Restore command
@@ -254,6 +342,22 @@ func (m *infoModel) renderSingleItem(item types.DeletedItem) string {
 	return content
 }
 
+// renderPreview shows the start of what the item holds, below its details.
+func (m *infoModel) renderPreview(result previewResult) string {
+	width := 80
+	if m.width > 0 {
+		width = min(m.width, 100)
+	}
+	content := previewModel{config: m.config, styles: m.styles}.render(result.preview, result.err, width-6, infoPreviewLines)
+
+	return lipgloss.NewStyle().
+		MarginLeft(2).
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(lipgloss.Color(m.config.UI.Colors.Border)).
+		PaddingLeft(1).
+		Render(content)
+}
+
 func (m *infoModel) renderPagination() string {
 	totalPages := (len(m.matchingItems) + m.itemsPerPage - 1) / m.itemsPerPage
 	currentPage := m.currentPage + 1
@@ -1,29 +1,134 @@
 package command
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 	"vanish/internal/helpers"
 	"vanish/internal/types"
+	"vanish/pkg/vanish"
 
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
+// Sort orders of the list, cycled with s.
 const (
-	itemsPerPage = 10
+	sortByDate = iota
+	sortBySize
+	sortByPath
+	sortByExpiry
 )
 
+var sortNames = []string{"date", "size", "path", "expiry"}
+
+// Screens of the list, besides browsing.
+const (
+	listBrowsing  = "browsing"
+	listPrompting = "prompting"
+	listConfirm   = "confirming"
+	listViewing   = "viewing"
+	listBusy      = "busy"
+)
+
+// Lines around the list: title, search, column header and status line.
+const listChrome = 4
+
+const (
+	// previewMinWidth is the narrowest terminal that shows the preview pane.
+	previewMinWidth = 80
+	// compactWidth is the narrowest list that shows every column.
+	compactWidth = 100
+)
+
+type listKeyMap struct {
+	toggle    key.Binding
+	toggleAll key.Binding
+	sort      key.Binding
+	reverse   key.Binding
+	restore   key.Binding
+	restoreTo key.Binding
+	purge     key.Binding
+	pin       key.Binding
+	info      key.Binding
+	diff      key.Binding
+	preview   key.Binding
+	tree      key.Binding
+	expand    key.Binding
+	collapse  key.Binding
+}
+
+func newListKeyMap() listKeyMap {
+	return listKeyMap{
+		toggle:    key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "select")),
+		toggleAll: key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "select all")),
+		sort:      key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "sort")),
+		reverse:   key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "reverse")),
+		restore:   key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "restore")),
+		restoreTo: key.NewBinding(key.WithKeys("R"), key.WithHelp("R", "restore to")),
+		purge:     key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "purge")),
+		pin:       key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "pin/unpin")),
+		info:      key.NewBinding(key.WithKeys("i", "enter"), key.WithHelp("i", "info")),
+		diff:      key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "diff")),
+		preview:   key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "preview")),
+		tree:      key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "group by directory")),
+		expand:    key.NewBinding(key.WithKeys("right", "l"), key.WithHelp("→/l", "expand")),
+		collapse:  key.NewBinding(key.WithKeys("left", "h"), key.WithHelp("←/h", "collapse")),
+	}
+}
+
+// listEntry adapts a cached item to bubbles/list, filtered on its path.
+type listEntry struct {
+	item types.DeletedItem
+}
+
+func (e listEntry) FilterValue() string {
+	return e.item.OriginalPath
+}
+
+// listAction is an action waiting for confirmation.
+type listAction struct {
+	name  string // restore, restore-to, purge, pin or unpin
+	items []types.DeletedItem
+	dest  string // Directory for restore-to
+}
+
 type listModel struct {
-	items       []types.DeletedItem
 	config      types.Config
-	cursor      int
-	currentPage int
-	totalPages  int
 	styles      types.ThemeStyles
-	err         error
+	trash       *vanish.Trash
+	keys        listKeyMap
+	list        list.Model
+	input       textinput.Model
+	viewport    viewport.Model
+	items       []types.DeletedItem
+	selected    map[string]bool
+	sortBy      int
+	reversed    bool
+	state       string
+	pending     listAction
+	cancel      context.CancelFunc
+	viewName    string
+	status      string
+	failed      bool
+	showPreview bool
+	previews    map[string]*previewResult // nil while loading
+	tree        bool                      // Grouped by original directory
+	expanded    map[string]bool           // Directories open in the tree
+	width       int
+	height      int
+	loaded      bool  // The index was read once, later failures are reloads
+	err         error // Why the index could not be read at all
 }
 
 type loadIndexMsg struct {
@@ -31,237 +136,799 @@ type loadIndexMsg struct {
 	err   error
 }
 
-func loadIndexCmd(config types.Config) tea.Cmd {
-	return func() tea.Msg {
-		index, err := helpers.LoadIndex(config)
-		if err != nil {
-			return loadIndexMsg{err: err}
-		}
+// previewResult is the preview of one item, or why there is none.
+type previewResult struct {
+	preview helpers.Preview
+	err     error
+}
+
+type previewMsg struct {
+	id     string
+	result previewResult
+}
 
-		// Sort by delete date (newest first)
-		sort.Slice(index.Items, func(i, j int) bool {
-			return index.Items[i].DeleteDate.After(index.Items[j].DeleteDate)
-		})
+// listActionMsg reports the outcome of a confirmed action.
+type listActionMsg struct {
+	status string
+	err    error
+}
 
-		return loadIndexMsg{items: index.Items}
+func loadIndexCmd(config types.Config) tea.Cmd {
+	return func() tea.Msg {
+		items, err := vanish.New(config).List(vanish.Filter{})
+		return loadIndexMsg{items: items, err: err}
 	}
 }
 
-func initialModel(config types.Config) listModel {
-	return listModel{
+func initialModel(config types.Config) *listModel {
+	m := &listModel{
 		config:      config,
-		currentPage: 0,
 		styles:      helpers.CreateThemeStyles(config),
+		trash:       vanish.New(config),
+		keys:        newListKeyMap(),
+		selected:    make(map[string]bool),
+		state:       listBrowsing,
+		showPreview: true,
+		previews:    make(map[string]*previewResult),
 	}
+
+	m.list = list.New(nil, listDelegate{m: m}, 0, 0)
+	// The title, search box and counts are drawn above the column header
+	m.list.SetShowTitle(false)
+	m.list.SetShowFilter(false)
+	m.list.SetShowStatusBar(false)
+	m.list.Filter = list.UnsortedFilter // Matches keep the chosen sort order
+	m.list.FilterInput.Prompt = "Search: "
+	m.list.KeyMap.PrevPage = key.NewBinding(key.WithKeys("left", "h", "pgup"), key.WithHelp("←/h/pgup", "prev page"))
+	m.list.KeyMap.NextPage = key.NewBinding(key.WithKeys("right", "l", "pgdown"), key.WithHelp("→/l/pgdn", "next page"))
+	m.list.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{m.keys.toggle, m.keys.restore, m.keys.purge, m.keys.info, m.keys.preview}
+	}
+	m.list.AdditionalFullHelpKeys = func() []key.Binding {
+		keys := []key.Binding{
+			m.keys.toggle, m.keys.toggleAll, m.keys.sort, m.keys.reverse,
+			m.keys.restore, m.keys.restoreTo, m.keys.purge, m.keys.pin, m.keys.info, m.keys.diff, m.keys.preview, m.keys.tree,
+		}
+		if m.tree {
+			keys = append(keys, m.keys.expand, m.keys.collapse)
+		}
+		return keys
+	}
+
+	m.input = textinput.New()
+	m.input.Prompt = "Restore into: "
+	m.input.Placeholder = "directory"
+	return m
 }
 
-func (m listModel) Init() tea.Cmd {
+func (m *listModel) Init() tea.Cmd {
 	return loadIndexCmd(m.config)
 }
 
-func (m listModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m *listModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := m.update(msg)
+	return model, tea.Batch(cmd, m.loadPreview())
+}
+
+func (m *listModel) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "q", "esc":
-			return m, tea.Quit
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.resize()
+		m.viewport.Width, m.viewport.Height = msg.Width, max(1, msg.Height-listChrome)
+		return m, nil
+
+	case previewMsg:
+		// Drop previews asked for before the index was reloaded
+		if _, ok := m.previews[msg.id]; ok {
+			m.previews[msg.id] = &msg.result
+		}
+		return m, nil
 
-		case "up", "k":
-			if m.cursor > 0 {
-				m.cursor--
-				// Move to previous page if cursor goes above current page
-				if m.cursor < m.currentPage*itemsPerPage {
-					m.currentPage--
-				}
+	case loadIndexMsg:
+		if msg.err != nil && !m.loaded {
+			m.err = msg.err
+			return m, tea.Quit
+		}
+		if msg.err != nil {
+			// Keep browsing what was read last, after the action's outcome
+			if m.status != "" {
+				m.status += "; "
 			}
-
-		case "down", "j":
-			if m.cursor < len(m.items)-1 {
-				m.cursor++
-				// Move to next page if cursor goes below current page
-				if m.cursor >= (m.currentPage+1)*itemsPerPage {
-					m.currentPage++
-				}
+			m.status += "Cannot reload the index: " + firstLine(msg.err)
+			m.failed = true
+			return m, nil
+		}
+		m.loaded = true
+		m.items = msg.items
+		// Restores and purges change payloads, read them again
+		clear(m.previews)
+		for id := range m.selected {
+			if !m.hasItem(id) {
+				delete(m.selected, id)
 			}
+		}
+		return m, m.sortItems()
 
-		case "left", "h":
-			if m.currentPage > 0 {
-				m.currentPage--
-				m.cursor = m.currentPage * itemsPerPage
+	case listActionMsg:
+		m.state = listBrowsing
+		m.cancel = nil
+		m.status, m.failed = msg.status, msg.err != nil
+		if msg.err != nil {
+			m.status += ": " + firstLine(msg.err)
+		}
+		return m, loadIndexCmd(m.config)
+
+	case tea.KeyMsg:
+		switch m.state {
+		case listBusy:
+			if msg.String() == "ctrl+c" && m.cancel != nil {
+				m.cancel()
+			}
+			return m, nil
+		case listPrompting:
+			return m.updatePrompt(msg)
+		case listConfirm:
+			return m.updateConfirm(msg)
+		case listViewing:
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "q", "esc":
+				m.state = listBrowsing
+				return m, nil
 			}
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		}
 
-		case "right", "l":
-			if m.currentPage < m.totalPages-1 {
-				m.currentPage++
-				m.cursor = m.currentPage * itemsPerPage
+		// Let the search box have every key while it is being typed in
+		if m.list.FilterState() != list.Filtering {
+			if cmd, handled := m.handleKey(msg); handled {
+				return m, cmd
 			}
+		}
+	}
 
-		case "home", "g":
-			m.cursor = 0
-			m.currentPage = 0
+	// The tree is searched whole, and folds back once the search is cleared
+	searching := m.list.FilterState() != list.Unfiltered
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	if m.tree && searching != (m.list.FilterState() != list.Unfiltered) {
+		return m, tea.Batch(cmd, m.setRows())
+	}
+	return m, cmd
+}
 
-		case "end", "G":
-			m.cursor = len(m.items) - 1
-			m.currentPage = m.totalPages - 1
+// handleKey runs the browser's own key bindings, and reports whether msg
+// was one of them.
+func (m *listModel) handleKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	if m.tree {
+		if cmd, handled := m.handleTreeKey(msg); handled {
+			return cmd, true
+		}
+	}
 
-		case "pgup":
-			if m.currentPage > 0 {
-				m.currentPage--
-				m.cursor = m.currentPage * itemsPerPage
-			}
+	switch {
+	case key.Matches(msg, m.keys.toggle):
+		if items := m.cursorItems(); len(items) > 0 {
+			m.toggle(items)
+			m.list.CursorDown()
+		}
+
+	case key.Matches(msg, m.keys.toggleAll):
+		var items []types.DeletedItem
+		for _, v := range m.list.VisibleItems() {
+			items = append(items, rowItems(v)...)
+		}
+		m.toggle(items)
+
+	case key.Matches(msg, m.keys.sort):
+		m.sortBy = (m.sortBy + 1) % len(sortNames)
+		m.reversed = false
+		return m.sortItems(), true
+
+	case key.Matches(msg, m.keys.reverse):
+		m.reversed = !m.reversed
+		return m.sortItems(), true
+
+	case key.Matches(msg, m.keys.restore):
+		m.confirm(listAction{name: "restore", items: m.targets()})
+
+	case key.Matches(msg, m.keys.restoreTo):
+		if len(m.targets()) > 0 {
+			cwd, _ := os.Getwd()
+			m.input.SetValue(cwd)
+			m.input.CursorEnd()
+			m.state = listPrompting
+			return m.input.Focus(), true
+		}
 
-		case "pgdown":
-			if m.currentPage < m.totalPages-1 {
-				m.currentPage++
-				m.cursor = m.currentPage * itemsPerPage
+	case key.Matches(msg, m.keys.purge):
+		m.confirm(listAction{name: "purge", items: m.targets()})
+
+	case key.Matches(msg, m.keys.pin):
+		targets := m.targets()
+		name := "unpin"
+		for _, item := range targets {
+			if !item.IsPinned() {
+				name = "pin"
 			}
 		}
+		m.confirm(listAction{name: name, items: targets})
 
-	case tea.WindowSizeMsg:
-		// No need to adjust viewport size anymore
+	case key.Matches(msg, m.keys.info):
+		m.showInfo(m.targets())
 
-	case loadIndexMsg:
-		if msg.err != nil {
-			m.err = msg.err
-			return m, tea.Quit
+	case key.Matches(msg, m.keys.diff):
+		m.showDiff(m.targets())
+
+	case key.Matches(msg, m.keys.preview):
+		m.showPreview = !m.showPreview
+		m.resize()
+
+	case key.Matches(msg, m.keys.tree):
+		m.tree = !m.tree
+		m.list.ResetSelected()
+		return m.setRows(), true
+
+	default:
+		return nil, false
+	}
+	return nil, true
+}
+
+func (m *listModel) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc":
+		m.input.Blur()
+		m.state = listBrowsing
+		return m, nil
+	case "enter":
+		dest := strings.TrimSpace(m.input.Value())
+		if dest == "" {
+			return m, nil
 		}
-		m.items = msg.items
-		// Calculate total pages
-		if len(m.items) > 0 {
-			m.totalPages = (len(m.items) + itemsPerPage - 1) / itemsPerPage
-		} else {
-			m.totalPages = 0
+		m.input.Blur()
+		m.state = listBrowsing
+		items := m.targets()
+		// Items land under their base names, two of the same name would
+		// collide half way through
+		if clashes := baseNameClashes(items); len(clashes) > 0 {
+			m.status, m.failed = fmt.Sprintf("Cannot restore into one directory, several items are named %s", strings.Join(clashes, ", ")), true
+			return m, nil
+		}
+		m.confirm(listAction{name: "restore-to", items: items, dest: helpers.ExpandPath(dest)})
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// baseNameClashes returns the base names shared by several items, in the
+// order first met.
+func baseNameClashes(items []types.DeletedItem) []string {
+	count := make(map[string]int)
+	var clashes []string
+	for _, item := range items {
+		base := filepath.Base(item.OriginalPath)
+		count[base]++
+		if count[base] == 2 {
+			clashes = append(clashes, base)
 		}
 	}
+	return clashes
+}
 
+func (m *listModel) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch strings.ToLower(msg.String()) {
+	case "y":
+		m.state = listBusy
+		m.status, m.failed = "", false
+		return m, m.run(m.pending)
+	case "n", "q", "esc", "ctrl+c":
+		m.state = listBrowsing
+		m.status, m.failed = "Cancelled", false
+	}
 	return m, nil
 }
 
-func (m listModel) View() string {
+// paneShown reports whether the preview pane is drawn next to the list.
+func (m *listModel) paneShown() bool {
+	return m.showPreview && m.width >= previewMinWidth
+}
+
+// listWidth is the width left to the list by the preview pane.
+func (m *listModel) listWidth() int {
+	if m.paneShown() {
+		return m.width * 55 / 100
+	}
+	return m.width
+}
+
+func (m *listModel) resize() {
+	m.list.SetSize(m.listWidth(), max(1, m.height-listChrome))
+}
+
+// loadPreview reads the payload of the item under the cursor in the
+// background, unless the pane is hidden or it was read already.
+func (m *listModel) loadPreview() tea.Cmd {
+	if !m.paneShown() || m.state != listBrowsing {
+		return nil
+	}
+	item, ok := m.cursorItem()
+	if !ok {
+		return nil
+	}
+	config := m.config
+	if _, ok := m.previews[item.ID]; ok {
+		return nil
+	}
+	m.previews[item.ID] = nil
+	return func() tea.Msg {
+		preview, err := helpers.PreviewItem(item, config)
+		return previewMsg{id: item.ID, result: previewResult{preview: preview, err: err}}
+	}
+}
+
+// previewView draws the pane with the preview of the item under the cursor.
+func (m *listModel) previewView() string {
+	width, height := m.width-m.listWidth()-2, max(1, m.height-listChrome+1)
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color(m.config.UI.Colors.Muted))
+
+	content := muted.Render("Loading preview...")
+	if row, ok := m.list.SelectedItem().(treeRow); ok && row.node != nil {
+		content = m.dirPreview(row.node, width, height)
+	} else if item, ok := m.cursorItem(); !ok {
+		content = muted.Render("No item selected")
+	} else if result := m.previews[item.ID]; result != nil {
+		content = previewModel{config: m.config, styles: m.styles}.render(result.preview, result.err, width, height)
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(lipgloss.Color(m.config.UI.Colors.Border)).
+		PaddingLeft(1).
+		Width(width + 1).
+		Height(height).
+		MaxHeight(height).
+		Render(content)
+}
+
+// targets returns the selected items, or those under the cursor when
+// nothing is selected: one item, or a whole directory of the tree.
+func (m *listModel) targets() []types.DeletedItem {
+	var items []types.DeletedItem
+	for _, item := range m.items {
+		if m.selected[item.ID] {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		items = m.cursorItems()
+	}
+	return items
+}
+
+// cursorItems returns the items of the row under the cursor.
+func (m *listModel) cursorItems() []types.DeletedItem {
+	return rowItems(m.list.SelectedItem())
+}
+
+// cursorItem returns the item under the cursor, unless it is on a
+// directory of the tree.
+func (m *listModel) cursorItem() (types.DeletedItem, bool) {
+	switch row := m.list.SelectedItem().(type) {
+	case listEntry:
+		return row.item, true
+	case treeRow:
+		return row.item, row.node == nil
+	}
+	return types.DeletedItem{}, false
+}
+
+// toggle selects items, or unselects them when they all are selected.
+func (m *listModel) toggle(items []types.DeletedItem) {
+	all := true
+	for _, item := range items {
+		all = all && m.selected[item.ID]
+	}
+	for _, item := range items {
+		if all {
+			delete(m.selected, item.ID)
+		} else {
+			m.selected[item.ID] = true
+		}
+	}
+}
+
+func (m *listModel) hasItem(id string) bool {
+	for _, item := range m.items {
+		if item.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *listModel) confirm(action listAction) {
+	if len(action.items) == 0 {
+		return
+	}
+	m.pending = action
+	m.state = listConfirm
+}
+
+// sortItems orders the items by the chosen key and hands them to the list,
+// which reapplies the current search. The tree orders its directories by
+// the same key.
+func (m *listModel) sortItems() tea.Cmd {
+	items := m.items
+	less := func(a, b types.DeletedItem) bool {
+		switch m.sortBy {
+		case sortBySize:
+			return a.Size > b.Size
+		case sortByPath:
+			return a.OriginalPath < b.OriginalPath
+		case sortByExpiry:
+			// Pinned items never expire and go last
+			if a.IsPinned() != b.IsPinned() {
+				return b.IsPinned()
+			}
+			return helpers.ExpiryDate(a, m.config).Before(helpers.ExpiryDate(b, m.config))
+		}
+		return a.DeleteDate.After(b.DeleteDate)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if m.reversed {
+			return less(items[j], items[i])
+		}
+		return less(items[i], items[j])
+	})
+	return m.setRows()
+}
+
+// run performs a confirmed action in the background.
+func (m *listModel) run(action listAction) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	trash, config := m.trash, m.config
+	ids := make([]string, len(action.items))
+	for i, item := range action.items {
+		ids[i] = item.ID
+	}
+
+	return func() tea.Msg {
+		defer cancel()
+		switch action.name {
+		case "restore", "restore-to":
+			restored, err := trash.Restore(ctx, ids, vanish.RestoreOptions{ByID: true, Into: action.dest})
+			status := fmt.Sprintf("Restored %d of %d item(s)", len(restored), len(ids))
+			if action.dest != "" {
+				status += " into " + action.dest
+			}
+			if errors.Is(err, context.Canceled) {
+				status += ", the rest was cancelled"
+				err = nil
+			}
+			return listActionMsg{status: status, err: err}
+
+		case "purge":
+			result, err := trash.Purge(vanish.PurgePolicy{IDs: ids})
+			var size int64
+			for _, item := range result.Selected {
+				size += item.Size
+			}
+			status := fmt.Sprintf("Purged %d item(s), %s freed", len(result.Selected), helpers.FormatBytes(size))
+			if kept := len(ids) - len(result.Selected); err == nil && kept > 0 {
+				status += fmt.Sprintf(", %d pinned item(s) kept", kept)
+			}
+			return listActionMsg{status: status, err: err}
+
+		default:
+			pin := action.name == "pin"
+			items, err := helpers.SetPinnedByID(ids, pin, time.Time{}, config)
+			verb := "Unpinned"
+			if pin {
+				verb = "Pinned"
+			}
+			return listActionMsg{status: fmt.Sprintf("%s %d item(s)", verb, len(items)), err: err}
+		}
+	}
+}
+
+// showInfo opens the details of the items, as vx --info shows them.
+func (m *listModel) showInfo(items []types.DeletedItem) {
+	if len(items) == 0 {
+		return
+	}
+	var sections []string
+	ids := make([]string, len(items))
+	for i, item := range items {
+		info := &infoModel{config: m.config, pattern: item.ID, styles: m.styles}
+		sections = append(sections, m.styles.Filename.Render(item.OriginalPath), info.renderSingleItem(item), "")
+		ids[i] = item.ID
+	}
+	// Viewing an item counts as a use for LRU eviction
+	helpers.TouchItems(ids, m.config)
+	m.view("Info", strings.Join(sections, "\n"))
+}
+
+// showDiff compares the items with what is now at their original paths.
+func (m *listModel) showDiff(items []types.DeletedItem) {
+	if len(items) == 0 {
+		return
+	}
+	m.view("Diff", renderDiffs(items, m.config, m.styles))
+}
+
+func (m *listModel) view(name, content string) {
+	m.viewName = name
+	m.viewport = viewport.New(m.width, max(1, m.height-listChrome))
+	m.viewport.SetContent(content)
+	m.state = listViewing
+}
+
+func (m *listModel) View() string {
 	if m.err != nil {
 		return fmt.Sprintf("Error loading index: %v\n", m.err)
 	}
 
-	var b strings.Builder
-
-	// Title
-	title := fmt.Sprintf("Cached Files (%d items)", len(m.items))
-	b.WriteString(m.styles.Title.Render(title))
-	b.WriteString("\n")
+	switch m.state {
+	case listViewing:
+		return lipgloss.JoinVertical(lipgloss.Left,
+			m.styles.Title.Render(m.viewName),
+			m.viewport.View(),
+			m.styles.Help.Render(fmt.Sprintf("↑/↓ scroll • %3.f%% • esc back", m.viewport.ScrollPercent()*100)))
+	case listConfirm:
+		return m.confirmView()
+	}
 
-	// Empty state
 	if len(m.items) == 0 {
-		b.WriteString(m.styles.Info.Render("No cached files found."))
-		b.WriteString("\n\n")
-		b.WriteString(m.styles.Help.Render("Press q to quit"))
-		return b.String()
+		return lipgloss.JoinVertical(lipgloss.Left,
+			m.styles.Title.Render("Cached Files (0 items)"),
+			m.styles.Info.Render("No cached files found."),
+			"",
+			m.statusView(),
+			m.styles.Help.Render("Press q to quit"))
 	}
 
-	// Page info
-	pageInfo := fmt.Sprintf("Page %d of %d", m.currentPage+1, m.totalPages)
-	b.WriteString(m.styles.Help.Render(pageInfo))
-	b.WriteString("\n")
+	title := fmt.Sprintf("Cached Files (%d items) • sorted by %s", len(m.items), sortNames[m.sortBy])
+	if m.tree {
+		title = fmt.Sprintf("Cached Files (%d items) • grouped by directory • sorted by %s", len(m.items), sortNames[m.sortBy])
+	}
+	if m.reversed {
+		title += " (reversed)"
+	}
+	if len(m.selected) > 0 {
+		title += fmt.Sprintf(" • %d selected", len(m.selected))
+	}
 
-	// Header
 	headerStyle := lipgloss.NewStyle().
 		Bold(true).
 		Foreground(lipgloss.Color(m.config.UI.Colors.Primary)).
-		Background(lipgloss.Color(m.config.UI.Colors.Border)).
-		Padding(0, 1)
-	header := fmt.Sprintf("%-4s | %-16s | %-8s | %-8s | %-10s | %s",
+		Background(lipgloss.Color(m.config.UI.Colors.Border))
+	header := fmt.Sprintf("  %-4s | %-16s | %-8s | %-8s | %-9s | %s",
 		"Type", "Deleted", "Size", "Status", "Days Left", "Original Path")
-	b.WriteString(headerStyle.Render(header))
-	b.WriteString("\n")
+	switch {
+	case m.tree:
+		header = fmt.Sprintf("  %-8s | %-9s | %s", "Size", "Status", "Original Location")
+	case m.listWidth() < compactWidth:
+		header = fmt.Sprintf("  %-4s | %-8s | %-8s | %s", "Type", "Size", "Status", "Original Path")
+	}
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		headerStyle.Render(truncate(header, m.listWidth())),
+		m.list.View())
+	if m.paneShown() {
+		// Cut long lines rather than wrapping them, then pad the short ones
+		column := lipgloss.NewStyle().MaxWidth(m.listWidth()).Render(body)
+		body = lipgloss.JoinHorizontal(lipgloss.Top, lipgloss.NewStyle().Width(m.listWidth()).Render(column), m.previewView())
+	}
 
-	// Calculate visible range for current page
-	visibleStart := m.currentPage * itemsPerPage
-	visibleEnd := minInt(len(m.items), visibleStart+itemsPerPage)
+	return lipgloss.JoinVertical(lipgloss.Left,
+		m.styles.Title.UnsetMargins().UnsetPadding().Render(title),
+		m.searchView(),
+		body,
+		m.statusView())
+}
 
-	// Items
-	for i := visibleStart; i < visibleEnd; i++ {
-		item := m.items[i]
-		line := m.formatItem(item, i == m.cursor)
-		b.WriteString(line)
-		b.WriteString("\n")
+// searchView shows the search box while it is typed in, then the search
+// in effect.
+func (m *listModel) searchView() string {
+	// One line, without the margin help text gets elsewhere
+	hint := m.styles.Help.UnsetMargins()
+	switch m.list.FilterState() {
+	case list.Filtering:
+		return m.list.FilterInput.View()
+	case list.FilterApplied:
+		return hint.Render(fmt.Sprintf("Search: %s • %d of %d items • esc clears",
+			m.list.FilterValue(), len(m.list.VisibleItems()), len(m.items)))
 	}
+	return hint.Render("Press / to search")
+}
 
-	// Navigation info
-	b.WriteString("\n")
-	if m.totalPages > 1 {
-		navInfo := fmt.Sprintf("← Page %d/%d →", m.currentPage+1, m.totalPages)
-		b.WriteString(m.styles.Help.Render(navInfo))
-		b.WriteString("\n")
+// statusView shows the restore-to prompt, a running action or the outcome
+// of the last one.
+func (m *listModel) statusView() string {
+	switch {
+	case m.state == listPrompting:
+		return m.input.View()
+	case m.state == listBusy:
+		return m.styles.Info.Render("Working... (ctrl+c to cancel)")
+	case m.failed:
+		return m.styles.StatusBad.Render(m.status)
+	case m.status != "":
+		return m.styles.StatusGood.Render(m.status)
 	}
+	return ""
+}
 
-	// Help text
-	help := "↑/k up • ↓/j down • ←/h prev page • →/l next page • g home • G end • q quit"
-	b.WriteString(m.styles.Help.Render(help))
+func (m *listModel) confirmView() string {
+	action := m.pending
+	var question, note string
+	switch action.name {
+	case "restore":
+		question = fmt.Sprintf("Restore %d item(s) to their original paths?", len(action.items))
+	case "restore-to":
+		question = fmt.Sprintf("Restore %d item(s) into %s?", len(action.items), action.dest)
+	case "purge":
+		question = fmt.Sprintf("Purge %d item(s) from the cache? They cannot be restored afterwards.", len(action.items))
+		for _, item := range action.items {
+			if item.IsPinned() {
+				note = "Pinned items are kept, unpin them first."
+			}
+			if helpers.ShouldShred(item, m.config) {
+				note = strings.TrimSpace(note + " Sensitive items are shredded: " + helpers.ShredCaveat)
+			}
+		}
+	case "pin":
+		question = fmt.Sprintf("Pin %d item(s) until unpinned?", len(action.items))
+	case "unpin":
+		question = fmt.Sprintf("Unpin %d item(s)? They expire by the normal retention rules.", len(action.items))
+	}
 
-	return b.String()
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color(m.config.UI.Colors.Muted))
+	lines := []string{m.styles.Question.Render(question), ""}
+	for i, item := range action.items {
+		if i == 5 {
+			lines = append(lines, muted.Render(fmt.Sprintf("  ... and %d more", len(action.items)-5)))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s", m.styles.Filename.Render(item.OriginalPath),
+			muted.Render("("+helpers.FormatBytes(item.Size)+")")))
+	}
+	if note != "" {
+		lines = append(lines, "", m.styles.Warning.Render(note))
+	}
+	lines = append(lines, "", m.styles.Help.Render("y confirm • n cancel"))
+	return m.styles.Root.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
 }
 
-func (m listModel) formatItem(item types.DeletedItem, isSelected bool) string {
+// listDelegate renders one cached item per line.
+type listDelegate struct {
+	m *listModel
+}
+
+func (d listDelegate) Height() int                             { return 1 }
+func (d listDelegate) Spacing() int                            { return 0 }
+func (d listDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+
+func (d listDelegate) Render(w io.Writer, l list.Model, index int, entry list.Item) {
+	switch e := entry.(type) {
+	case listEntry:
+		fmt.Fprint(w, d.m.formatItem(e.item, index == l.Index(), l.Width()))
+	case treeRow:
+		fmt.Fprint(w, d.m.formatTreeRow(e, index == l.Index(), l.Width()))
+	}
+}
+
+func (m *listModel) formatItem(item types.DeletedItem, isCursor bool, width int) string {
 	fileType := "FILE"
 	if item.IsDirectory {
 		fileType = "DIR"
 	}
+	status, daysLeftText, statusColor := m.itemStatus(item)
+
+	mark := " "
+	if m.selected[item.ID] {
+		mark = "●"
+	}
+
+	// The path gets whatever the other columns leave; narrow lists drop
+	// the dates
+	prefix := fmt.Sprintf("%s %-4s | %-16s | %-8s | ", mark, fileType,
+		item.DeleteDate.Format("2006-01-02 15:04"), helpers.FormatBytes(item.Size))
+	suffix := fmt.Sprintf(" | %-9s | ", daysLeftText)
+	if width > 0 && width < compactWidth {
+		prefix = fmt.Sprintf("%s %-4s | %-8s | ", mark, fileType, helpers.FormatBytes(item.Size))
+		suffix = " | "
+	}
+	path := item.OriginalPath
+	if width > 0 {
+		path = truncateLeft(path, width-lipgloss.Width(prefix+suffix)-8)
+	}
+
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color(m.config.UI.Colors.Text))
+	if isCursor {
+		style = style.Background(lipgloss.Color(m.config.UI.Colors.Highlight)).Bold(true)
+	}
+	statusStyle := style.Foreground(statusColor)
+	return style.Render(prefix) + statusStyle.Render(fmt.Sprintf("%-8s", status)) + style.Render(suffix+path)
+}
 
-	expiryDate := item.DeleteDate.Add(time.Duration(m.config.Cache.Days) * 24 * time.Hour)
+// itemStatus returns the expiry status of an item, the days it has left
+// and the colour of the status.
+func (m *listModel) itemStatus(item types.DeletedItem) (string, string, lipgloss.Color) {
+	expiryDate := helpers.ExpiryDate(item, m.config)
 	daysLeft := int(time.Until(expiryDate).Hours() / 24)
 
 	status := "OK"
+	daysLeftText := fmt.Sprintf("%d days", daysLeft)
 	var statusColor lipgloss.Color
-	if daysLeft <= 0 {
+	if item.IsPinned() {
+		status = "PINNED"
+		statusColor = lipgloss.Color(m.config.UI.Colors.Primary)
+		daysLeftText = "∞"
+		if !item.PinnedUntil.IsZero() {
+			daysLeftText = fmt.Sprintf("%d days", int(time.Until(item.PinnedUntil).Hours()/24))
+		}
+	} else if daysLeft <= 0 {
 		status = "EXPIRED"
 		statusColor = lipgloss.Color(m.config.UI.Colors.Error)
 	} else if daysLeft <= 2 {
 		status = "EXPIRING"
 		statusColor = lipgloss.Color(m.config.UI.Colors.Warning)
 	} else {
-		status = "OK"
 		statusColor = lipgloss.Color(m.config.UI.Colors.Success)
 	}
+	return status, daysLeftText, statusColor
+}
 
-	// Simple status style with just color, no borders or padding
-	statusStyle := lipgloss.NewStyle().Foreground(statusColor)
-
-	// Format the line
-	line := fmt.Sprintf("%-4s | %-16s | %-8s | %-8s | %-10s | %s",
-		fileType,
-		item.DeleteDate.Format("2006-01-02 15:04"),
-		helpers.FormatBytes(item.Size),
-		statusStyle.Render(status),
-		fmt.Sprintf("%d days", daysLeft),
-		item.OriginalPath,
-	)
-
-	// Apply selection style if this is the cursor position
-	if isSelected {
-		selectedStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color(m.config.UI.Colors.Text)).
-			Background(lipgloss.Color(m.config.UI.Colors.Highlight)).
-			Bold(true)
-		return selectedStyle.Render(line)
+// truncate cuts s to width cells, marking the cut with an ellipsis.
+func truncate(s string, width int) string {
+	if width <= 0 || lipgloss.Width(s) <= width {
+		return s
 	}
-
-	normalStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(m.config.UI.Colors.Text))
-	return normalStyle.Render(line)
+	runes := []rune(s)
+	for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
 }
 
-// Helper functions
-func minInt(a, b int) int {
-	if a < b {
-		return a
+// truncateLeft cuts the start of s to fit width cells, keeping the end of
+// a path, which tells items apart best.
+func truncateLeft(s string, width int) string {
+	if width <= 1 || lipgloss.Width(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+		runes = runes[1:]
 	}
-	return b
+	return "…" + string(runes)
 }
 
-// ShowList displays an interactive TUI list of cached files and directories
+// firstLine returns the first line of an error, enough for a status line.
+func firstLine(err error) string {
+	msg, _, _ := strings.Cut(err.Error(), "\n")
+	return msg
+}
+
+// ShowList runs the cache browser: a searchable, sortable list of cached
+// items, flat or grouped by original directory, with multi-select and
+// restore, restore-to, purge, pin, info and diff actions.
 func ShowList(config types.Config) error {
-	p := tea.NewProgram(initialModel(config))
-	if _, err := p.Run(); err != nil {
+	p := tea.NewProgram(initialModel(config), tea.WithAltScreen())
+	final, err := p.Run()
+	if err != nil {
 		return fmt.Errorf("error running TUI: %v", err)
 	}
+	// The alternate screen takes the error view with it, report it here
+	if m, ok := final.(*listModel); ok && m.err != nil {
+		return fmt.Errorf("failed to load index: %w", m.err)
+	}
 	return nil
 }
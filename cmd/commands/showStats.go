@@ -9,6 +9,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"vanish/internal/helpers"
 	"vanish/internal/types"
+	"vanish/pkg/vanish"
 )
 
 type statsModel struct {
@@ -31,11 +32,21 @@ type statsModel struct {
 	newestItem      string
 	newestItemDate  time.Time
 	avgFileSize     int64
+	// Deduplicated store: file content held for items, and bytes on disk
+	dedupLogical int64
+	dedupStored  int64
+	// Daily activity recorded by deletes, restores and purges
+	history    types.History
+	historyErr error
 }
 
 type statsLoaded struct {
-	index types.Index
-	err   error
+	index        types.Index
+	dedupLogical int64
+	dedupStored  int64
+	history      types.History
+	historyErr   error
+	err          error
 }
 
 func (m *statsModel) Init() tea.Cmd {
@@ -44,8 +55,15 @@ func (m *statsModel) Init() tea.Cmd {
 
 func loadStatsCmd(config types.Config) tea.Cmd {
 	return func() tea.Msg {
-		index, err := helpers.LoadIndex(config)
-		return statsLoaded{index: index, err: err}
+		items, err := vanish.New(config).List(vanish.Filter{})
+		if err != nil {
+			return statsLoaded{err: err}
+		}
+		logical, stored, err := helpers.DedupUsage(config)
+		// A broken history is reported in the view rather than failing the stats
+		history, historyErr := helpers.LoadHistory(config)
+		return statsLoaded{index: types.Index{Items: items}, dedupLogical: logical, dedupStored: stored,
+			history: history, historyErr: historyErr, err: err}
 	}
 }
 
@@ -61,6 +79,8 @@ func (m *statsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		}
 		m.index = msg.index
+		m.dedupLogical, m.dedupStored = msg.dedupLogical, msg.dedupStored
+		m.history, m.historyErr = msg.history, msg.historyErr
 		m.calculateStats()
 		return m, tea.Quit
 
@@ -78,7 +98,6 @@ func (m *statsModel) calculateStats() {
 		return
 	}
 
-	cutoff := time.Now().Add(-time.Duration(m.retentionDays) * 24 * time.Hour)
 	m.oldestItemDate = time.Now()
 	m.newestItemDate = time.Time{}
 
@@ -91,7 +110,7 @@ func (m *statsModel) calculateStats() {
 			m.fileCount++
 		}
 
-		if item.DeleteDate.Before(cutoff) {
+		if helpers.IsExpired(item, m.config) {
 			m.expiredCount++
 		}
 
@@ -124,7 +143,7 @@ func (m *statsModel) View() string {
 	if len(m.index.Items) == 0 {
 		emptyMsg := m.styles.Warning.Render("📦 Cache is empty")
 		help := m.styles.Help.Render("No items currently in the vanish cache")
-		return m.styles.Root.Render(lipgloss.JoinVertical(lipgloss.Left, emptyMsg, help))
+		return m.styles.Root.Render(lipgloss.JoinVertical(lipgloss.Left, emptyMsg, help, "", m.buildHistory()))
 	}
 
 	var sections []string
@@ -142,6 +161,9 @@ func (m *statsModel) View() string {
 	retentionInfo := m.buildRetentionInfo()
 	sections = append(sections, retentionInfo)
 
+	// Activity over the last days
+	sections = append(sections, m.buildHistory())
+
 	// Footer with help text
 	if m.expiredCount > 0 {
 		footer := m.buildFooter()
@@ -195,6 +217,16 @@ func (m *statsModel) buildStatsContent() string {
 		rows = append(rows, fmt.Sprintf("%s %s %s", avgIcon, avgLabel, avgValue))
 	}
 
+	// Deduplicated size, only once the dedup store holds something
+	if m.dedupLogical > 0 {
+		dedupIcon := m.styles.IconStyle.Render("🧬")
+		dedupLabel := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Muted)).Render("  Deduplicated:")
+		dedupValue := m.styles.Info.Foreground(lipgloss.Color(m.config.UI.Colors.Secondary)).Render(
+			fmt.Sprintf("%s stored for %s (saved %s)", helpers.FormatBytes(m.dedupStored),
+				helpers.FormatBytes(m.dedupLogical), helpers.FormatBytes(m.dedupLogical-m.dedupStored)))
+		rows = append(rows, fmt.Sprintf("%s %s %s", dedupIcon, dedupLabel, dedupValue))
+	}
+
 	rows = append(rows, "") // Spacer
 
 	// Largest item
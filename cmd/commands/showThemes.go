@@ -59,6 +59,26 @@ func ShowThemesWithTuiPreview(displayer ThemeDisplayer) {
 	fmt.Println("Config location:", displayer.GetConfigPath())
 }
 
+// ShowThemePreview displays the preview of a single named theme.
+func ShowThemePreview(displayer ThemeDisplayer, themeName string) error {
+	found := false
+	for _, name := range displayer.GetAvailableThemes() {
+		if name == themeName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown theme %q (see vx --themes)", themeName)
+	}
+
+	fmt.Printf("%s:\n", strings.ToUpper(themeName))
+	fmt.Println(strings.Repeat("-", 40))
+	fmt.Print(displayer.RenderThemePreview(themeName))
+	fmt.Println("To use this theme, set 'theme = \"" + themeName + "\"' in your vanish.toml cfg file.")
+	return nil
+}
+
 // GetCurrentTheme returns the name of the currently configured theme,
 // falling back to "default" if none is found.
 func (m *MainThemeDisplayer) GetCurrentTheme() string {
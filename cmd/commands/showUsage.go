@@ -70,23 +70,38 @@ func ShowUsage(config types.Config) {
 	fmt.Printf("  %s, %s     %s\n", flagStyle.Render("-r"), flagStyle.Render("--restore <pattern>..."), descStyle.Render("Restore files matching patterns"))
 	fmt.Printf("  %s, %s         %s\n", flagStyle.Render("-c"), flagStyle.Render("--clear"), descStyle.Render("Clear all cached files immediately"))
 	fmt.Printf("  %s, %s        %s\n", flagStyle.Render("-pr"), flagStyle.Render("--purge <days>"), descStyle.Render("Delete files older than N days"))
+	fmt.Printf("  %s   %s\n", flagStyle.Render("pin <pattern>... [--days N]"), descStyle.Render("Keep items past expiry, purge and eviction"))
+	fmt.Printf("  %s             %s\n", flagStyle.Render("unpin <pattern>..."), descStyle.Render("Let pinned items expire normally again"))
+	fmt.Printf("  %s             %s\n", flagStyle.Render("shred <pattern>..."), descStyle.Render("Overwrite and destroy cached items now"))
+	fmt.Printf("  %s                %s\n", flagStyle.Render("purge --expired"), descStyle.Render("Purge expired items without the TUI"))
+	fmt.Printf("  %s          %s\n", flagStyle.Render("offload [<pattern>...]"), descStyle.Render("Upload cached items to the remote tier"))
+	fmt.Printf("  %s       %s\n", flagStyle.Render("service install [--cron]"), descStyle.Render("Schedule a daily purge --expired"))
+	fmt.Printf("  %s       %s\n", flagStyle.Render("serve [--socket <path>]"), descStyle.Render("Serve a JSON API on a Unix socket"))
 	fmt.Println()
 
 	fmt.Println(sectionStyle.Render("INFORMATION:"))
-	fmt.Printf("  %s, %s          %s\n", flagStyle.Render("-l"), flagStyle.Render("--list"), descStyle.Render("Show all cached files"))
+	fmt.Printf("  %s, %s          %s\n", flagStyle.Render("-l"), flagStyle.Render("--list"), descStyle.Render("Browse cached files: search, sort, restore, purge, pin"))
 	fmt.Printf("  %s, %s     %s\n", flagStyle.Render("-i"), flagStyle.Render("--info <pattern>"), descStyle.Render("Show detailed info about cached item(s)"))
-	fmt.Printf("  %s, %s         %s\n", flagStyle.Render("-s"), flagStyle.Render("--stats"), descStyle.Render("Show cache statistics"))
+	fmt.Printf("  %s              %s\n", flagStyle.Render("diff <pattern>..."), descStyle.Render("Compare cached items with what is on disk now"))
+	fmt.Printf("  %s, %s         %s\n", flagStyle.Render("-s"), flagStyle.Render("--stats"), descStyle.Render("Show cache statistics and history"))
+	fmt.Printf("  %s %s\n", flagStyle.Render("stats --by ext|dir|age|size"), descStyle.Render("Break the cache down by extension, directory, age or size"))
+	fmt.Printf("  %s          %s\n", flagStyle.Render("stats --prometheus"), descStyle.Render("Print cache metrics in the Prometheus text format"))
 	fmt.Printf("  %s, %s          %s\n", flagStyle.Render("-p"), flagStyle.Render("--path"), descStyle.Render("Print cache directory path"))
 	fmt.Printf("  %s, %s    %s\n", flagStyle.Render("-cp"), flagStyle.Render("--config-path"), descStyle.Render("Print config file path"))
 	fmt.Println()
 
 	fmt.Println(sectionStyle.Render("CUSTOMIZATION:"))
-	fmt.Printf("  %s, %s        %s\n", flagStyle.Render("-t"), flagStyle.Render("--themes"), descStyle.Render("Interactive theme selector"))
-	// fmt.Printf("  %s            %s\n", flagStyle.Render("--completion <shell>"), descStyle.Render("Generate shell completion (bash,zsh,fish,powershell)"))
+	fmt.Printf("  %s, %s        %s\n", flagStyle.Render("-t"), flagStyle.Render("--themes [name]"), descStyle.Render("Interactive theme selector"))
+	fmt.Printf("  %s      %s\n", flagStyle.Render("completion <shell>"), descStyle.Render("Generate shell completion (bash, zsh, fish)"))
 	fmt.Println()
 
 	fmt.Println(sectionStyle.Render("OPTIONS:"))
 	fmt.Printf("  %s, %s     %s\n", flagStyle.Render("-f"), flagStyle.Render("--noconfirm"), descStyle.Render("Skip confirmation prompts"))
+	fmt.Printf("  %s   %s\n", flagStyle.Render("--force-protected"), descStyle.Render("Allow deleting protected paths (typed confirmation)"))
+	fmt.Printf("  %s    %s\n", flagStyle.Render("--include-pinned"), descStyle.Render("Let --clear remove pinned items too"))
+	fmt.Printf("  %s         %s\n", flagStyle.Render("--permanent"), descStyle.Render("Delete without caching, items cannot be restored"))
+	fmt.Printf("  %s, %s      %s\n", flagStyle.Render("-j"), flagStyle.Render("--jobs <n>"), descStyle.Render("Delete or restore n items at once (default: cache.jobs)"))
+	fmt.Printf("  %s         %s\n", flagStyle.Render("--fail-fast"), descStyle.Render("Stop at the first item that fails instead of going on"))
 	fmt.Printf("  %s, %s          %s\n", flagStyle.Render("-h"), flagStyle.Render("--help"), descStyle.Render("Show this help message"))
 	fmt.Printf("  %s, %s        %s\n", flagStyle.Render("-v"), flagStyle.Render("--version"), descStyle.Render("Show version information"))
 	fmt.Println()
@@ -107,6 +122,7 @@ func ShowUsage(config types.Config) {
 	fmt.Println(exampleStyle.Render("Maintenance:"))
 	fmt.Printf("  %s %s\n", commandStyle.Render("vx -pr 30"), descStyle.Render("# Purge files older than 30 days"))
 	fmt.Printf("  %s %s\n", commandStyle.Render("vx -s"), descStyle.Render("# Show cache statistics"))
+	fmt.Printf("  %s %s\n", commandStyle.Render("vx stats --by ext"), descStyle.Render("# See which file types fill the cache"))
 	fmt.Printf("  %s %s\n", commandStyle.Render("vx -c"), descStyle.Render("# Clear entire cache"))
 	fmt.Println()
 
@@ -129,17 +145,18 @@ func ShowUsage(config types.Config) {
 	// }
 	// fmt.Println()
 
-	// Show shell completion section if available
-	// fmt.Println(sectionStyle.Render("SHELL COMPLETION:"))
-	// completionStyle := lipgloss.NewStyle().
-	// 	Foreground(lipgloss.Color(config.UI.Colors.Secondary)).
-	// 	MarginLeft(2)
+	fmt.Println()
 
-	// fmt.Println(completionStyle.Render("Setup tab completion for better productivity:"))
-	// fmt.Printf("  %s %s\n", commandStyle.Render("vx --completion bash"), descStyle.Render("# Generate Bash completion"))
-	// fmt.Printf("  %s %s\n", commandStyle.Render("vx --completion zsh"), descStyle.Render("# Generate Zsh completion"))
-	// fmt.Printf("  %s %s\n", commandStyle.Render("vx --completion fish"), descStyle.Render("# Generate Fish completion"))
-	// fmt.Println()
+	fmt.Println(sectionStyle.Render("SHELL COMPLETION:"))
+	completionStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color(config.UI.Colors.Secondary)).
+		MarginLeft(2)
+
+	fmt.Println(completionStyle.Render("Setup tab completion for better productivity:"))
+	fmt.Printf("  %s %s\n", commandStyle.Render("vx completion bash"), descStyle.Render("# Generate Bash completion"))
+	fmt.Printf("  %s %s\n", commandStyle.Render("vx completion zsh"), descStyle.Render("# Generate Zsh completion"))
+	fmt.Printf("  %s %s\n", commandStyle.Render("vx completion fish"), descStyle.Render("# Generate Fish completion"))
+	fmt.Println()
 	fmt.Println(footerStyle.Render("For more information visit: https://github.com/Aelune/vanish"))
 }
 
@@ -158,23 +175,38 @@ func ShowUsageFallback(config types.Config) {
 	fmt.Println("  -r, --restore <pattern>...                   Restore files matching patterns")
 	fmt.Println("  -c, --clear                                   Clear all cached files immediately")
 	fmt.Println("  -pr, --purge <days>                           Delete files older than N days")
+	fmt.Println("  pin <pattern>... [--days N|--until DATE]      Keep items past expiry, purge and eviction")
+	fmt.Println("  unpin <pattern>...                            Let pinned items expire normally again")
+	fmt.Println("  shred <pattern>...                            Overwrite and destroy cached items now")
+	fmt.Println("  purge --expired                               Purge expired items without the TUI")
+	fmt.Println("  offload [<pattern>...]                        Upload cached items to the remote tier")
+	fmt.Println("  service install|uninstall [--cron]            Schedule a daily purge --expired")
+	fmt.Println("  serve [--socket <path>]                       Serve a JSON API on a Unix socket")
 	fmt.Println()
 
 	fmt.Println("INFORMATION:")
-	fmt.Println("  -l, --list                                    Show all cached files")
+	fmt.Println("  -l, --list                                    Browse cached files: search, sort, restore, purge, pin")
 	fmt.Println("  -i, --info <pattern>                          Show detailed info about cached item(s)")
-	fmt.Println("  -s, --stats                                   Show cache statistics")
+	fmt.Println("  diff <pattern>...                             Compare cached items with what is on disk now")
+	fmt.Println("  -s, --stats                                   Show cache statistics and history")
+	fmt.Println("  stats --by ext|dir|age|size                   Break the cache down by extension, directory, age or size")
+	fmt.Println("  stats --prometheus                            Print cache metrics in the Prometheus text format")
 	fmt.Println("  -p, --path                                    Print cache directory path")
 	fmt.Println("  -cp, --config-path                            Print config file path")
 	fmt.Println()
 
 	fmt.Println("CUSTOMIZATION:")
-	fmt.Println("  -t, --themes                                  Interactive theme selector")
-	// fmt.Println("  --completion <shell>                          Generate shell completion")
+	fmt.Println("  -t, --themes [name]                           Interactive theme selector")
+	fmt.Println("  completion <shell>                            Generate shell completion (bash, zsh, fish)")
 	fmt.Println()
 
 	fmt.Println("OPTIONS:")
 	fmt.Println("  -f, --noconfirm                               Skip confirmation prompts")
+	fmt.Println("  --force-protected                             Allow deleting protected paths (typed confirmation)")
+	fmt.Println("  --include-pinned                              Let --clear remove pinned items too")
+	fmt.Println("  --permanent                                   Delete without caching, items cannot be restored")
+	fmt.Println("  -j, --jobs <n>                                Delete or restore n items at once (default: cache.jobs)")
+	fmt.Println("  --fail-fast                                   Stop at the first item that fails instead of going on")
 	fmt.Println("  -h, --help                                    Show this help message")
 	fmt.Println("  -v, --version                                 Show version information")
 	fmt.Println()
@@ -0,0 +1,73 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+	"vanish/pkg/vanish"
+)
+
+// ShredItems securely destroys every cached item matching the arguments
+// right away, overwriting the payloads before unlinking them. It asks for
+// confirmation unless -f/--noconfirm is among the arguments, since shredded
+// items can never be restored.
+func ShredItems(args []string, config types.Config) error {
+	var patterns []string
+	noConfirm := false
+	for _, arg := range args {
+		switch arg {
+		case "-f", "--noconfirm":
+			noConfirm = true
+		default:
+			patterns = append(patterns, arg)
+		}
+	}
+	if len(patterns) == 0 {
+		return fmt.Errorf("shred requires at least one pattern")
+	}
+
+	items, err := vanish.New(config).List(vanish.Filter{Patterns: patterns})
+	if err != nil {
+		return fmt.Errorf("error loading index: %v", err)
+	}
+
+	styles := helpers.CreateThemeStyles(config)
+	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(config.UI.Colors.Muted))
+
+	if len(items) == 0 {
+		fmt.Println(styles.Warning.Render("No matching items found in cache"))
+		return nil
+	}
+
+	fmt.Println(styles.StatusBad.Render(fmt.Sprintf("These %d item(s) will be overwritten %d time(s) and cannot be restored:",
+		len(items), helpers.ShredPasses(config))))
+	for _, item := range items {
+		fmt.Printf("  %s %s\n", styles.Filename.Render(item.OriginalPath),
+			mutedStyle.Render("("+helpers.FormatBytes(item.Size)+")"))
+	}
+	fmt.Println(mutedStyle.Render("Note: " + helpers.ShredCaveat))
+
+	if !noConfirm {
+		fmt.Print("Shred these items? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Println(mutedStyle.Render("Cancelled"))
+			return nil
+		}
+	}
+
+	shredded, err := helpers.ShredItems(items, config)
+	for _, item := range shredded {
+		fmt.Printf("%s %s\n", styles.StatusGood.Render("Shredded"), styles.Filename.Render(item.OriginalPath))
+	}
+	if err != nil {
+		return fmt.Errorf("error shredding items: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+	"vanish/pkg/vanish"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// breakdownRows is how many extensions or directories are listed before
+// the rest is folded into one row.
+const breakdownRows = 15
+
+// breakdownTitles names the first column of each breakdown.
+var breakdownTitles = map[string]string{
+	helpers.BreakdownExt:  "Extension",
+	helpers.BreakdownDir:  "Deleted under",
+	helpers.BreakdownAge:  "Deleted",
+	helpers.BreakdownSize: "File size",
+}
+
+// StatsCommand shows the cache statistics, or with --by one of ext, dir,
+// age or size, what the cache holds broken down that way. --prometheus
+// prints the cache metrics instead. It backs `vx stats [--by <kind>]
+// [--prometheus]` and `vx --stats ...`.
+func StatsCommand(args []string, config types.Config) error {
+	var by string
+	var prometheus bool
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--prometheus":
+			prometheus = true
+		case "--by":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--by requires one of %s", strings.Join(helpers.BreakdownBy, ", "))
+			}
+			by = args[i+1]
+			i++
+		default:
+			return fmt.Errorf("unknown stats argument %q", args[i])
+		}
+	}
+
+	if prometheus {
+		if by != "" {
+			return fmt.Errorf("--prometheus cannot be combined with --by")
+		}
+		return helpers.WriteMetrics(os.Stdout, config)
+	}
+	if by == "" {
+		return ShowStats(config)
+	}
+	if !slices.Contains(helpers.BreakdownBy, by) {
+		return fmt.Errorf("unknown breakdown %q (use %s)", by, strings.Join(helpers.BreakdownBy, ", "))
+	}
+	return ShowBreakdown(by, config)
+}
+
+// ShowBreakdown prints a table and bar chart of what takes the space in
+// the cache, by extension, original directory, age or file size.
+func ShowBreakdown(by string, config types.Config) error {
+	items, err := vanish.New(config).List(vanish.Filter{})
+	if err != nil {
+		return fmt.Errorf("error loading cache index: %v", err)
+	}
+	breakdown, err := helpers.BreakdownCache(items, by, config)
+	if err != nil {
+		return err
+	}
+	if breakdown.Items == 0 {
+		fmt.Println("Cache is empty")
+		return nil
+	}
+
+	header := lipgloss.NewStyle().Foreground(lipgloss.Color(config.UI.Colors.Primary)).Bold(true)
+	bar := lipgloss.NewStyle().Foreground(lipgloss.Color(config.UI.Colors.Primary))
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color(config.UI.Colors.Muted))
+
+	rows := foldBreakdown(breakdown)
+	fmt.Println(header.Render(fmt.Sprintf("%-32s %10s %6s  %-*s %8s %6s",
+		breakdownTitles[by], "Size", "Share", barWidth, "", "Files", "Items")))
+	var peak int64
+	for _, row := range rows {
+		peak = max(peak, row.Bytes)
+	}
+	for _, row := range rows {
+		filled := 0
+		if peak > 0 {
+			filled = int(row.Bytes * barWidth / peak)
+			if row.Bytes > 0 {
+				filled = max(1, filled)
+			}
+		}
+		fmt.Printf("%-32s %10s %5.1f%%  %s %8d %6d\n",
+			truncateLeft(breakdownKey(by, row.Key), 32), helpers.FormatBytes(row.Bytes), percent(row.Bytes, breakdown.Bytes),
+			bar.Render(fmt.Sprintf("%-*s", barWidth, strings.Repeat("█", filled))), row.Files, row.Items)
+	}
+
+	fmt.Println()
+	fmt.Printf("%d item(s), %d file(s), %s\n", breakdown.Items, breakdown.Files, helpers.FormatBytes(breakdown.Bytes))
+	if summary := breakdownSummary(breakdown, items); summary != "" {
+		fmt.Println(summary)
+	}
+	if hidden := len(breakdown.Rows) - len(rows); hidden > 0 && by == helpers.BreakdownDir {
+		fmt.Println(muted.Render(fmt.Sprintf("%d smaller directories not shown", hidden)))
+	}
+	if breakdown.Unread > 0 {
+		fmt.Println(muted.Render(fmt.Sprintf("%d directory item(s) could not be read in place and count as one file", breakdown.Unread)))
+	}
+	return nil
+}
+
+// foldBreakdown keeps the largest extensions or directories and adds up
+// the rest in a last row. Directory rows overlap, so the rest of them is
+// left out instead.
+func foldBreakdown(breakdown helpers.Breakdown) []helpers.BreakdownRow {
+	if len(breakdown.Rows) <= breakdownRows {
+		return breakdown.Rows
+	}
+	if breakdown.By == helpers.BreakdownDir {
+		return breakdown.Rows[:breakdownRows]
+	}
+	rows := append([]helpers.BreakdownRow(nil), breakdown.Rows[:breakdownRows-1]...)
+	rest := breakdown.Rows[breakdownRows-1:]
+	other := helpers.BreakdownRow{Key: fmt.Sprintf("(%d more)", len(rest))}
+	for _, row := range rest {
+		other.Files += row.Files
+		other.Items += row.Items
+		other.Bytes += row.Bytes
+	}
+	return append(rows, other)
+}
+
+// breakdownKey shows the key of a row, directories relative to home.
+func breakdownKey(by, key string) string {
+	if by == helpers.BreakdownDir && !strings.HasPrefix(key, "(") {
+		return tildePath(key)
+	}
+	return key
+}
+
+// breakdownSummary answers in one line what takes the most space, or for
+// ages how much of the cache has been sitting there for a week or more.
+func breakdownSummary(breakdown helpers.Breakdown, items []types.DeletedItem) string {
+	if breakdown.Bytes == 0 || len(breakdown.Rows) == 0 {
+		return ""
+	}
+	switch breakdown.By {
+	case helpers.BreakdownAge:
+		var older int
+		var size int64
+		for _, item := range items {
+			if time.Since(item.DeleteDate) >= 7*24*time.Hour {
+				older++
+				size += item.Size
+			}
+		}
+		return fmt.Sprintf("%.0f%% of items (%s) are older than 7 days",
+			percent(int64(older), int64(len(items))), helpers.FormatBytes(size))
+
+	case helpers.BreakdownSize:
+		top := largestRow(breakdown.Rows)
+		return fmt.Sprintf("%.0f%% of the space is in %d file(s) of %s",
+			percent(top.Bytes, breakdown.Bytes), top.Files, top.Key)
+
+	case helpers.BreakdownDir:
+		top := breakdown.Rows[0]
+		return fmt.Sprintf("Most space: %s under %s (%.0f%%)",
+			helpers.FormatBytes(top.Bytes), tildePath(top.Key), percent(top.Bytes, breakdown.Bytes))
+	}
+	top := breakdown.Rows[0]
+	return fmt.Sprintf("Most space: %s of %s (%.0f%%)",
+		helpers.FormatBytes(top.Bytes), top.Key, percent(top.Bytes, breakdown.Bytes))
+}
+
+// largestRow returns the row holding the most bytes.
+func largestRow(rows []helpers.BreakdownRow) helpers.BreakdownRow {
+	top := rows[0]
+	for _, row := range rows[1:] {
+		if row.Bytes > top.Bytes {
+			top = row
+		}
+	}
+	return top
+}
+
+func percent(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
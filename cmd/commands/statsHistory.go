@@ -0,0 +1,197 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// historyWindows are the periods, in days, that the history compares.
+var historyWindows = []int{7, 30, 90}
+
+const (
+	// sparkWidth is the widest sparkline; longer periods fold several days
+	// into each cell.
+	sparkWidth = 30
+	// historyTopRows is the number of directories and extensions charted.
+	historyTopRows = 5
+	// barWidth is the width of the longest bar of a chart.
+	barWidth = 24
+)
+
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// historyWindow sums up the history over the last days.
+type historyWindow struct {
+	days                      int
+	total                     types.HistoryDay
+	deleted, restored, purged []int64 // Bytes per cell of the sparklines
+	dirs, exts                map[string]types.HistoryCount
+}
+
+// summarizeHistory adds up the days of the history ending today.
+func summarizeHistory(history types.History, days int, now time.Time) historyWindow {
+	perCell := (days + sparkWidth - 1) / sparkWidth
+	cells := (days + perCell - 1) / perCell
+	w := historyWindow{
+		days:     days,
+		deleted:  make([]int64, cells),
+		restored: make([]int64, cells),
+		purged:   make([]int64, cells),
+		dirs:     make(map[string]types.HistoryCount),
+		exts:     make(map[string]types.HistoryCount),
+	}
+
+	for i := 0; i < days; i++ {
+		day := history.Days[now.AddDate(0, 0, -i).Format(helpers.HistoryDate)]
+		if day == nil {
+			continue
+		}
+		// The last cell is today
+		cell := cells - 1 - i/perCell
+		w.deleted[cell] += day.Deleted.Bytes
+		w.restored[cell] += day.Restored.Bytes
+		w.purged[cell] += day.Purged.Bytes
+
+		w.total.Deleted = addCounts(w.total.Deleted, day.Deleted)
+		w.total.Restored = addCounts(w.total.Restored, day.Restored)
+		w.total.Purged = addCounts(w.total.Purged, day.Purged)
+		w.total.RestoreDelay += day.RestoreDelay
+		for dir, count := range day.Dirs {
+			w.dirs[dir] = addCounts(w.dirs[dir], count)
+		}
+		for ext, count := range day.Exts {
+			w.exts[ext] = addCounts(w.exts[ext], count)
+		}
+	}
+	return w
+}
+
+// countItems spells out a number of items.
+func countItems(n int) string {
+	if n == 1 {
+		return "1 item"
+	}
+	return fmt.Sprintf("%d items", n)
+}
+
+func addCounts(a, b types.HistoryCount) types.HistoryCount {
+	return types.HistoryCount{Items: a.Items + b.Items, Bytes: a.Bytes + b.Bytes}
+}
+
+// restoreSummary tells how often deleted items came back, and how long
+// they stayed in the cache first.
+func restoreSummary(total types.HistoryDay) string {
+	if total.Deleted.Items == 0 && total.Restored.Items == 0 {
+		return "nothing deleted or restored"
+	}
+	var parts []string
+	if total.Deleted.Items > 0 {
+		rate := float64(total.Restored.Items) / float64(total.Deleted.Items) * 100
+		parts = append(parts, fmt.Sprintf("restore rate %.0f%%", rate))
+	}
+	if total.Restored.Items > 0 {
+		delay := time.Duration(total.RestoreDelay/int64(total.Restored.Items)) * time.Second
+		parts = append(parts, "restored after "+formatDuration(delay)+" on average")
+	}
+	return strings.Join(parts, " • ")
+}
+
+// buildHistory charts the deletions, restores and purges of the last 7, 30
+// and 90 days, and where the deleted items came from.
+func (m *statsModel) buildHistory() string {
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color(m.config.UI.Colors.Muted))
+	label := lipgloss.NewStyle().Foreground(lipgloss.Color(m.config.UI.Colors.Text))
+	title := m.styles.IconStyle.Render("📈") + " " + label.Bold(true).Render("History")
+
+	if m.historyErr != nil {
+		return lipgloss.JoinVertical(lipgloss.Left, title, m.styles.Warning.Render("Cannot read the history: "+m.historyErr.Error()))
+	}
+	if m.history.Since.IsZero() {
+		return lipgloss.JoinVertical(lipgloss.Left, title,
+			muted.Render("Nothing recorded yet, the history starts with the next delete, restore or purge"))
+	}
+
+	rows := []string{title, muted.Render(fmt.Sprintf("Recorded since %s: %s", m.history.Since.Format("2006-01-02"), restoreSummary(m.history.Total))), ""}
+	now := time.Now()
+	var longest historyWindow
+	for _, days := range historyWindows {
+		w := summarizeHistory(m.history, days, now)
+		longest = w
+
+		peak := int64(0)
+		for _, series := range [][]int64{w.deleted, w.restored, w.purged} {
+			for _, v := range series {
+				peak = max(peak, v)
+			}
+		}
+		rows = append(rows, label.Render(fmt.Sprintf("Last %d days", days))+muted.Render(" • "+restoreSummary(w.total)))
+		for _, line := range []struct {
+			name   string
+			series []int64
+			count  types.HistoryCount
+			color  string
+		}{
+			{"Deleted", w.deleted, w.total.Deleted, m.config.UI.Colors.Warning},
+			{"Restored", w.restored, w.total.Restored, m.config.UI.Colors.Success},
+			{"Purged", w.purged, w.total.Purged, m.config.UI.Colors.Error},
+		} {
+			spark := lipgloss.NewStyle().Foreground(lipgloss.Color(line.color)).Render(sparkline(line.series, peak))
+			pad := strings.Repeat(" ", sparkWidth-len(line.series))
+			rows = append(rows, fmt.Sprintf("  %s %s%s  %s", muted.Render(fmt.Sprintf("%-8s", line.name)), spark, pad,
+				muted.Render(fmt.Sprintf("%s, %s", countItems(line.count.Items), helpers.FormatBytes(line.count.Bytes)))))
+		}
+		rows = append(rows, "")
+	}
+
+	if len(longest.dirs) > 0 {
+		rows = append(rows, label.Render(fmt.Sprintf("Deleted most from (last %d days)", longest.days)))
+		rows = append(rows, m.barChart(longest.dirs, func(dir string) string { return tildePath(dir) + string(filepath.Separator) })...)
+		rows = append(rows, "", label.Render(fmt.Sprintf("Deleted most by extension (last %d days)", longest.days)))
+		rows = append(rows, m.barChart(longest.exts, func(ext string) string { return ext })...)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+// barChart draws the largest entries of counts as horizontal bars.
+func (m *statsModel) barChart(counts map[string]types.HistoryCount, name func(string) string) []string {
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color(m.config.UI.Colors.Muted))
+	bar := lipgloss.NewStyle().Foreground(lipgloss.Color(m.config.UI.Colors.Primary))
+
+	keys := helpers.SortedCounts(counts)
+	if len(keys) > historyTopRows {
+		keys = keys[:historyTopRows]
+	}
+	peak := counts[keys[0]].Bytes
+	var rows []string
+	for _, key := range keys {
+		count := counts[key]
+		filled := 1
+		if peak > 0 {
+			filled = max(1, int(count.Bytes*barWidth/peak))
+		}
+		rows = append(rows, fmt.Sprintf("  %-28s %s %s", truncateLeft(name(key), 28),
+			bar.Render(fmt.Sprintf("%-*s", barWidth, strings.Repeat("█", filled))),
+			muted.Render(fmt.Sprintf("%s, %s", helpers.FormatBytes(count.Bytes), countItems(count.Items)))))
+	}
+	return rows
+}
+
+// sparkline draws values as block heights relative to peak. Empty cells
+// keep the lowest block, anything above zero is at least one step higher.
+func sparkline(values []int64, peak int64) string {
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if v > 0 && peak > 0 {
+			level = 1 + int((v*int64(len(sparkLevels)-2))/peak)
+		}
+		b.WriteRune(sparkLevels[min(level, len(sparkLevels)-1)])
+	}
+	return b.String()
+}
@@ -4,10 +4,14 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
+	"github.com/alecthomas/chroma/v2/styles"
+	"vanish/internal/helpers"
 	"vanish/internal/types"
 )
 
@@ -177,6 +181,43 @@ directory = ".cache/vanish"
 # Number of days to keep deleted files before automatic cleanup
 days = 10
 
+# Optional quota. When the cache grows past max_size or max_items, items
+# are evicted after each delete according to the eviction policy:
+#   "oldest"  - evict the items deleted first
+#   "largest" - evict the biggest items first
+#   "lru"     - evict the items least recently viewed with --info
+# max_size = "20GB"
+# max_items = 1000
+eviction = "oldest"
+
+# Items matching these patterns are deleted permanently instead of being
+# cached, which saves IO and space for junk that is never restored. They
+# are logged as DELETE_PERMANENT and marked unrecoverable before you
+# confirm. A pattern matches the item name, or the full path when it
+# contains a "/". Use --permanent to do the same for a single deletion.
+# permanent = ["node_modules", "target", "__pycache__"]
+
+# Overwrite payloads with random data before purge, clear and eviction
+# unlink them, so they cannot be recovered from the disk. Retention rules
+# can set sensitive = true to shred only matching items. This cannot be
+# guaranteed on copy-on-write filesystems (btrfs, ZFS, APFS) or on SSDs.
+shred = false
+shred_passes = 3
+
+# Where payloads are kept: "directory" stores each item as its own file or
+# directory in the cache; "bundle" packs items up to bundle_max_size into
+# one append-only tar archive per day under bundles/, saving inodes when
+# many small files are deleted (larger items use "directory"); "dedup"
+# stores identical file content once, even inside directories, and frees
+# it when the last item using it leaves the cache.
+storage = "directory"
+bundle_max_size = "1MB"
+
+# Number of items deleted or restored at once. Raise it to copy many items
+# to a cache on another filesystem faster, lower it on slow disks. Use
+# --jobs to override it for one run.
+jobs = 4
+
 # ------------------------------
 # Logging Configuration
 # ------------------------------
@@ -187,6 +228,96 @@ enabled = true
 # Directory for log files (relative to the cache directory above)
 directory = ".cache/vanish/logs"
 
+# ------------------------------
+# Safety Settings
+# ------------------------------
+[safety]
+# Paths that need --force-protected plus a typed confirmation before they
+# can be deleted. Glob patterns are allowed; protecting a directory also
+# protects everything inside it. /, $HOME, the config directory, mount
+# points and the current directory are always protected, and the cache
+# directory itself can never be deleted.
+protected = ["~/.ssh", "~/.gnupg"]
+
+# Deletions above any of these limits need a typed confirmation, even when
+# no_confirm is enabled. Set a limit to 0 (or "" for max_bytes) to disable it.
+# A deletion that does not fit in the free space of the cache filesystem is
+# always refused.
+max_items = 500           # Number of items deleted at once
+max_bytes = "10GB"        # Total size of the deletion
+max_files_in_dir = 10000  # Files inside a single deleted directory
+
+# ------------------------------
+# Retention Rules
+# ------------------------------
+# Rules decide how long an item is kept, overriding cache.days. The first
+# rule whose conditions all match wins; the result is stored on the item
+# when it is deleted, so editing rules later does not change cached items.
+#
+# Conditions: match (glob on the name, or the full path if it has a "/"),
+# path (directory prefix), type ("file", "directory", "symlink"),
+# min_size and max_size. Actions: days, compress (store as .tar.gz) and
+# skip_cache (delete permanently, nothing to restore) and sensitive (shred
+# the payload when it leaves the cache).
+#
+# [[retention.rules]]
+# name = "build output"
+# match = "node_modules"
+# type = "directory"
+# days = 1
+#
+# [[retention.rules]]
+# name = "large downloads"
+# path = "~/Downloads"
+# min_size = "1GB"
+# days = 3
+# compress = true
+#
+# [[retention.rules]]
+# match = "*.tmp"
+# skip_cache = true
+
+# ------------------------------
+# Remote Tier
+# ------------------------------
+# Offload cached items to an S3-compatible bucket (AWS S3, MinIO, ...)
+# instead of keeping them on this disk. Items deleted more than after_days
+# ago, or at least min_size large, are uploaded during cleanup and by
+# "vx offload"; the local copy is removed and the item is marked remote.
+# Restoring downloads it again. Uploaded items are deleted from the bucket
+# days after the upload. Pinned items are never offloaded.
+[remote]
+enabled = false
+endpoint = "http://localhost:9000"
+region = "us-east-1"
+bucket = "vanish"
+prefix = "vanish/"
+access_key = ""
+secret_key = ""
+# session_token = ""        # For temporary credentials
+# virtual_hosted = false    # Use <bucket>.<host> URLs (AWS) instead of <host>/<bucket>
+after_days = 3
+min_size = "500MB"
+days = 90
+
+# ------------------------------
+# Preview
+# ------------------------------
+# The preview pane of --list and the preview in --info read payloads
+# straight from the cache, decompressing them on the fly.
+[preview]
+highlight = true   # Syntax highlighting of text files
+style = "monokai"  # Any Chroma style, e.g. "github", "dracula", "nord"
+
+# ------------------------------
+# Metrics
+# ------------------------------
+# Rewrite cache metrics in the Prometheus text format after every
+# operation, for the node_exporter textfile collector. The file name must
+# end in .prom; give each user their own. Empty disables it.
+[metrics]
+textfile = ""      # e.g. "/var/lib/node_exporter/textfile/vanish-alice.prom"
+
 # ------------------------------
 # User Interface (UI) Settings
 # ------------------------------
@@ -239,6 +370,19 @@ func LoadConfig() (types.Config, error) {
 	config := types.Config{}
 	config.Cache.Directory = filepath.Join(homeDir, ".cache", "vanish")
 	config.Cache.Days = 10
+	config.Cache.Eviction = "oldest"
+	config.Cache.Storage = helpers.StorageDirectory
+	config.Cache.BundleMaxSize = helpers.DefaultBundleMaxSize
+	config.Cache.Jobs = helpers.DefaultJobs
+	config.Remote.Region = "us-east-1"
+	config.Remote.Prefix = "vanish/"
+	config.Remote.Days = helpers.DefaultRemoteDays
+	config.Safety.Protected = []string{"~/.ssh", "~/.gnupg"}
+	config.Safety.MaxItems = 500
+	config.Safety.MaxBytes = "10GB"
+	config.Safety.MaxFilesInDir = 10000
+	config.Preview.Highlight = true
+	config.Preview.Style = "monokai"
 	config.Logging.Enabled = true
 	config.Logging.Directory = filepath.Join(homeDir, ".cache", "vanish", "logs")
 
@@ -251,6 +395,10 @@ func LoadConfig() (types.Config, error) {
 			return config, fmt.Errorf("error parsing config file: %v", err)
 		}
 
+		if err := validateConfig(config); err != nil {
+			return config, fmt.Errorf("invalid config file: %v", err)
+		}
+
 		// fmt.Printf("DEBUG: Loaded theme from config: '%s'\n", config.UI.Theme)
 
 		// Determine which theme to use
@@ -336,3 +484,86 @@ func LoadConfig() (types.Config, error) {
 
 	return config, nil
 }
+
+// validateConfig checks values that cannot be verified by the TOML decoder.
+func validateConfig(config types.Config) error {
+	if config.Cache.MaxSize != "" {
+		if _, err := helpers.ParseSize(config.Cache.MaxSize); err != nil {
+			return fmt.Errorf("cache.max_size: %v", err)
+		}
+	}
+	validEviction := false
+	for _, policy := range helpers.EvictionPolicies {
+		if config.Cache.Eviction == policy {
+			validEviction = true
+		}
+	}
+	if !validEviction {
+		return fmt.Errorf("cache.eviction: unknown policy %q (options: %s)",
+			config.Cache.Eviction, strings.Join(helpers.EvictionPolicies, ", "))
+	}
+	if config.Cache.ShredPasses < 0 {
+		return fmt.Errorf("cache.shred_passes cannot be negative")
+	}
+	validStorage := false
+	for _, backend := range helpers.StorageBackends {
+		if config.Cache.Storage == backend {
+			validStorage = true
+		}
+	}
+	if !validStorage {
+		return fmt.Errorf("cache.storage: unknown backend %q (options: %s)",
+			config.Cache.Storage, strings.Join(helpers.StorageBackends, ", "))
+	}
+	if config.Cache.BundleMaxSize != "" {
+		if _, err := helpers.ParseSize(config.Cache.BundleMaxSize); err != nil {
+			return fmt.Errorf("cache.bundle_max_size: %v", err)
+		}
+	}
+	if config.Cache.Jobs < 1 {
+		return fmt.Errorf("cache.jobs must be at least 1")
+	}
+	if config.Safety.MaxBytes != "" {
+		if _, err := helpers.ParseSize(config.Safety.MaxBytes); err != nil {
+			return fmt.Errorf("safety.max_bytes: %v", err)
+		}
+	}
+	if _, ok := styles.Registry[config.Preview.Style]; !ok {
+		return fmt.Errorf("preview.style: unknown style %q (options: %s)",
+			config.Preview.Style, strings.Join(styles.Names(), ", "))
+	}
+	if config.Metrics.Textfile != "" && !strings.HasSuffix(config.Metrics.Textfile, ".prom") {
+		return fmt.Errorf("metrics.textfile: %q must end in .prom for the node_exporter textfile collector", config.Metrics.Textfile)
+	}
+	if err := validateRemote(config); err != nil {
+		return err
+	}
+	return helpers.ValidateRetentionRules(config.Retention.Rules)
+}
+
+// validateRemote checks the [remote] section, only when it is enabled.
+func validateRemote(config types.Config) error {
+	remote := config.Remote
+	if !remote.Enabled {
+		return nil
+	}
+	endpoint, err := url.Parse(remote.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return fmt.Errorf("remote.endpoint: expected an http or https URL, got %q", remote.Endpoint)
+	}
+	if remote.Bucket == "" {
+		return fmt.Errorf("remote.bucket is required")
+	}
+	if remote.AccessKey == "" || remote.SecretKey == "" {
+		return fmt.Errorf("remote.access_key and remote.secret_key are required")
+	}
+	if remote.MinSize != "" {
+		if _, err := helpers.ParseSize(remote.MinSize); err != nil {
+			return fmt.Errorf("remote.min_size: %v", err)
+		}
+	}
+	if remote.AfterDays < 0 || remote.Days < 0 {
+		return fmt.Errorf("remote.after_days and remote.days cannot be negative")
+	}
+	return nil
+}
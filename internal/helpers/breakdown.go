@@ -0,0 +1,231 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"vanish/internal/types"
+)
+
+// --- Cache Breakdown ---
+
+// Ways to break the cache down.
+const (
+	BreakdownExt  = "ext"
+	BreakdownDir  = "dir"
+	BreakdownAge  = "age"
+	BreakdownSize = "size"
+)
+
+// BreakdownBy lists the ways the cache can be broken down.
+var BreakdownBy = []string{BreakdownExt, BreakdownDir, BreakdownAge, BreakdownSize}
+
+// breakdownBucket is a range of an age or size breakdown, up to but not
+// including max. The last bucket has no upper bound.
+type breakdownBucket struct {
+	name string
+	max  int64
+}
+
+const oneDay = int64(24 * time.Hour)
+
+var (
+	// ageBuckets group items by how long ago they were deleted.
+	ageBuckets = []breakdownBucket{
+		{"under 1 day", oneDay},
+		{"1-7 days", 7 * oneDay},
+		{"7-30 days", 30 * oneDay},
+		{"30-90 days", 90 * oneDay},
+		{"over 90 days", 0},
+	}
+	// sizeBuckets group files by size.
+	sizeBuckets = []breakdownBucket{
+		{"under 1 KB", 1 << 10},
+		{"1 KB - 1 MB", 1 << 20},
+		{"1 MB - 100 MB", 100 << 20},
+		{"100 MB - 1 GB", 1 << 30},
+		{"1 GB and more", 0},
+	}
+)
+
+// BreakdownRow is one extension, directory, age or size range of a
+// breakdown.
+type BreakdownRow struct {
+	Key   string
+	Items int // Cached items with files in the row
+	Files int // Files, those inside deleted directories included
+	Bytes int64
+}
+
+// Breakdown is the cache split up by one property of its files.
+type Breakdown struct {
+	By     string
+	Rows   []BreakdownRow // Largest first, or in range order for age and size
+	Items  int
+	Files  int
+	Bytes  int64
+	Unread int // Items whose payload could not be walked, counted as one file
+}
+
+// BreakdownCache splits items up by extension, original directory, age or
+// file size. A directory row counts everything deleted from it or from
+// below it, so rows overlap; directories holding nothing but one of their
+// subdirectories are left out. The payloads of deleted directories are
+// walked, so their files are counted one by one; payloads that cannot be
+// read in place, such as those in the remote tier, count as a single file.
+func BreakdownCache(items []types.DeletedItem, by string, config types.Config) (Breakdown, error) {
+	breakdown := Breakdown{By: by, Items: len(items)}
+	var buckets []breakdownBucket
+	switch by {
+	case BreakdownAge:
+		buckets = ageBuckets
+	case BreakdownSize:
+		buckets = sizeBuckets
+	case BreakdownExt, BreakdownDir:
+	default:
+		return breakdown, fmt.Errorf("unknown breakdown %q (use ext, dir, age or size)", by)
+	}
+
+	rows := make(map[string]*BreakdownRow)
+	for _, bucket := range buckets {
+		rows[bucket.name] = &BreakdownRow{Key: bucket.name}
+	}
+	payloads, unread := payloadFiles(items, config)
+	now := time.Now()
+	for _, item := range items {
+		seen := make(map[string]bool)
+		add := func(ext string, size int64) {
+			var keys []string
+			switch by {
+			case BreakdownExt:
+				keys = []string{ext}
+			case BreakdownDir:
+				for dir := filepath.Dir(item.OriginalPath); ; dir = filepath.Dir(dir) {
+					keys = append(keys, dir)
+					if dir == filepath.Dir(dir) {
+						break
+					}
+				}
+			case BreakdownAge:
+				keys = []string{bucketOf(buckets, int64(now.Sub(item.DeleteDate)))}
+			case BreakdownSize:
+				keys = []string{bucketOf(buckets, size)}
+			}
+			for _, key := range keys {
+				row := rows[key]
+				if row == nil {
+					row = &BreakdownRow{Key: key}
+					rows[key] = row
+				}
+				if !seen[key] {
+					seen[key] = true
+					row.Items++
+				}
+				row.Files++
+				row.Bytes += size
+			}
+			breakdown.Files++
+			breakdown.Bytes += size
+		}
+
+		switch {
+		case !item.IsDirectory:
+			add(ItemExtension(item), item.Size)
+		case unread[item.ID]:
+			breakdown.Unread++
+			add(ItemExtension(item), item.Size)
+		default:
+			for _, file := range payloads[item.ID] {
+				add(fileExtension(path.Base(file.Path)), file.Size)
+			}
+		}
+	}
+
+	if buckets != nil {
+		for _, bucket := range buckets {
+			breakdown.Rows = append(breakdown.Rows, *rows[bucket.name])
+		}
+		return breakdown, nil
+	}
+	if by == BreakdownDir {
+		// A directory whose files are all in one subdirectory tells
+		// nothing more than that subdirectory
+		var redundant []string
+		for key, row := range rows {
+			parent := filepath.Dir(key)
+			if parent != key && rows[parent] != nil && rows[parent].Files == row.Files {
+				redundant = append(redundant, parent)
+			}
+		}
+		for _, key := range redundant {
+			delete(rows, key)
+		}
+	}
+	for _, row := range rows {
+		breakdown.Rows = append(breakdown.Rows, *row)
+	}
+	sort.Slice(breakdown.Rows, func(i, j int) bool {
+		a, b := breakdown.Rows[i], breakdown.Rows[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Key < b.Key
+	})
+	return breakdown, nil
+}
+
+// payloadFiles lists the regular files of the payload of each directory
+// item, by item ID, and the items whose payload could not be read. Items
+// stored in the same bundle are read in one pass over it.
+func payloadFiles(items []types.DeletedItem, config types.Config) (map[string][]PayloadEntry, map[string]bool) {
+	files := make(map[string][]PayloadEntry)
+	unread := make(map[string]bool)
+	bundles := make(map[string][]types.DeletedItem)
+	for _, item := range items {
+		if !item.IsDirectory {
+			continue
+		}
+		if item.Storage == StorageBundle {
+			if archive, _, err := parseBundleRef(item.CachePath); err == nil {
+				bundles[archive] = append(bundles[archive], item)
+				continue
+			}
+		}
+		err := WalkPayload(item, config, func(entry PayloadEntry, _ io.Reader) error {
+			if entry.Mode.IsRegular() {
+				files[item.ID] = append(files[item.ID], entry)
+			}
+			return nil
+		})
+		if err != nil {
+			unread[item.ID] = true
+		}
+	}
+
+	for archive, bundled := range bundles {
+		failed := walkBundle(archive, bundled, func(item types.DeletedItem, entry PayloadEntry, _ io.Reader) error {
+			if entry.Mode.IsRegular() {
+				files[item.ID] = append(files[item.ID], entry)
+			}
+			return nil
+		})
+		for id := range failed {
+			unread[id] = true
+		}
+	}
+	return files, unread
+}
+
+// bucketOf returns the name of the bucket value falls in.
+func bucketOf(buckets []breakdownBucket, value int64) string {
+	for _, bucket := range buckets {
+		if bucket.max == 0 || value < bucket.max {
+			return bucket.name
+		}
+	}
+	return buckets[len(buckets)-1].name
+}
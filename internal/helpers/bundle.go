@@ -0,0 +1,424 @@
+package helpers
+
+import (
+	"archive/tar"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// --- Bundle Storage ---
+
+// bundleTrailer is the size of the two zero blocks ending a tar archive.
+const bundleTrailer = 1024
+
+// bundleStorage packs payloads into one append-only tar archive per day,
+// so that many small deletions cost two inodes a day instead of one or
+// more each. A manifest next to each archive records where every payload's
+// file content lives and which payloads are gone. Removed payloads stay in
+// the archive, unless shredded, until every payload of that day is gone and
+// both files are deleted. References look like "<archive>#<name>".
+type bundleStorage struct {
+	dir string
+}
+
+// bundleManifest is stored as <day>.json next to <day>.tar.
+type bundleManifest struct {
+	Entries map[string]*bundleEntry `json:"entries"`
+}
+
+// bundleEntry describes one payload of a bundle.
+type bundleEntry struct {
+	Size    int64      `json:"size"`
+	Files   int        `json:"files"`
+	Ranges  [][2]int64 `json:"ranges,omitempty"` // Offset and length of each file's content
+	Deleted bool       `json:"deleted,omitempty"`
+}
+
+func (s *bundleStorage) Name() string {
+	return StorageBundle
+}
+
+func (s *bundleStorage) Put(src, name string, report *CopyReport) (string, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", err
+	}
+	archive := filepath.Join(s.dir, time.Now().Format("2006-01-02")+".tar")
+
+	file, err := openLockedBundle(archive, os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	manifest, err := loadBundleManifest(archive)
+	if err != nil {
+		return "", err
+	}
+	if _, exists := manifest.Entries[name]; exists {
+		return "", fmt.Errorf("%s is already in %s", name, filepath.Base(archive))
+	}
+
+	// Overwrite the trailer of the previous append
+	stat, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	start := stat.Size() - bundleTrailer
+	if start < 0 {
+		start = 0
+	}
+
+	// Drop the append and put the trailer back
+	dropAppend := func() {
+		file.Truncate(start)
+		if start > 0 {
+			file.WriteAt(make([]byte, bundleTrailer), start)
+		}
+	}
+	entry, err := appendToBundle(file, start, src, name, report)
+	if err != nil {
+		dropAppend()
+		return "", fmt.Errorf("failed to append to bundle: %w", err)
+	}
+
+	manifest.Entries[name] = entry
+	if err := saveBundleManifest(archive, manifest); err != nil {
+		dropAppend()
+		return "", err
+	}
+	if err := os.RemoveAll(src); err != nil {
+		// Whatever RemoveAll got to comes back from the archive, and the
+		// entry goes, so that src is the only copy again
+		if putBackErr := extractFromBundle(file, name, src, nil, true); putBackErr != nil {
+			return "", fmt.Errorf("%v, and %w: %v", err, errNotPutBack, putBackErr)
+		}
+		removeFromBundle(file, archive, manifest, name, 0)
+		return "", err
+	}
+	return archive + "#" + name, nil
+}
+
+// appendToBundle writes src as tar entries under name, starting at offset
+// start of file, followed by a fresh trailer.
+func appendToBundle(file *os.File, start int64, src, name string, report *CopyReport) (*bundleEntry, error) {
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	counter := &countingWriter{w: file, n: start}
+	tw := tar.NewWriter(counter)
+	entry := &bundleEntry{}
+	links := hardlinks{}
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := report.err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		entryName := name
+		if rel != "." {
+			entryName += "/" + filepath.ToSlash(rel)
+		}
+		if info.IsDir() {
+			entryName += "/"
+		}
+		header, err := archiveHeader(path, info, entryName, links, report)
+		if errors.Is(err, errSocket) && path != src {
+			report.skip(path)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		offset := counter.n
+		written, err := io.Copy(tw, report.reader(f))
+		if err != nil {
+			return err
+		}
+		entry.Ranges = append(entry.Ranges, [2]int64{offset, written})
+		entry.Size += written
+		entry.Files++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return entry, file.Sync()
+}
+
+func (s *bundleStorage) Get(ref, dst string, report *CopyReport) error {
+	archive, name, err := parseBundleRef(ref)
+	if err != nil {
+		return err
+	}
+
+	file, err := openLockedBundle(archive, os.O_RDWR)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	manifest, err := loadBundleManifest(archive)
+	if err != nil {
+		return err
+	}
+	entry := manifest.Entries[name]
+	if entry == nil || entry.Deleted {
+		return &os.PathError{Op: "get", Path: ref, Err: os.ErrNotExist}
+	}
+
+	if err := extractFromBundle(file, name, dst, report, false); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return removeFromBundle(file, archive, manifest, name, 0)
+}
+
+// extractFromBundle recreates the payload stored under name at dst. To
+// repair a partial tree, entries already at dst are kept.
+func extractFromBundle(file *os.File, name, dst string, report *CopyReport, repair bool) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	found := false
+	var extracted extractedEntries
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		entryName := strings.TrimSuffix(header.Name, "/")
+		if entryName != name && !strings.HasPrefix(entryName, name+"/") {
+			continue
+		}
+		target, err := bundleTarget(dst, name, entryName)
+		if err != nil {
+			return err
+		}
+		found = true
+		if _, err := os.Lstat(target); repair && err == nil && header.Typeflag != tar.TypeDir {
+			continue
+		}
+		if header.Typeflag == tar.TypeLink {
+			if header.Linkname, err = bundleTarget(dst, name, header.Linkname); err != nil {
+				return err
+			}
+		}
+		created, err := extractEntry(header, tr, target, report)
+		if err != nil {
+			return err
+		}
+		if created {
+			extracted.add(header, target)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%s not found in %s", name, filepath.Base(file.Name()))
+	}
+	extracted.apply(report)
+	return nil
+}
+
+// bundleTarget returns where the entry entryName of the payload stored
+// under name goes when the payload is extracted to dst.
+func bundleTarget(dst, name, entryName string) (string, error) {
+	rel := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSuffix(entryName, "/"), name), "/")
+	return archiveTarget(dst, rel)
+}
+
+func (s *bundleStorage) Delete(ref string, passes int) error {
+	archive, name, err := parseBundleRef(ref)
+	if err != nil {
+		return err
+	}
+
+	file, err := openLockedBundle(archive, os.O_RDWR)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	manifest, err := loadBundleManifest(archive)
+	if err != nil {
+		return err
+	}
+	entry := manifest.Entries[name]
+	if entry == nil || entry.Deleted {
+		return &os.PathError{Op: "delete", Path: ref, Err: os.ErrNotExist}
+	}
+	return removeFromBundle(file, archive, manifest, name, passes)
+}
+
+// removeFromBundle marks a payload deleted, overwriting its file content
+// first when passes is above zero, and deletes the bundle once nothing in
+// it is left.
+func removeFromBundle(file *os.File, archive string, manifest *bundleManifest, name string, passes int) error {
+	entry := manifest.Entries[name]
+	for pass := 0; pass < passes; pass++ {
+		for _, r := range entry.Ranges {
+			if _, err := file.Seek(r[0], io.SeekStart); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(file, rand.Reader, r[1]); err != nil {
+				return err
+			}
+		}
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+	entry.Deleted = true
+
+	for _, other := range manifest.Entries {
+		if !other.Deleted {
+			return saveBundleManifest(archive, manifest)
+		}
+	}
+	if err := os.Remove(archive); err != nil {
+		return err
+	}
+	return os.Remove(bundleManifestPath(archive))
+}
+
+func (s *bundleStorage) Stat(ref string) (StorageInfo, error) {
+	archive, name, err := parseBundleRef(ref)
+	if err != nil {
+		return StorageInfo{}, err
+	}
+	manifest, err := loadBundleManifest(archive)
+	if err != nil {
+		return StorageInfo{}, err
+	}
+	entry := manifest.Entries[name]
+	if entry == nil || entry.Deleted {
+		return StorageInfo{}, &os.PathError{Op: "stat", Path: ref, Err: os.ErrNotExist}
+	}
+	return StorageInfo{Ref: ref, Size: entry.Size, Files: entry.Files}, nil
+}
+
+func (s *bundleStorage) List() ([]string, error) {
+	manifests, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	for _, manifestPath := range manifests {
+		archive := strings.TrimSuffix(manifestPath, ".json") + ".tar"
+		manifest, err := loadBundleManifest(archive)
+		if err != nil {
+			return refs, err
+		}
+		for name, entry := range manifest.Entries {
+			if !entry.Deleted {
+				refs = append(refs, archive+"#"+name)
+			}
+		}
+	}
+	return refs, nil
+}
+
+// parseBundleRef splits a bundle reference into archive path and name.
+func parseBundleRef(ref string) (string, string, error) {
+	i := strings.Index(ref, ".tar#")
+	if i < 0 {
+		return "", "", fmt.Errorf("invalid bundle reference: %s", ref)
+	}
+	return ref[:i+len(".tar")], ref[i+len(".tar#"):], nil
+}
+
+// openLockedBundle opens an archive and takes an exclusive lock on it, so
+// that concurrent vx processes do not interleave appends. The lock is
+// released when the file is closed.
+func openLockedBundle(archive string, flag int) (*os.File, error) {
+	file, err := os.OpenFile(archive, flag, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func bundleManifestPath(archive string) string {
+	return strings.TrimSuffix(archive, ".tar") + ".json"
+}
+
+// loadBundleManifest reads the manifest of archive, returning an empty one
+// if it does not exist yet.
+func loadBundleManifest(archive string) (*bundleManifest, error) {
+	manifest := &bundleManifest{Entries: map[string]*bundleEntry{}}
+	data, err := os.ReadFile(bundleManifestPath(archive))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", bundleManifestPath(archive), err)
+	}
+	if manifest.Entries == nil {
+		manifest.Entries = map[string]*bundleEntry{}
+	}
+	return manifest, nil
+}
+
+// saveBundleManifest replaces the manifest atomically so that Stat and
+// List never read a half-written file.
+func saveBundleManifest(archive string, manifest *bundleManifest) error {
+	return writeJSONFile(bundleManifestPath(archive), manifest)
+}
+
+// countingWriter tracks the file offset reached through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
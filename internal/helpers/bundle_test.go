@@ -0,0 +1,170 @@
+package helpers
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// writeTree creates the files of tree, by slash-separated path, under root.
+func writeTree(t *testing.T, root string, tree map[string]string) {
+	t.Helper()
+	for name, content := range tree {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkTree fails unless root holds exactly the files of tree.
+func checkTree(t *testing.T, root string, tree map[string]string) {
+	t.Helper()
+	found := 0
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if want, ok := tree[filepath.ToSlash(rel)]; !ok || string(data) != want {
+			t.Errorf("%s holds %q, want %q", rel, data, want)
+		}
+		found++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != len(tree) {
+		t.Errorf("%s holds %d files, want %d", root, found, len(tree))
+	}
+}
+
+func TestBundleManifest(t *testing.T) {
+	dir := t.TempDir()
+	store := &bundleStorage{dir: filepath.Join(dir, "bundles")}
+	tree := map[string]string{"x": "first file", "sub/y": "second", "sub/empty": ""}
+	writeTree(t, filepath.Join(dir, "a"), tree)
+	writeTree(t, filepath.Join(dir, "b"), map[string]string{"b": "a single file"})
+
+	refA, err := store.Put(filepath.Join(dir, "a"), "a", nil)
+	if err != nil {
+		t.Fatalf("Put a: %v", err)
+	}
+	refB, err := store.Put(filepath.Join(dir, "b", "b"), "b", nil)
+	if err != nil {
+		t.Fatalf("Put b: %v", err)
+	}
+	archive, _, err := parseBundleRef(refA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, _, _ := parseBundleRef(refB); other != archive {
+		t.Fatalf("items of the same day went to %s and %s", archive, other)
+	}
+
+	// Every range points at the content of one file in the archive
+	manifest, err := loadBundleManifest(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := func(name string) []string {
+		var got []string
+		for _, r := range manifest.Entries[name].Ranges {
+			got = append(got, string(data[r[0]:r[0]+r[1]]))
+		}
+		sort.Strings(got)
+		return got
+	}
+	tests := []struct {
+		name  string
+		size  int64
+		files int
+		want  []string
+	}{
+		{"a", 16, 3, []string{"", "first file", "second"}},
+		{"b", 13, 1, []string{"a single file"}},
+	}
+	for _, test := range tests {
+		entry := manifest.Entries[test.name]
+		if entry == nil {
+			t.Fatalf("%s is missing from the manifest", test.name)
+		}
+		if entry.Size != test.size || entry.Files != test.files {
+			t.Errorf("%s: size %d, %d files, want %d, %d", test.name, entry.Size, entry.Files, test.size, test.files)
+		}
+		if got := contents(test.name); !slices.Equal(got, test.want) {
+			t.Errorf("%s: ranges hold %q, want %q", test.name, got, test.want)
+		}
+	}
+	if refs, err := store.List(); err != nil || len(refs) != 2 {
+		t.Errorf("List = %v, %v, want both items", refs, err)
+	}
+
+	// A payload taken out is marked deleted, the bundle goes with the last
+	if err := store.Get(refA, filepath.Join(dir, "a"), nil); err != nil {
+		t.Fatalf("Get a: %v", err)
+	}
+	checkTree(t, filepath.Join(dir, "a"), tree)
+	if manifest, err = loadBundleManifest(archive); err != nil {
+		t.Fatal(err)
+	}
+	if !manifest.Entries["a"].Deleted || manifest.Entries["b"].Deleted {
+		t.Errorf("after Get a: a deleted %v, b deleted %v", manifest.Entries["a"].Deleted, manifest.Entries["b"].Deleted)
+	}
+	if _, err := store.Stat(refA); !os.IsNotExist(err) {
+		t.Errorf("Stat a after Get = %v, want not found", err)
+	}
+
+	if err := store.Delete(refB, 1); err != nil {
+		t.Fatalf("Delete b: %v", err)
+	}
+	for _, path := range []string{archive, bundleManifestPath(archive)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s is still there once the bundle is empty: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestBundleWalk(t *testing.T) {
+	dir := t.TempDir()
+	store := &bundleStorage{dir: filepath.Join(dir, "bundles")}
+	writeTree(t, filepath.Join(dir, "a"), map[string]string{"x": "12345", "sub/y": "123"})
+	ref, err := store.Put(filepath.Join(dir, "a"), "a", nil)
+	if err != nil {
+		t.Fatalf("Put a: %v", err)
+	}
+
+	files := make(map[string]string)
+	err = store.walk(ref, func(entry PayloadEntry, content io.Reader) error {
+		if content == nil {
+			return nil
+		}
+		data, err := io.ReadAll(content)
+		if int64(len(data)) != entry.Size {
+			t.Errorf("%s: read %d bytes, entry says %d", entry.Path, len(data), entry.Size)
+		}
+		files[entry.Path] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	if files["x"] != "12345" || files["sub/y"] != "123" || len(files) != 2 {
+		t.Errorf("walk read %q", files)
+	}
+}
@@ -0,0 +1,275 @@
+package helpers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// --- Compression Helpers ---
+
+// CompressedSuffix is appended to the cache path of compressed payloads.
+const CompressedSuffix = ".tar.gz"
+
+// CompressToCache packs src (a file, directory or symlink) into a gzipped
+// tar archive at dst and removes src once the archive is complete. The
+// root of src is stored as "." so that it can be restored under any name.
+// Metadata that could not be read is recorded in report, which may be nil.
+func CompressToCache(src, dst string, report *CopyReport) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if err := writeArchive(src, out, report); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.RemoveAll(src)
+}
+
+func writeArchive(src string, w io.Writer, report *CopyReport) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	links := hardlinks{}
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := report.err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(rel)
+		if info.IsDir() {
+			name += "/"
+		}
+		header, err := archiveHeader(path, info, name, links, report)
+		if errors.Is(err, errSocket) && path != src {
+			report.skip(path)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, report.reader(file))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ExtractFromCache unpacks an archive written by CompressToCache so that
+// its root ends up at dst, then removes the archive. Metadata that could
+// not be restored is recorded in report, which may be nil.
+func ExtractFromCache(archive, dst string, report *CopyReport) error {
+	if err := extractArchive(archive, dst, report); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.Remove(archive)
+}
+
+func extractArchive(archive, dst string, report *CopyReport) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	var extracted extractedEntries
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := archiveTarget(dst, header.Name)
+		if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeLink {
+			if header.Linkname, err = archiveTarget(dst, header.Linkname); err != nil {
+				return err
+			}
+		}
+		created, err := extractEntry(header, tr, target, report)
+		if err != nil {
+			return err
+		}
+		if created {
+			extracted.add(header, target)
+		}
+	}
+
+	extracted.apply(report)
+	return nil
+}
+
+// archiveHeader returns the tar header named name of the file at path,
+// with its owner, times and extended attributes. A file whose inode was
+// archived before becomes a hardlink to it, sparse files are marked so
+// that extraction restores their holes, and sockets are refused with
+// errSocket. Reading the metadata happens before the content, which
+// updates the access time.
+func archiveHeader(path string, info os.FileInfo, name string, links hardlinks, report *CopyReport) (*tar.Header, error) {
+	if info.Mode()&os.ModeSocket != 0 {
+		return nil, errSocket
+	}
+
+	var err error
+	linkTarget := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		if linkTarget, err = os.Readlink(path); err != nil {
+			return nil, err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, linkTarget)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := readMetadata(path, report)
+	if err != nil {
+		return nil, err
+	}
+	meta.addToHeader(header)
+	header.Name = name
+
+	if first, seen := links.link(info, name); seen {
+		header.Typeflag = tar.TypeLink
+		header.Linkname = first
+		header.Size = 0
+	} else if isSparse(info) {
+		if header.PAXRecords == nil {
+			header.PAXRecords = make(map[string]string)
+		}
+		header.PAXRecords[paxSparse] = "1"
+	}
+	return header, nil
+}
+
+// extractedEntries remembers the metadata of extracted entries, which is
+// applied once everything is in place: creating children changes the
+// times of their directory.
+type extractedEntries []extractedEntry
+
+type extractedEntry struct {
+	target string
+	meta   *fileMetadata
+}
+
+func (e *extractedEntries) add(header *tar.Header, target string) {
+	*e = append(*e, extractedEntry{target, metadataFromHeader(header)})
+}
+
+// apply sets the metadata in reverse order, children before their parent.
+func (e extractedEntries) apply(report *CopyReport) {
+	for i := len(e) - 1; i >= 0; i-- {
+		e[i].meta.apply(e[i].target, report)
+	}
+}
+
+// archiveTarget returns where the archive entry name goes under dst,
+// refusing names that would escape it.
+func archiveTarget(dst, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) || filepath.IsAbs(clean) {
+		return "", fmt.Errorf("invalid path in archive: %s", name)
+	}
+	return filepath.Join(dst, clean), nil
+}
+
+// extractEntry creates the directory, symlink, hardlink, regular file,
+// FIFO or device node described by header at target, reading file content
+// from r. Hardlinks must have their Linkname resolved to a path already.
+// Device nodes that cannot be created without root are recorded in report
+// and reported as not created.
+func extractEntry(header *tar.Header, r io.Reader, target string, report *CopyReport) (bool, error) {
+	if header.Typeflag != tar.TypeDir {
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return false, err
+		}
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		// Owner write access until the metadata is applied, for the children
+		return true, os.MkdirAll(target, os.FileMode(header.Mode).Perm()|0700)
+	case tar.TypeSymlink:
+		return true, os.Symlink(header.Linkname, target)
+	case tar.TypeLink:
+		return true, os.Link(header.Linkname, target)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		rdev := unix.Mkdev(uint32(header.Devmajor), uint32(header.Devminor))
+		err := makeNode(target, header.FileInfo().Mode(), rdev)
+		if errors.Is(err, os.ErrPermission) {
+			report.skip(target)
+			return false, nil
+		}
+		return err == nil, err
+	case tar.TypeReg:
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+		if err != nil {
+			return false, err
+		}
+		if header.PAXRecords[paxSparse] != "" {
+			err = writeSparse(out, report.reader(r))
+		} else {
+			_, err = io.Copy(out, report.reader(r))
+		}
+		if err != nil {
+			out.Close()
+			return false, err
+		}
+		return true, out.Close()
+	}
+	return false, nil
+}
@@ -0,0 +1,200 @@
+package helpers
+
+import (
+	"context"
+	"io"
+	"slices"
+	"sort"
+)
+
+// --- Copy Methods ---
+
+// Ways file content gets copied when a payload cannot be renamed, from
+// fastest to slowest, as recorded in DeletedItem.CopyMethod.
+const (
+	CopyReflink   = "reflink"         // FICLONE, shares extents on btrfs and XFS
+	CopyFileRange = "copy_file_range" // In-kernel copy, offloaded by some filesystems
+	CopyReadWrite = "read/write"      // Plain userspace copy
+)
+
+var copyMethods = []string{CopyReflink, CopyFileRange, CopyReadWrite}
+
+// copyChunk bounds a single copy_file_range call, so that progress is
+// reported and cancellation noticed while large files are copied.
+const copyChunk = 8 << 20
+
+// CopyReport collects what a copy did besides moving content: the kinds
+// of metadata it could not preserve, the special files it left out and the
+// copy methods it used. It also carries the context that cancels the copy
+// and the callback told about copied bytes. A nil *CopyReport discards
+// everything and never cancels.
+type CopyReport struct {
+	lost    map[string]bool
+	skipped []string
+	methods map[string]bool
+
+	ctx      context.Context
+	progress func(n int64)
+	expected int64 // Bytes the item accounts for, 0 when unknown
+	counted  int64
+}
+
+// NewCopyReport returns a report for a copy that stops with ctx.Err()
+// once ctx is cancelled. progress, when set, is called with the size of
+// each chunk of content copied.
+func NewCopyReport(ctx context.Context, progress func(n int64)) *CopyReport {
+	return &CopyReport{ctx: ctx, progress: progress}
+}
+
+// expect sets the number of bytes the item being copied accounts for.
+// Progress never goes past it, even when content is read twice, as with
+// compressed payloads, and finish makes up for renames that copy nothing.
+func (r *CopyReport) expect(size int64) {
+	if r != nil {
+		r.expected, r.counted = size, 0
+	}
+}
+
+// finish reports whatever part of the expected size was not counted.
+func (r *CopyReport) finish() {
+	if r != nil && r.expected > 0 && r.progress != nil && r.counted < r.expected {
+		r.progress(r.expected - r.counted)
+		r.counted = r.expected
+	}
+}
+
+// count records n bytes copied and returns the cancellation error, if
+// any, so that copies stop between chunks.
+func (r *CopyReport) count(n int64) error {
+	if r == nil {
+		return nil
+	}
+	if r.expected > 0 {
+		n = min(n, r.expected-r.counted)
+	}
+	if n > 0 {
+		r.counted += n
+		if r.progress != nil {
+			r.progress(n)
+		}
+	}
+	return r.err()
+}
+
+// err returns ctx.Err() of the report's context.
+func (r *CopyReport) err() error {
+	if r == nil || r.ctx == nil {
+		return nil
+	}
+	return r.ctx.Err()
+}
+
+// context returns the report's context, for network transfers.
+func (r *CopyReport) context() context.Context {
+	if r == nil || r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// commit stops the copies that follow from being cancelled, once the
+// payload has left its old place and must reach the new one.
+func (r *CopyReport) commit() {
+	if r != nil {
+		r.ctx = nil
+	}
+}
+
+// reader wraps r so that reads are counted and fail once cancelled.
+func (r *CopyReport) reader(src io.Reader) io.Reader {
+	if r == nil {
+		return src
+	}
+	return &countingReader{r: src, report: r}
+}
+
+// countingReader reports what is read through it to a CopyReport.
+type countingReader struct {
+	r      io.Reader
+	report *CopyReport
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if countErr := c.report.count(int64(n)); countErr != nil {
+		return n, countErr
+	}
+	return n, err
+}
+
+// lose records a kind of metadata that could not be preserved.
+func (r *CopyReport) lose(kind string) {
+	if r == nil {
+		return
+	}
+	if r.lost == nil {
+		r.lost = make(map[string]bool)
+	}
+	r.lost[kind] = true
+}
+
+// loseAll records kinds lost earlier, such as an item's MetadataLost.
+func (r *CopyReport) loseAll(kinds []string) {
+	for _, kind := range kinds {
+		r.lose(kind)
+	}
+}
+
+// LostMetadata returns the kinds of metadata lost, sorted, or nil if none
+// was.
+func (r *CopyReport) LostMetadata() []string {
+	if r == nil || len(r.lost) == 0 {
+		return nil
+	}
+	kinds := make([]string, 0, len(r.lost))
+	for kind := range r.lost {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// skip records a special file left out of the copy.
+func (r *CopyReport) skip(path string) {
+	if r != nil {
+		r.skipped = append(r.skipped, path)
+	}
+}
+
+// Skipped returns the special files left out, in the order met.
+func (r *CopyReport) Skipped() []string {
+	if r == nil {
+		return nil
+	}
+	return r.skipped
+}
+
+// use records the method used to copy a file's content.
+func (r *CopyReport) use(method string) {
+	if r == nil {
+		return
+	}
+	if r.methods == nil {
+		r.methods = make(map[string]bool)
+	}
+	r.methods[method] = true
+}
+
+// Method returns the slowest copy method used, or "" when nothing had to
+// be copied because every rename succeeded.
+func (r *CopyReport) Method() string {
+	if r == nil {
+		return ""
+	}
+	for _, method := range slices.Backward(copyMethods) {
+		if r.methods[method] {
+			return method
+		}
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyContent copies the content of src into the empty file dst. It tries
+// a reflink first, which is instant and takes no space, then
+// copy_file_range, then a userspace copy, and returns the method that
+// worked. Holes of sparse files are kept.
+func copyContent(dst, src *os.File, info os.FileInfo, report *CopyReport) (string, error) {
+	if err := report.err(); err != nil {
+		return "", err
+	}
+	if err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())); err == nil {
+		return CopyReflink, report.count(info.Size())
+	}
+	if isSparse(info) {
+		return copySparse(dst, src, info.Size(), report)
+	}
+	return copyRange(dst, src, 0, info.Size(), report)
+}
+
+// copyRange copies n bytes at offset of src to the same offset of dst with
+// copy_file_range, falling back to a userspace copy when the kernel or
+// filesystem does not support it between these files. Copied bytes are
+// counted in report, which stops the copy when cancelled.
+func copyRange(dst, src *os.File, offset, n int64, report *CopyReport) (string, error) {
+	srcOffset, dstOffset := offset, offset
+	for n > 0 {
+		written, err := unix.CopyFileRange(int(src.Fd()), &srcOffset, int(dst.Fd()), &dstOffset, int(min(n, copyChunk)), 0)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			if !copyRangeUnsupported(err) {
+				return "", err
+			}
+			// The offsets are where the kernel stopped, finish in userspace
+			_, err := io.Copy(io.NewOffsetWriter(dst, dstOffset), report.reader(io.NewSectionReader(src, srcOffset, n)))
+			return CopyReadWrite, err
+		}
+		if written == 0 {
+			break // src is shorter than it was
+		}
+		n -= int64(written)
+		if err := report.count(int64(written)); err != nil {
+			return "", err
+		}
+	}
+	return CopyFileRange, nil
+}
+
+// copyRangeUnsupported reports whether a copy_file_range error means the
+// call cannot work for these files, rather than a failing copy.
+func copyRangeUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EXDEV) || errors.Is(err, unix.EINVAL) ||
+		errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EBADF) || errors.Is(err, unix.EPERM)
+}
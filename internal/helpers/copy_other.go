@@ -0,0 +1,25 @@
+//go:build !linux
+
+package helpers
+
+import (
+	"io"
+	"os"
+)
+
+// copyContent copies the content of src into the empty file dst with a
+// userspace copy; reflinks and copy_file_range are Linux-only.
+func copyContent(dst, src *os.File, info os.FileInfo, report *CopyReport) (string, error) {
+	if err := report.err(); err != nil {
+		return "", err
+	}
+	return copyRange(dst, src, 0, info.Size(), report)
+}
+
+// copyRange copies n bytes at offset of src to the same offset of dst.
+// Copied bytes are counted in report, which stops the copy when
+// cancelled.
+func copyRange(dst, src *os.File, offset, n int64, report *CopyReport) (string, error) {
+	_, err := io.Copy(io.NewOffsetWriter(dst, offset), report.reader(io.NewSectionReader(src, offset, n)))
+	return CopyReadWrite, err
+}
@@ -0,0 +1,567 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"vanish/internal/types"
+)
+
+// --- Deduplicated Storage ---
+
+// StorageDedup is the backend name of the content-addressed store.
+const StorageDedup = "dedup"
+
+// dedupStorage keeps the content of every regular file once, as a blob
+// named after its SHA-256 under objects/, whatever item or directory it
+// came from. Each payload is a manifest under manifests/ describing its
+// tree, and refs.json counts how many manifest entries use each blob, so a
+// blob is only freed when its last reference goes. References are
+// manifest paths.
+type dedupStorage struct {
+	dir string
+}
+
+// dedupManifest describes one payload.
+type dedupManifest struct {
+	Entries []dedupEntry `json:"entries"`
+}
+
+// dedupEntry is one file, directory or symlink of a payload, in walk
+// order so that directories come before their contents.
+type dedupEntry struct {
+	Path     string      `json:"path"` // Slash-separated, "." for the payload root
+	Mode     os.FileMode `json:"mode"`
+	ModTime  time.Time   `json:"mtime"`
+	Link     string      `json:"link,omitempty"` // Symlink target
+	Hash     string      `json:"hash,omitempty"` // Blob of a regular file
+	Size     int64       `json:"size,omitempty"`
+	Sparse   bool        `json:"sparse,omitempty"`   // Restore zero blocks as holes
+	Hardlink string      `json:"hardlink,omitempty"` // Earlier entry sharing the inode, instead of Hash
+	Rdev     uint64      `json:"rdev,omitempty"`     // Device number of a device node
+	// Owner, times and xattrs, missing from manifests of older versions
+	Meta *fileMetadata `json:"meta,omitempty"`
+}
+
+func (s *dedupStorage) Name() string {
+	return StorageDedup
+}
+
+func (s *dedupStorage) Put(src, name string, report *CopyReport) (string, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	// Hash everything before touching src, so a failure leaves it intact
+	manifest, err := buildDedupManifest(src, report)
+	if err != nil {
+		return "", err
+	}
+	refs, err := s.loadRefs()
+	if err != nil {
+		return "", err
+	}
+
+	// Move new content into blobs, rolling back if one of them fails
+	var moved []int
+	var added []string
+	for i, entry := range manifest.Entries {
+		if entry.Hash == "" || refs[entry.Hash] > 0 {
+			continue
+		}
+		blob := s.blobPath(entry.Hash)
+		if _, err := os.Stat(blob); err == nil {
+			continue
+		}
+		file := filepath.Join(src, filepath.FromSlash(entry.Path))
+		info, err := os.Lstat(file)
+		if err != nil {
+			return "", s.putBack(src, manifest, moved, added, err)
+		}
+		if hasOtherLinks(info) {
+			// The inode lives on outside src: renaming it into a blob
+			// would let later edits of that file change the cache
+			hash, size, created, err := s.copyToBlob(file, report)
+			if err != nil {
+				return "", s.putBack(src, manifest, moved, added, fmt.Errorf("failed to store %s: %w", entry.Path, err))
+			}
+			manifest.Entries[i].Hash, manifest.Entries[i].Size = hash, size
+			if created {
+				added = append(added, s.blobPath(hash))
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+			return "", s.putBack(src, manifest, moved, added, err)
+		}
+		if err := moveFile(file, blob, report); err != nil {
+			return "", s.putBack(src, manifest, moved, added, fmt.Errorf("failed to store %s: %w", entry.Path, err))
+		}
+		moved = append(moved, i)
+		added = append(added, blob)
+		// Blobs are shared, their xattrs come from each manifest entry
+		clearXattrs(blob)
+	}
+
+	// src is only removed once the manifest is written, and refs.json only
+	// counts the payload once src is gone, so that until then a failure can
+	// put src back together and leave the counts as they were
+	ref := filepath.Join(s.dir, "manifests", name+".json")
+	if err := writeJSONFile(ref, manifest); err != nil {
+		os.Remove(ref)
+		return "", s.putBack(src, manifest, moved, added, err)
+	}
+	if err := os.RemoveAll(src); err != nil {
+		os.Remove(ref)
+		return "", s.putBack(src, manifest, moved, added, err)
+	}
+	for _, entry := range manifest.Entries {
+		if entry.Hash != "" {
+			refs[entry.Hash]++
+		}
+	}
+	if err := s.saveRefs(refs); err != nil {
+		os.Remove(ref)
+		return "", s.putBack(src, manifest, moved, added, err)
+	}
+	return ref, nil
+}
+
+// putBack undoes a Put that failed with cause half way: the moved files
+// return from their blobs, and anything else missing from src, such as
+// what RemoveAll got to, is rebuilt from the manifest. The moved files are
+// linked back rather than renamed, since other entries may need the same
+// blob, and the added blobs go once src is whole. It returns cause,
+// wrapping errNotPutBack if src could not be made whole again.
+func (s *dedupStorage) putBack(src string, manifest *dedupManifest, moved []int, added []string, cause error) error {
+	for _, i := range moved {
+		entry := manifest.Entries[i]
+		blob := s.blobPath(entry.Hash)
+		file := filepath.Join(src, filepath.FromSlash(entry.Path))
+		err := os.MkdirAll(filepath.Dir(file), 0700)
+		if err == nil && os.Link(blob, file) != nil {
+			err = copyBlob(blob, file, entry.Sparse, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("%v, and %w: %v", cause, errNotPutBack, err)
+		}
+		if entry.Meta != nil {
+			entry.Meta.apply(file, nil)
+		}
+	}
+	if err := s.restoreTree(manifest, nil, src, nil, true); err != nil {
+		return fmt.Errorf("%v, and %w: %v", cause, errNotPutBack, err)
+	}
+	for _, blob := range added {
+		os.Remove(blob)
+	}
+	return cause
+}
+
+// hasOtherLinks reports whether the file has hardlinks besides its path.
+// Those inside the payload are copied to blobs too, which is only slower.
+func hasOtherLinks(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Nlink > 1
+}
+
+// copyToBlob stores a copy of file as a blob. It returns the hash and size
+// of the bytes it wrote, which differ from the manifest if the file changed
+// since it was hashed, and whether the blob is new.
+func (s *dedupStorage) copyToBlob(file string, report *CopyReport) (string, int64, bool, error) {
+	src, err := os.Open(file)
+	if err != nil {
+		return "", 0, false, err
+	}
+	defer src.Close()
+	dir := filepath.Join(s.dir, "objects")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", 0, false, err
+	}
+	tmp, err := os.CreateTemp(dir, ".blob-*")
+	if err != nil {
+		return "", 0, false, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), report.reader(src))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, false, err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	blob := s.blobPath(sum)
+	if _, err := os.Stat(blob); err == nil {
+		return sum, size, false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		return "", 0, false, err
+	}
+	return sum, size, true, os.Rename(tmp.Name(), blob)
+}
+
+// buildDedupManifest walks src and hashes its regular files. Files
+// hardlinked to an earlier one refer to it instead, sockets are left out.
+func buildDedupManifest(src string, report *CopyReport) (*dedupManifest, error) {
+	manifest := &dedupManifest{}
+	links := hardlinks{}
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := report.err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		entry := dedupEntry{Path: filepath.ToSlash(rel), Mode: info.Mode(), ModTime: info.ModTime()}
+		if entry.Meta, err = readMetadata(path, report); err != nil {
+			return err
+		}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if entry.Link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case info.IsDir():
+		case info.Mode()&os.ModeSocket != 0:
+			if path == src {
+				return errSocket
+			}
+			report.skip(path)
+			return nil
+		default:
+			if first, seen := links.link(info, entry.Path); seen {
+				entry.Hardlink = first
+			} else if isSpecial(info.Mode()) {
+				entry.Rdev = uint64(info.Sys().(*syscall.Stat_t).Rdev)
+			} else {
+				if entry.Hash, err = hashFile(path); err != nil {
+					return err
+				}
+				entry.Size = info.Size()
+				entry.Sparse = isSparse(info)
+			}
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	})
+	return manifest, err
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (s *dedupStorage) Get(ref, dst string, report *CopyReport) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	manifest, err := loadDedupManifest(ref)
+	if err != nil {
+		return err
+	}
+	refs, err := s.loadRefs()
+	if err != nil {
+		return err
+	}
+
+	if err := s.restoreTree(manifest, refs, dst, report, false); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return s.release(ref, manifest, refs, 0)
+}
+
+// restoreTree recreates a payload at dst. Blobs still shared with other
+// references are copied; a blob used only by this entry is hard-linked,
+// since release is about to drop it anyway. To repair a partial tree,
+// entries already at dst are kept and every blob is copied.
+func (s *dedupStorage) restoreTree(manifest *dedupManifest, refs map[string]int, dst string, report *CopyReport, repair bool) error {
+	remaining := make(map[string]int)
+	for _, entry := range manifest.Entries {
+		if entry.Hash != "" {
+			remaining[entry.Hash]++
+		}
+	}
+
+	created := make([]bool, len(manifest.Entries))
+	for i, entry := range manifest.Entries {
+		if err := report.err(); err != nil {
+			return err
+		}
+		target := filepath.Join(dst, filepath.FromSlash(entry.Path))
+		if _, err := os.Lstat(target); repair && err == nil && !entry.Mode.IsDir() {
+			continue
+		}
+		switch {
+		case entry.Mode.IsDir():
+			if err := os.MkdirAll(target, entry.Mode.Perm()|0700); err != nil {
+				return err
+			}
+		case entry.Mode&os.ModeSymlink != 0:
+			if err := os.Symlink(entry.Link, target); err != nil {
+				return err
+			}
+		case entry.Hardlink != "":
+			if err := os.Link(filepath.Join(dst, filepath.FromSlash(entry.Hardlink)), target); err != nil {
+				return err
+			}
+		case isSpecial(entry.Mode):
+			err := makeNode(target, entry.Mode, entry.Rdev)
+			if errors.Is(err, os.ErrPermission) {
+				report.skip(target)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			blob := s.blobPath(entry.Hash)
+			remaining[entry.Hash]--
+			linked := false
+			if !repair && refs[entry.Hash] <= 1 && remaining[entry.Hash] == 0 {
+				linked = os.Link(blob, target) == nil
+			}
+			if !linked {
+				if err := copyBlob(blob, target, entry.Sparse, report); err != nil {
+					return err
+				}
+			}
+		}
+		created[i] = true
+	}
+
+	// Metadata goes last, children before their directory
+	for i := len(manifest.Entries) - 1; i >= 0; i-- {
+		entry := manifest.Entries[i]
+		target := filepath.Join(dst, filepath.FromSlash(entry.Path))
+		if !created[i] {
+			continue
+		}
+		if entry.Meta != nil {
+			entry.Meta.apply(target, report)
+		} else if entry.Mode&os.ModeSymlink == 0 {
+			os.Chmod(target, entry.Mode.Perm())
+			os.Chtimes(target, entry.ModTime, entry.ModTime)
+		}
+	}
+	return nil
+}
+
+// copyBlob copies the content of a blob to target. Zero blocks become
+// holes for sparse files whose blob was stored from a dense copy.
+func copyBlob(blob, target string, sparse bool, report *CopyReport) error {
+	src, err := os.Open(blob)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if sparse && !isSparse(info) {
+		err = writeSparse(dst, report.reader(src))
+		report.use(CopyReadWrite)
+	} else {
+		var method string
+		method, err = copyContent(dst, src, info, report)
+		report.use(method)
+	}
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func (s *dedupStorage) Delete(ref string, passes int) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	manifest, err := loadDedupManifest(ref)
+	if err != nil {
+		return err
+	}
+	refs, err := s.loadRefs()
+	if err != nil {
+		return err
+	}
+	return s.release(ref, manifest, refs, passes)
+}
+
+// release drops the references of a manifest and the manifest itself,
+// removing blobs nobody uses any more. Blobs still shared with other items
+// cannot be shredded, only the last reference overwrites them.
+func (s *dedupStorage) release(ref string, manifest *dedupManifest, refs map[string]int, passes int) error {
+	for _, entry := range manifest.Entries {
+		if entry.Hash == "" {
+			continue
+		}
+		refs[entry.Hash]--
+		if refs[entry.Hash] > 0 {
+			continue
+		}
+		delete(refs, entry.Hash)
+
+		blob := s.blobPath(entry.Hash)
+		var err error
+		if passes > 0 {
+			err = ShredPath(blob, passes)
+		} else {
+			err = os.Remove(blob)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if err := s.saveRefs(refs); err != nil {
+		return err
+	}
+	return os.Remove(ref)
+}
+
+func (s *dedupStorage) Stat(ref string) (StorageInfo, error) {
+	manifest, err := loadDedupManifest(ref)
+	if err != nil {
+		return StorageInfo{}, err
+	}
+	info := StorageInfo{Ref: ref}
+	for _, entry := range manifest.Entries {
+		if entry.Hash != "" {
+			info.Size += entry.Size
+			info.Files++
+		}
+	}
+	return info, nil
+}
+
+func (s *dedupStorage) List() ([]string, error) {
+	return filepath.Glob(filepath.Join(s.dir, "manifests", "*.json"))
+}
+
+func (s *dedupStorage) blobPath(hash string) string {
+	return filepath.Join(s.dir, "objects", hash[:2], hash)
+}
+
+// lock serialises changes to the reference counts across vx processes.
+func (s *dedupStorage) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Join(s.dir, "manifests"), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(s.dir, ".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() { file.Close() }, nil
+}
+
+func (s *dedupStorage) loadRefs() (map[string]int, error) {
+	refs := make(map[string]int)
+	data, err := os.ReadFile(filepath.Join(s.dir, "refs.json"))
+	if os.IsNotExist(err) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &refs); err != nil {
+		return nil, fmt.Errorf("failed to parse dedup reference counts: %v", err)
+	}
+	return refs, nil
+}
+
+func (s *dedupStorage) saveRefs(refs map[string]int) error {
+	return writeJSONFile(filepath.Join(s.dir, "refs.json"), refs)
+}
+
+func loadDedupManifest(ref string) (*dedupManifest, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &dedupManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filepath.Base(ref), err)
+	}
+	return manifest, nil
+}
+
+// writeJSONFile replaces path atomically with v encoded as JSON.
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// DedupUsage reports how much file content the deduplicated store holds
+// for its items (logical) and how much it actually keeps on disk (stored).
+// Both are zero when the store was never used.
+func DedupUsage(config types.Config) (logical, stored int64, err error) {
+	store := &dedupStorage{dir: filepath.Join(ExpandPath(config.Cache.Directory), "dedup")}
+	refs, err := store.loadRefs()
+	if err != nil {
+		return 0, 0, err
+	}
+	for hash, count := range refs {
+		info, err := os.Stat(store.blobPath(hash))
+		if err != nil {
+			continue
+		}
+		logical += int64(count) * info.Size()
+		stored += info.Size()
+	}
+	return logical, stored, nil
+}
@@ -0,0 +1,147 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDedupRefcounts(t *testing.T) {
+	dir := t.TempDir()
+	store := &dedupStorage{dir: filepath.Join(dir, "dedup")}
+	shared := "shared content"
+	writeTree(t, filepath.Join(dir, "a"), map[string]string{"x": shared, "sub/y": shared, "z": "only in a"})
+	writeTree(t, filepath.Join(dir, "b"), map[string]string{"x": shared})
+	sharedHash, err := hashFile(filepath.Join(dir, "b", "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	onlyHash, err := hashFile(filepath.Join(dir, "a", "z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRefs := func(step string, want map[string]int) {
+		t.Helper()
+		refs, err := store.loadRefs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(refs) != len(want) {
+			t.Errorf("%s: refs = %v, want %v", step, refs, want)
+		}
+		for hash, count := range want {
+			if refs[hash] != count {
+				t.Errorf("%s: refs[%.8s] = %d, want %d", step, hash, refs[hash], count)
+			}
+			if _, err := os.Stat(store.blobPath(hash)); err != nil {
+				t.Errorf("%s: blob %.8s: %v", step, hash, err)
+			}
+		}
+	}
+
+	refA, err := store.Put(filepath.Join(dir, "a"), "a", nil)
+	if err != nil {
+		t.Fatalf("Put a: %v", err)
+	}
+	refB, err := store.Put(filepath.Join(dir, "b"), "b", nil)
+	if err != nil {
+		t.Fatalf("Put b: %v", err)
+	}
+	for _, src := range []string{"a", "b"} {
+		if _, err := os.Lstat(filepath.Join(dir, src)); !os.IsNotExist(err) {
+			t.Errorf("%s is still there after Put: %v", src, err)
+		}
+	}
+	checkRefs("after Put", map[string]int{sharedHash: 3, onlyHash: 1})
+
+	if err := store.Get(refA, filepath.Join(dir, "a"), nil); err != nil {
+		t.Fatalf("Get a: %v", err)
+	}
+	checkTree(t, filepath.Join(dir, "a"), map[string]string{"x": shared, "sub/y": shared, "z": "only in a"})
+	checkRefs("after Get", map[string]int{sharedHash: 1})
+	if _, err := os.Stat(store.blobPath(onlyHash)); !os.IsNotExist(err) {
+		t.Errorf("the blob only a used is still there: %v", err)
+	}
+	if _, err := os.Stat(refA); !os.IsNotExist(err) {
+		t.Errorf("the manifest of a is still there: %v", err)
+	}
+
+	if err := store.Delete(refB, 0); err != nil {
+		t.Fatalf("Delete b: %v", err)
+	}
+	checkRefs("after Delete", map[string]int{})
+	if _, err := os.Stat(store.blobPath(sharedHash)); !os.IsNotExist(err) {
+		t.Errorf("the shared blob is still there once unused: %v", err)
+	}
+}
+
+func TestDedupPutRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	store := &dedupStorage{dir: filepath.Join(dir, "dedup")}
+	tree := map[string]string{"x": "one", "sub/y": "two", "sub/z": "one"}
+	writeTree(t, filepath.Join(dir, "a"), tree)
+
+	// refs.json cannot be replaced while its temporary file is a directory
+	if err := os.MkdirAll(filepath.Join(store.dir, "refs.json.tmp", "busy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put(filepath.Join(dir, "a"), "a", nil); err == nil {
+		t.Fatal("Put succeeded without saving the reference counts")
+	}
+
+	checkTree(t, filepath.Join(dir, "a"), tree)
+	if refs, err := store.loadRefs(); err != nil || len(refs) != 0 {
+		t.Errorf("refs = %v, %v after a failed Put, want none", refs, err)
+	}
+	if manifests, _ := store.List(); len(manifests) != 0 {
+		t.Errorf("manifests %v are left after a failed Put", manifests)
+	}
+	if blobs, _ := filepath.Glob(filepath.Join(store.dir, "objects", "*", "*")); len(blobs) != 0 {
+		t.Errorf("blobs %v are left after a failed Put", blobs)
+	}
+}
+
+func TestDedupPutCopiesLinkedFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := &dedupStorage{dir: filepath.Join(dir, "dedup")}
+	writeTree(t, filepath.Join(dir, "a"), map[string]string{"x": "linked", "y": "plain"})
+	outside := filepath.Join(dir, "outside")
+	if err := os.Link(filepath.Join(dir, "a", "x"), outside); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := store.Put(filepath.Join(dir, "a"), "a", nil)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	manifest, err := loadDedupManifest(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range manifest.Entries {
+		if entry.Path != "x" {
+			continue
+		}
+		blob, err := os.Stat(store.blobPath(entry.Hash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		live, err := os.Stat(outside)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if os.SameFile(blob, live) {
+			t.Error("the blob shares its inode with a file outside the payload")
+		}
+	}
+
+	// Editing the file left outside must not reach the cached copy
+	if err := os.WriteFile(outside, []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Get(ref, filepath.Join(dir, "a"), nil); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	checkTree(t, filepath.Join(dir, "a"), map[string]string{"x": "linked", "y": "plain"})
+}
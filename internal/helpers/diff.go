@@ -0,0 +1,351 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"vanish/internal/types"
+)
+
+// --- Diffing Cached Items ---
+
+const (
+	// diffMaxText is the largest file compared line by line.
+	diffMaxText = 1 << 20
+	// diffMaxCells bounds the line comparison table of a text diff.
+	diffMaxCells = 4 << 20
+	// diffContext is the number of unchanged lines around each change.
+	diffContext = 3
+)
+
+// ItemDiff compares a cached item with what is now at its original path.
+// Lines starting with "-" describe the cached copy, lines starting with
+// "+" what is on disk, and "~" marks directory entries present in both
+// that differ.
+type ItemDiff struct {
+	Summary string
+	Lines   []string
+}
+
+// DiffItem compares the cached payload of item with what is now at its
+// original path, so a restore can be weighed before it is made. Only
+// payloads kept as plain entries of the cache directory can be read.
+func DiffItem(item types.DeletedItem, config types.Config) (ItemDiff, error) {
+	if item.Compressed || StorageFor(item, config).Name() != StorageDirectory {
+		return ItemDiff{}, fmt.Errorf("the payload is %s and cannot be compared in place", payloadKind(item))
+	}
+	cached, err := os.Lstat(item.CachePath)
+	if err != nil {
+		return ItemDiff{}, fmt.Errorf("cached file not found: %s", item.CachePath)
+	}
+	current, err := os.Lstat(item.OriginalPath)
+	if os.IsNotExist(err) {
+		return ItemDiff{Summary: "Nothing is at the original path, a restore puts the cached copy back as it was"}, nil
+	}
+	if err != nil {
+		return ItemDiff{}, err
+	}
+
+	if kind, now := entryKind(cached.Mode()), entryKind(current.Mode()); kind != now {
+		return ItemDiff{Summary: fmt.Sprintf("The cached %s is now a %s on disk", kind, now)}, nil
+	}
+	switch {
+	case cached.Mode()&os.ModeSymlink != 0:
+		return diffSymlinks(item.CachePath, item.OriginalPath)
+	case cached.IsDir():
+		return diffTrees(item.CachePath, item.OriginalPath)
+	default:
+		return diffFiles(item.CachePath, item.OriginalPath)
+	}
+}
+
+// payloadKind describes how an item's payload is stored.
+func payloadKind(item types.DeletedItem) string {
+	if item.Compressed {
+		return "compressed"
+	}
+	return "in " + item.Storage + " storage"
+}
+
+// entryKind names the type of a directory entry.
+func entryKind(mode fs.FileMode) string {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode.IsDir():
+		return "directory"
+	case mode.IsRegular():
+		return "file"
+	}
+	return "special file"
+}
+
+func diffSymlinks(cached, current string) (ItemDiff, error) {
+	was, err := os.Readlink(cached)
+	if err != nil {
+		return ItemDiff{}, err
+	}
+	now, err := os.Readlink(current)
+	if err != nil {
+		return ItemDiff{}, err
+	}
+	if was == now {
+		return ItemDiff{Summary: "Identical to the symlink at the original path"}, nil
+	}
+	return ItemDiff{
+		Summary: "The symlink points elsewhere now",
+		Lines:   []string{"-" + was, "+" + now},
+	}, nil
+}
+
+func diffFiles(cached, current string) (ItemDiff, error) {
+	same, err := sameContent(cached, current)
+	if err != nil {
+		return ItemDiff{}, err
+	}
+	if same {
+		return ItemDiff{Summary: "Identical to the file at the original path"}, nil
+	}
+
+	was, wasText := readText(cached)
+	now, nowText := readText(current)
+	if !wasText || !nowText {
+		return ItemDiff{Summary: "The content differs, binary or too large to compare line by line"}, nil
+	}
+	a, b := splitLines(was), splitLines(now)
+	if len(a)*len(b) > diffMaxCells {
+		return ItemDiff{Summary: "The content differs, too many lines to compare"}, nil
+	}
+	lines := diffLines(a, b)
+	return ItemDiff{Summary: fmt.Sprintf("The content differs (%d cached lines, %d on disk)", len(a), len(b)), Lines: lines}, nil
+}
+
+// diffTrees lists the entries only in the cached tree, only on disk, or
+// in both but different.
+func diffTrees(cached, current string) (ItemDiff, error) {
+	was, err := treeEntries(cached)
+	if err != nil {
+		return ItemDiff{}, err
+	}
+	now, err := treeEntries(current)
+	if err != nil {
+		return ItemDiff{}, err
+	}
+
+	var names []string
+	for name := range was {
+		names = append(names, name)
+	}
+	for name := range now {
+		if _, ok := was[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var lines []string
+	var removed, added, changed int
+	for _, name := range names {
+		wasMode, inCache := was[name]
+		nowMode, onDisk := now[name]
+		switch {
+		case !onDisk:
+			removed++
+			lines = append(lines, "-"+name)
+		case !inCache:
+			added++
+			lines = append(lines, "+"+name)
+		case entryKind(wasMode) != entryKind(nowMode):
+			changed++
+			lines = append(lines, fmt.Sprintf("~%s (%s, now %s)", name, entryKind(wasMode), entryKind(nowMode)))
+		case wasMode.IsRegular():
+			same, err := sameContent(filepath.Join(cached, name), filepath.Join(current, name))
+			if err != nil || !same {
+				changed++
+				lines = append(lines, "~"+name)
+			}
+		case wasMode&os.ModeSymlink != 0:
+			a, _ := os.Readlink(filepath.Join(cached, name))
+			b, _ := os.Readlink(filepath.Join(current, name))
+			if a != b {
+				changed++
+				lines = append(lines, fmt.Sprintf("~%s (-> %s, now -> %s)", name, a, b))
+			}
+		}
+	}
+
+	if len(lines) == 0 {
+		return ItemDiff{Summary: "Identical to the directory at the original path"}, nil
+	}
+	summary := fmt.Sprintf("%d only in the cache, %d only on disk, %d changed", removed, added, changed)
+	return ItemDiff{Summary: summary, Lines: lines}, nil
+}
+
+// treeEntries returns the type of every entry below root, by relative path.
+func treeEntries(root string) (map[string]fs.FileMode, error) {
+	entries := make(map[string]fs.FileMode)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		entries[rel] = d.Type()
+		return nil
+	})
+	return entries, err
+}
+
+// sameContent reports whether two regular files hold the same bytes.
+func sameContent(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	sa, err := fa.Stat()
+	if err != nil {
+		return false, err
+	}
+	sb, err := fb.Stat()
+	if err != nil {
+		return false, err
+	}
+	if sa.Size() != sb.Size() {
+		return false, nil
+	}
+
+	bufA, bufB := make([]byte, 64<<10), make([]byte, 64<<10)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
+
+// readText returns the content of a small UTF-8 file without NUL bytes,
+// and false for anything else.
+func readText(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > diffMaxText {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", false
+	}
+	return string(data), true
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines returns a unified diff of a and b, without file headers.
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Walk the table into one edit per line, remembering the line numbers
+	type edit struct {
+		op           byte
+		text         string
+		aLine, bLine int
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', a[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	// Group changes with their context into hunks
+	var lines []string
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+		from := max(0, start-diffContext)
+		end := start
+		for k := start; k < len(edits); k++ {
+			if edits[k].op != ' ' {
+				end = k
+			} else if k-end > 2*diffContext {
+				break
+			}
+		}
+		to := min(len(edits), end+diffContext+1)
+
+		var aCount, bCount int
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				aCount++
+			}
+			if e.op != '-' {
+				bCount++
+			}
+		}
+		lines = append(lines, fmt.Sprintf("@@ -%d,%d +%d,%d @@", edits[from].aLine+1, aCount, edits[from].bLine+1, bCount))
+		for _, e := range edits[from:to] {
+			lines = append(lines, string(e.op)+e.text)
+		}
+		start = to
+	}
+	return lines
+}
@@ -2,13 +2,14 @@
 package helpers
 
 import (
+	"errors"
 	"fmt"
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"golang.org/x/term"
-	"io"
 	// "log"
+	"math"
 	"os"
 	// "os/exec"
 	"path/filepath"
@@ -43,6 +44,57 @@ func FormatBytes(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+// ParseSize parses a human-readable size such as "20GB", "512 MiB" or
+// "1.5G" into bytes. Units are powers of 1024 to match FormatBytes, and a
+// bare number is taken as bytes. Sizes that do not fit in an int64 are
+// refused.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	if value == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	unitStart := len(value)
+	for unitStart > 0 && (value[unitStart-1] < '0' || value[unitStart-1] > '9') && value[unitStart-1] != '.' {
+		unitStart--
+	}
+	number, unit := strings.TrimSpace(value[:unitStart]), strings.TrimSpace(value[unitStart:])
+	unit = strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "I")
+
+	// A whole number of bytes is exact, floats only hold 53 bits of it
+	if unit == "" && !strings.Contains(number, ".") {
+		n, err := strconv.ParseInt(number, 10, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, fmt.Errorf("size too large: %q", s)
+		}
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid size: %q", s)
+		}
+		return n, nil
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+
+	exp := 0
+	if unit != "" {
+		exp = strings.Index("KMGTPE", unit) + 1
+		if exp == 0 || len(unit) > 1 {
+			return 0, fmt.Errorf("invalid size unit in %q", s)
+		}
+	}
+	for i := 0; i < exp; i++ {
+		n *= 1024
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, which no longer fits
+	if n >= math.MaxInt64 {
+		return 0, fmt.Errorf("size too large: %q", s)
+	}
+	return int64(n), nil
+}
+
 // SendNotification sends a desktop notification based on the provided title and message.
 // It only sends notifications if the corresponding flags are enabled in the config.
 // It's tested on only Linux tho it should also work on macOS, and Windows platforms.
@@ -229,23 +281,65 @@ func ExpandPath(path string) string {
 
 // ClearAllCache removes all cached files and directories, recreates the
 // cache directory, resets the index, and logs the operation if logging
-// is enabled. Returns a tea.Msg with any error encountered.
-func ClearAllCache(config types.Config) tea.Cmd {
+// is enabled. Pinned items are kept unless includePinned is set.
+// Returns a tea.Msg with any error encountered.
+func ClearAllCache(config types.Config, includePinned bool) tea.Cmd {
 	return func() tea.Msg {
 		cacheDir := ExpandPath(config.Cache.Directory)
-
-		// Remove all files in cache directory
-		if err := os.RemoveAll(cacheDir); err != nil {
+		unlock, err := lockIndex(config)
+		if err != nil {
 			return types.ClearMsg{Err: err}
 		}
+		defer unlock()
 
-		// Recreate cache directory
-		if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		if !includePinned {
+			index, err := LoadIndex(config)
+			if err != nil {
+				return types.ClearMsg{Err: err}
+			}
+			var pinnedItems []types.DeletedItem
+			for _, item := range index.Items {
+				if item.IsPinned() {
+					pinnedItems = append(pinnedItems, item)
+				}
+			}
+			if len(pinnedItems) > 0 {
+				return clearUnpinned(index, pinnedItems, config)
+			}
+		}
+
+		// Shred the payloads that need it, and delete the remote ones,
+		// before dropping the directory
+		index, err := LoadIndex(config)
+		if err == nil {
+			for _, item := range index.Items {
+				if ShouldShred(item, config) || item.IsRemote() {
+					if err := RemoveCachedItem(item, config); err != nil && !os.IsNotExist(err) {
+						return types.ClearMsg{Err: err}
+					}
+				}
+			}
+		}
+
+		// Remove all files in cache directory, but the locks and the
+		// history, which outlives the items it counts
+		entries, err := os.ReadDir(cacheDir)
+		if err != nil && !os.IsNotExist(err) {
 			return types.ClearMsg{Err: err}
 		}
+		for _, entry := range entries {
+			path := filepath.Join(cacheDir, entry.Name())
+			if entry.Name() == indexLock || entry.Name() == historyLock || path == GetHistoryPath(config) {
+				continue
+			}
+			if err := os.RemoveAll(path); err != nil {
+				return types.ClearMsg{Err: err}
+			}
+		}
+		recordHistory(HistoryPurged, index.Items, config)
 
 		// Create empty index
-		index := types.Index{Items: []types.DeletedItem{}}
+		index = types.Index{Items: []types.DeletedItem{}}
 		if err := SaveIndex(index, config); err != nil {
 			return types.ClearMsg{Err: err}
 		}
@@ -270,114 +364,189 @@ func ClearAllCache(config types.Config) tea.Cmd {
 	}
 }
 
-// PurgeOldFiles removes cached files and directories that are older than
-// the specified number of days. Updates the index and logs each purge
-// if logging is enabled. Returns a tea.Msg containing the purge results.
-func PurgeOldFiles(config types.Config, daysStr string) tea.Cmd {
-	return func() tea.Msg {
-		days, err := strconv.Atoi(daysStr)
-		if err != nil {
-			return types.PurgeMsg{Err: fmt.Errorf("invalid days value: %s", daysStr)}
+// clearUnpinned removes every unpinned item one by one so that the pinned
+// payloads, which share the cache directory, survive a clear.
+func clearUnpinned(index types.Index, pinnedItems []types.DeletedItem, config types.Config) types.ClearMsg {
+	var cleared []types.DeletedItem
+	for _, item := range index.Items {
+		if !item.IsPinned() {
+			if err := RemoveCachedItem(item, config); err != nil && !os.IsNotExist(err) {
+				return types.ClearMsg{Err: err}
+			}
+			cleared = append(cleared, item)
 		}
+	}
+	recordHistory(HistoryPurged, cleared, config)
 
-		cutoffDays := time.Duration(days) * 24 * time.Hour
-		cutoff := time.Now().Add(-cutoffDays)
+	index.Items = pinnedItems
+	if err := SaveIndex(index, config); err != nil {
+		return types.ClearMsg{Err: err}
+	}
 
-		index, err := LoadIndex(config)
-		if err != nil {
-			return types.PurgeMsg{Err: fmt.Errorf("error loading index: %v", err)}
-		}
+	LogSimpleOperation("CLEAR_ALL", fmt.Sprintf("Cache cleared, kept %d pinned item(s)", len(pinnedItems)), config)
+	return types.ClearMsg{KeptPinned: len(pinnedItems)}
+}
 
-		var remainingItems []types.DeletedItem
-		purgedCount := 0
+// PurgeDeletedBefore removes unpinned items deleted before cutoff from the
+// cache and the index, logging each one as PURGE. An item whose payload
+// cannot be removed stays in the index. It returns the removed items.
+func PurgeDeletedBefore(cutoff time.Time, config types.Config) ([]types.DeletedItem, error) {
+	unlock, err := lockIndex(config)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
-		for _, item := range index.Items {
-			if item.DeleteDate.Before(cutoff) {
-				// Remove the actual file or directory
-				if item.IsDirectory {
-					os.RemoveAll(item.CachePath)
-				} else {
-					os.Remove(item.CachePath)
-				}
-				purgedCount++
+	index, err := LoadIndex(config)
+	if err != nil {
+		return nil, fmt.Errorf("error loading index: %v", err)
+	}
 
-				// Log purge
-				if config.Logging.Enabled {
-					LogOperation("PURGE", item, config)
-				}
-			} else {
+	var remainingItems, purgedItems []types.DeletedItem
+	for _, item := range index.Items {
+		if item.DeleteDate.Before(cutoff) && !item.IsPinned() {
+			// Remove the actual file or directory
+			if err := RemoveCachedItem(item, config); err != nil && !os.IsNotExist(err) {
+				// Keep the entry so the payload left behind is not orphaned
+				LogSimpleOperation("ERROR", fmt.Sprintf("Failed to purge %s: %v", item.OriginalPath, err), config)
 				remainingItems = append(remainingItems, item)
+				continue
 			}
-		}
+			purgedItems = append(purgedItems, item)
 
-		// Update index
-		index.Items = remainingItems
-		if err := SaveIndex(index, config); err != nil {
-			return types.PurgeMsg{Err: fmt.Errorf("error updating index: %v", err)}
+			// Log purge
+			if config.Logging.Enabled {
+				LogOperation("PURGE", item, config)
+			}
+		} else {
+			remainingItems = append(remainingItems, item)
 		}
+	}
+	recordHistory(HistoryPurged, purgedItems, config)
 
-		return types.PurgeMsg{PurgedCount: purgedCount, Err: nil}
+	// Update index
+	index.Items = remainingItems
+	if err := SaveIndex(index, config); err != nil {
+		return purgedItems, fmt.Errorf("error updating index: %v", err)
 	}
+	return purgedItems, nil
 }
 
-// CheckRestoreItems searches the index for deleted items that match
-// any of the given patterns (case-insensitive substring match).
-// Returns a tea.Msg containing the matched items.
-func CheckRestoreItems(patterns []string, config types.Config) tea.Cmd {
-	return func() tea.Msg {
-		index, err := LoadIndex(config)
-		if err != nil {
-			return types.ErrorMsg(fmt.Sprintf("Error loading index: %v", err))
-		}
+// PurgeItems removes the unpinned items with the given IDs from the cache
+// and the index, logging each one as PURGE. It returns the removed items.
+func PurgeItems(ids []string, config types.Config) ([]types.DeletedItem, error) {
+	unlock, err := lockIndex(config)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
-		var matchingItems []types.DeletedItem
-		for _, pattern := range patterns {
-			for _, item := range index.Items {
-				// Simple pattern matching - check if pattern is contained in original path
-				if strings.Contains(strings.ToLower(item.OriginalPath), strings.ToLower(pattern)) {
-					matchingItems = append(matchingItems, item)
-				}
-			}
+	index, err := LoadIndex(config)
+	if err != nil {
+		return nil, fmt.Errorf("error loading index: %v", err)
+	}
+
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+
+	var remainingItems, purgedItems []types.DeletedItem
+	for _, item := range index.Items {
+		if !selected[item.ID] || item.IsPinned() {
+			remainingItems = append(remainingItems, item)
+			continue
 		}
+		if err := RemoveCachedItem(item, config); err != nil && item.IsRemote() && !os.IsNotExist(err) {
+			// Keep the entry so the bucket object is not orphaned
+			LogSimpleOperation("ERROR", fmt.Sprintf("Failed to delete remote copy of %s: %v", item.OriginalPath, err), config)
+			remainingItems = append(remainingItems, item)
+			continue
+		}
+		purgedItems = append(purgedItems, item)
+		if config.Logging.Enabled {
+			LogOperation("PURGE", item, config)
+		}
+	}
+	if len(purgedItems) == 0 {
+		return nil, nil
+	}
+	recordHistory(HistoryPurged, purgedItems, config)
 
-		return types.RestoreItemsMsg{Items: matchingItems}
+	index.Items = remainingItems
+	if err := SaveIndex(index, config); err != nil {
+		return purgedItems, fmt.Errorf("error updating index: %v", err)
 	}
+	return purgedItems, nil
+}
+
+// RemoveCachedItem deletes the cached payload of an item from its storage
+// backend, shredding it first when the item is sensitive or cache.shred is
+// set.
+func RemoveCachedItem(item types.DeletedItem, config types.Config) error {
+	passes := 0
+	if ShouldShred(item, config) {
+		passes = ShredPasses(config)
+	}
+	return StorageFor(item, config).Delete(item.CachePath, passes)
 }
 
 // CheckFilesExist checks if the specified files or directories exist on disk,
-// gathers metadata about each, and returns a tea.Msg with the results.
-func CheckFilesExist(filenames []string) tea.Cmd {
+// gathers metadata about each including its safety status, and returns a
+// tea.Msg with the results.
+func CheckFilesExist(filenames []string, config types.Config) tea.Cmd {
 	return func() tea.Msg {
-		fileInfos := make([]types.FileInfo, len(filenames))
+		return types.FilesExistMsg{FileInfos: InspectPaths(filenames, config)}
+	}
+}
 
-		for i, filename := range filenames {
-			stat, err := os.Stat(filename)
-			if err != nil {
-				fileInfos[i] = types.FileInfo{
-					Path:   filename,
-					Exists: false,
-					Error:  err.Error(),
-				}
-				continue
+// InspectPaths gathers what CheckFilesExist reports about each path.
+func InspectPaths(filenames []string, config types.Config) []types.FileInfo {
+	fileInfos := make([]types.FileInfo, len(filenames))
+
+	for i, filename := range filenames {
+		stat, err := os.Stat(filename)
+		if err != nil {
+			fileInfos[i] = types.FileInfo{
+				Path:   filename,
+				Exists: false,
+				Error:  err.Error(),
 			}
+			continue
+		}
 
-			isDir := stat.IsDir()
-			fileCount := 0
+		isDir := stat.IsDir()
+		fileCount := 0
 
-			if isDir {
-				fileCount, _ = CountFilesInDirectory(filename)
-			}
+		// Check safety first so refused targets like / are never walked
+		refused, protected, reason := CheckPathSafety(filename, config)
 
-			fileInfos[i] = types.FileInfo{
-				Path:        filename,
-				IsDirectory: isDir,
-				FileCount:   fileCount,
-				Exists:      true,
-			}
+		size := stat.Size()
+		if isDir && !refused {
+			fileCount, _ = CountFilesInDirectory(filename)
+			size, _ = GetDirectorySize(filename)
+		}
+
+		var rule *types.RetentionRule
+		if linkInfo, err := os.Lstat(filename); err == nil {
+			rule = MatchRetentionRule(filename, linkInfo, size, config)
 		}
 
-		return types.FilesExistMsg{FileInfos: fileInfos}
+		fileInfos[i] = types.FileInfo{
+			Path:        filename,
+			IsDirectory: isDir,
+			FileCount:   fileCount,
+			Size:        size,
+			Rule:        rule,
+			Permanent:   IsPermanentPath(filename, config) || (rule != nil && rule.SkipCache),
+			Exists:      !refused,
+			Protected:   protected,
+			Refused:     refused,
+			Reason:      reason,
+		}
 	}
+
+	return fileInfos
 }
 
 // CountFilesInDirectory returns the number of files (not including directories)
@@ -385,11 +554,11 @@ func CheckFilesExist(filenames []string) tea.Cmd {
 // the directory tree are ignored.
 func CountFilesInDirectory(dir string) (int, error) {
 	count := 0
-	err := filepath.Walk(dir, func(path string, _ os.FileInfo, err error) error {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // skips problematic files
 		}
-		if path != dir {
+		if !info.IsDir() {
 			count++
 		}
 		return nil
@@ -426,6 +595,12 @@ func FindNextValidFile(fileInfos []types.FileInfo, startIndex int) int {
 // MoveFile moves a file from the source path to the destination path.
 // It handles regular files, symlinks, and special files appropriately.
 func MoveFile(src, dst string) error {
+	return moveFile(src, dst, nil)
+}
+
+// moveFile is MoveFile recording in report what a cross-filesystem copy
+// could not preserve and how it copied the content.
+func moveFile(src, dst string, report *CopyReport) error {
 	// Check if it's a symlink first (before opening)
 	isSymlink, err := IsSymlink(src)
 	if err != nil {
@@ -433,36 +608,27 @@ func MoveFile(src, dst string) error {
 	}
 
 	if isSymlink {
-		return MoveSymlink(src, dst)
+		return moveSymlink(src, dst, report)
 	}
 
-	// For regular files, use the copy approach
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
+	// Use os.Rename when possible (same filesystem), it needs no extra space
+	// and keeps all metadata
+	if err := os.Rename(src, dst); err == nil {
+		return nil
 	}
-	defer sourceFile.Close()
 
-	destFile, err := os.Create(dst)
+	// For cross-filesystem moves, use the copy approach
+	info, err := os.Lstat(src)
 	if err != nil {
 		return err
 	}
-	defer destFile.Close()
-
-	// Copy file contents
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		return err
+	if isSpecial(info.Mode()) {
+		err = copySpecial(src, dst, info, report)
+	} else {
+		err = copyFile(src, dst, report)
 	}
-
-	// Get source file permissions
-	srcInfo, err := sourceFile.Stat()
 	if err != nil {
-		return err
-	}
-
-	// Set destination file permissions
-	if err := destFile.Chmod(srcInfo.Mode()); err != nil {
+		os.Remove(dst)
 		return err
 	}
 
@@ -474,13 +640,19 @@ func MoveFile(src, dst string) error {
 // using os.Rename first, and falls back to a copy-and-remove approach
 // if that fails. Properly handles symlinks within directories.
 func MoveDirectory(src, dst string) error {
+	return moveDirectory(src, dst, nil)
+}
+
+func moveDirectory(src, dst string, report *CopyReport) error {
 	// Use os.Rename for atomic operation when possible (same filesystem)
 	if err := os.Rename(src, dst); err == nil {
 		return nil
 	}
 
-	// Fallback to copy + remove for cross-filesystem moves
-	if err := CopyDirectory(src, dst); err != nil {
+	// Fallback to copy + remove for cross-filesystem moves, dropping a
+	// partial copy so that a failed or cancelled move leaves src alone
+	if err := copyDirectory(src, dst, report); err != nil {
+		os.RemoveAll(dst)
 		return err
 	}
 
@@ -488,17 +660,29 @@ func MoveDirectory(src, dst string) error {
 }
 
 // CopyDirectory recursively copies the contents of the source directory to the
-// destination directory. Preserves file and directory modes. Returns an error
-// if any operation fails.
+// destination directory. Preserves modes, ownership when permitted, times
+// and extended attributes, hardlinks within the directory, holes of sparse
+// files, FIFOs and device nodes. Sockets, and device nodes when not running
+// as root, are left out. Returns an error if any operation fails.
 func CopyDirectory(src, dst string) error {
-	// Use Lstat to not follow symlinks when checking source
-	srcInfo, err := os.Lstat(src)
+	return copyDirectory(src, dst, nil)
+}
+
+// copyDirectory is CopyDirectory recording in report what it could not
+// preserve, including the special files it left out.
+func copyDirectory(src, dst string, report *CopyReport) error {
+	return copyTree(src, dst, hardlinks{}, report)
+}
+
+func copyTree(src, dst string, links hardlinks, report *CopyReport) error {
+	// Read metadata first, listing the directory updates its access time
+	meta, err := readMetadata(src, report)
 	if err != nil {
 		return err
 	}
 
 	// Create destination directory
-	if err := os.MkdirAll(dst, srcInfo.Mode()); err != nil {
+	if err := os.MkdirAll(dst, meta.Mode.Perm()|0700); err != nil {
 		return err
 	}
 
@@ -508,78 +692,111 @@ func CopyDirectory(src, dst string) error {
 	}
 
 	for _, entry := range entries {
+		if err := report.err(); err != nil {
+			return err
+		}
 		srcPath := filepath.Join(src, entry.Name())
 		dstPath := filepath.Join(dst, entry.Name())
 
-		// Check if entry is a symlink
-		isSymlink, err := IsSymlink(srcPath)
+		info, err := os.Lstat(srcPath)
 		if err != nil {
 			return err
 		}
 
-		if isSymlink {
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
 			// Handle symlink
-			linkTarget, err := os.Readlink(srcPath)
-			if err != nil {
-				return fmt.Errorf("failed to read symlink %s: %w", srcPath, err)
-			}
-			if err := os.Symlink(linkTarget, dstPath); err != nil {
-				return fmt.Errorf("failed to create symlink %s: %w", dstPath, err)
-			}
-		} else if entry.IsDir() {
+			err = copySymlink(srcPath, dstPath, report)
+		case info.IsDir():
 			// Handle directory
-			if err := CopyDirectory(srcPath, dstPath); err != nil {
-				return err
+			err = copyTree(srcPath, dstPath, links, report)
+		case isSpecial(info.Mode()):
+			// FIFOs and device nodes are recreated, sockets and devices
+			// that need root are reported and left out
+			err = copySpecial(srcPath, dstPath, info, report)
+			if errors.Is(err, errSocket) || errors.Is(err, os.ErrPermission) {
+				report.skip(srcPath)
+				err = nil
 			}
-		} else {
-			// Handle regular file
-			if err := CopyFile(srcPath, dstPath); err != nil {
-				return err
+		default:
+			// Handle regular file, linking it to an earlier copy of the
+			// same inode
+			if first, seen := links.link(info, dstPath); seen {
+				if err = os.Link(first, dstPath); err == nil {
+					continue
+				}
 			}
+			err = copyFile(srcPath, dstPath, report)
+		}
+		if err != nil {
+			return err
 		}
 	}
 
+	// Creating the children changed the times, apply metadata last
+	meta.apply(dst, report)
 	return nil
 }
 
-// CopyFile copies a file from src to dst, preserving its permissions.
+// CopyFile copies a file from src to dst, preserving its permissions,
+// ownership when permitted, times, extended attributes and holes. The
+// content is reflinked where the filesystem supports it, otherwise copied
+// in the kernel with copy_file_range, and only then in userspace.
 // Returns an error if opening, copying, or creating fails.
 // Does not follow symlinks - use MoveSymlink for that.
 func CopyFile(src, dst string) error {
+	return copyFile(src, dst, nil)
+}
+
+func copyFile(src, dst string, report *CopyReport) error {
+	// Read metadata first, copying the content updates the access time
+	meta, err := readMetadata(src, report)
+	if err != nil {
+		return err
+	}
+
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer srcFile.Close()
 
-	srcInfo, err := srcFile.Stat()
+	info, err := srcFile.Stat()
 	if err != nil {
 		return err
 	}
 
-	dstFile, err := os.Create(dst)
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
-	defer dstFile.Close()
 
-	if err := dstFile.Chmod(srcInfo.Mode()); err != nil {
+	// Reflink, copy_file_range or plain copy, keeping holes
+	method, err := copyContent(dstFile, srcFile, info, report)
+	if err != nil {
+		dstFile.Close()
+		return err
+	}
+	report.use(method)
+	if err := dstFile.Close(); err != nil {
 		return err
 	}
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	meta.apply(dst, report)
+	return nil
 }
 
 // GetDirectorySize returns the total size in bytes of all non-directory
-// files within the specified directory and its subdirectories.
+// files within the specified directory and its subdirectories. Files
+// hardlinked to each other are counted once.
 func GetDirectorySize(dir string) (int64, error) {
 	var size int64
-	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+	links := hardlinks{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
-		if !info.IsDir() {
+		if _, seen := links.link(info, path); !info.IsDir() && !seen {
 			size += info.Size()
 		}
 		return nil
@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"512", 512, false},
+		{"1KB", 1 << 10, false},
+		{"512 MiB", 512 << 20, false},
+		{"1.5G", 3 << 29, false},
+		{" 20gb ", 20 << 30, false},
+		{"7E", 7 << 60, false},
+		{"8E", 0, true},
+		{"1E30", 0, true},
+		{"9223372036854775807", math.MaxInt64, false},
+		{"9223372036854775808", 0, true},
+		{"100000P", 0, true},
+		{"", 0, true},
+		{"-1K", 0, true},
+		{"12XB", 0, true},
+	}
+	for _, test := range tests {
+		got, err := ParseSize(test.in)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d (error %v)", test.in, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestCountFilesInDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a": "1", "sub/b": "2", "sub/deeper/c": "3"})
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := CountFilesInDirectory(dir); err != nil || got != 4 {
+		t.Errorf("CountFilesInDirectory = %d, %v, want 4 files", got, err)
+	}
+}
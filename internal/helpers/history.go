@@ -0,0 +1,240 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"vanish/internal/types"
+)
+
+// --- Operation History ---
+
+// Events counted by the history.
+const (
+	HistoryDeleted  = "deleted"
+	HistoryRestored = "restored"
+	HistoryPurged   = "purged"
+)
+
+// HistoryDate is the layout of the day keys of the history.
+const HistoryDate = "2006-01-02"
+
+const (
+	// historyDays is how many days of history are kept.
+	historyDays = 400
+	// historyTop is how many directories and extensions are kept for each
+	// past day. The current day keeps them all until it is over.
+	historyTop = 10
+)
+
+// historyMu serialises the updates of the history made by items deleted
+// and restored in parallel. lockHistory extends it to other vx processes.
+var historyMu sync.Mutex
+
+// historyLock is the lock file of the history, next to history.json.
+const historyLock = "history.lock"
+
+// GetHistoryPath returns the path of history.json, next to the index.
+func GetHistoryPath(config types.Config) string {
+	return filepath.Join(ExpandPath(config.Cache.Directory), "history.json")
+}
+
+// LoadHistory reads the history of the cache. A missing file is an empty
+// history.
+func LoadHistory(config types.Config) (types.History, error) {
+	history := types.History{Days: make(map[string]*types.HistoryDay)}
+	data, err := os.ReadFile(GetHistoryPath(config))
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return history, err
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		return history, err
+	}
+	if history.Days == nil {
+		history.Days = make(map[string]*types.HistoryDay)
+	}
+	return history, nil
+}
+
+// lockHistory serialises a load-modify-save of the history with the other
+// goroutines and vx processes updating it, like lockIndex for the index.
+func lockHistory(config types.Config) (func(), error) {
+	historyMu.Lock()
+	cacheDir := ExpandPath(config.Cache.Directory)
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		historyMu.Unlock()
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(cacheDir, historyLock), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		historyMu.Unlock()
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		historyMu.Unlock()
+		return nil, err
+	}
+	return func() {
+		file.Close()
+		historyMu.Unlock()
+	}, nil
+}
+
+func saveHistory(history types.History, config types.Config) error {
+	path := GetHistoryPath(config)
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// recordHistory counts items under event for today. The history is only
+// bookkeeping, so a failure is logged and never fails the operation.
+func recordHistory(event string, items []types.DeletedItem, config types.Config) {
+	if len(items) == 0 {
+		return
+	}
+	unlock, err := lockHistory(config)
+	if err != nil {
+		LogSimpleOperation("ERROR", fmt.Sprintf("Failed to lock the history: %v", err), config)
+		return
+	}
+	defer unlock()
+
+	history, err := LoadHistory(config)
+	if err != nil {
+		LogSimpleOperation("ERROR", fmt.Sprintf("Failed to read the history: %v", err), config)
+		return
+	}
+	now := time.Now()
+	if history.Since.IsZero() {
+		history.Since = now
+	}
+	day := history.Days[now.Format(HistoryDate)]
+	if day == nil {
+		day = &types.HistoryDay{}
+		history.Days[now.Format(HistoryDate)] = day
+	}
+
+	for _, item := range items {
+		switch event {
+		case HistoryDeleted:
+			addCount(&day.Deleted, item)
+			addCount(&history.Total.Deleted, item)
+			if day.Dirs == nil {
+				day.Dirs = make(map[string]types.HistoryCount)
+				day.Exts = make(map[string]types.HistoryCount)
+			}
+			dir, ext := filepath.Dir(item.OriginalPath), ItemExtension(item)
+			day.Dirs[dir] = addedCount(day.Dirs[dir], item)
+			day.Exts[ext] = addedCount(day.Exts[ext], item)
+		case HistoryRestored:
+			delay := int64(now.Sub(item.DeleteDate).Seconds())
+			addCount(&day.Restored, item)
+			addCount(&history.Total.Restored, item)
+			day.RestoreDelay += delay
+			history.Total.RestoreDelay += delay
+		case HistoryPurged:
+			addCount(&day.Purged, item)
+			addCount(&history.Total.Purged, item)
+		}
+	}
+
+	pruneHistory(&history, now)
+	if err := saveHistory(history, config); err != nil {
+		LogSimpleOperation("ERROR", fmt.Sprintf("Failed to save the history: %v", err), config)
+		return
+	}
+	metricsStale.Store(true)
+}
+
+func addCount(count *types.HistoryCount, item types.DeletedItem) {
+	count.Items++
+	count.Bytes += item.Size
+}
+
+func addedCount(count types.HistoryCount, item types.DeletedItem) types.HistoryCount {
+	addCount(&count, item)
+	return count
+}
+
+// pruneHistory drops the days older than historyDays, and keeps only the
+// largest directories and extensions of the days before now.
+func pruneHistory(history *types.History, now time.Time) {
+	oldest := now.AddDate(0, 0, -historyDays).Format(HistoryDate)
+	today := now.Format(HistoryDate)
+	for date, day := range history.Days {
+		switch {
+		case date < oldest:
+			delete(history.Days, date)
+		case date != today:
+			day.Dirs = topCounts(day.Dirs, historyTop)
+			day.Exts = topCounts(day.Exts, historyTop)
+		}
+	}
+}
+
+// topCounts keeps the n largest entries of counts, by size.
+func topCounts(counts map[string]types.HistoryCount, n int) map[string]types.HistoryCount {
+	if len(counts) <= n {
+		return counts
+	}
+	keys := SortedCounts(counts)
+	top := make(map[string]types.HistoryCount, n)
+	for _, key := range keys[:n] {
+		top[key] = counts[key]
+	}
+	return top
+}
+
+// SortedCounts returns the keys of counts, largest size first.
+func SortedCounts(counts map[string]types.HistoryCount) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := counts[keys[i]], counts[keys[j]]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// ItemExtension returns the lower-case extension of an item's name, such
+// as ".iso", "(none)" for files without one and "(directory)" for
+// directories.
+func ItemExtension(item types.DeletedItem) string {
+	if item.IsDirectory {
+		return "(directory)"
+	}
+	return fileExtension(filepath.Base(item.OriginalPath))
+}
+
+// fileExtension returns the lower-case extension of a file name, or
+// "(none)".
+func fileExtension(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	// A dotfile such as .bashrc has no extension
+	if ext == "" || ext == "." || ext == strings.ToLower(name) {
+		return "(none)"
+	}
+	return ext
+}
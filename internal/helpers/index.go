@@ -8,21 +8,91 @@ import (
 	// "os/exec"
 	"path/filepath"
 	// "runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 	"vanish/internal/types"
 )
 
 // --- Index Helpers ---
 
+// indexMu serialises the read-modify-write updates of the index made by
+// items deleted and restored in parallel. lockIndex extends it to other vx
+// processes.
+var indexMu sync.Mutex
+
+// indexLock is the lock file of the index, next to index.json since that
+// one is replaced on every save.
+const indexLock = "index.lock"
+
+// lastItemID is the last ID handed out by newItemID.
+var lastItemID int64
+
+// newItemID returns the ID of an item deleted at now: its Unix time in
+// nanoseconds, bumped when needed so that items deleted in parallel
+// never share an ID.
+func newItemID(now time.Time) string {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	id := max(now.UnixNano(), lastItemID+1)
+	lastItemID = id
+	return strconv.FormatInt(id, 10)
+}
+
 // SaveIndex serializes the provided index to JSON and writes it to disk
-// at the location specified by the given config. Returns an error if
+// at the location specified by the given config. The file is replaced
+// atomically, so readers never see a partial index, and the metrics
+// textfile is marked for a refresh by FlushMetrics. Returns an error if
 // marshalling or writing to file fails.
 func SaveIndex(index types.Index, config types.Config) error {
+	if err := saveIndex(index, config); err != nil {
+		return err
+	}
+	metricsStale.Store(true)
+	return nil
+}
+
+// saveIndex writes the index without touching the metrics, for changes
+// that no metric shows.
+func saveIndex(index types.Index, config types.Config) error {
 	indexPath := GetIndexPath(config)
 	data, err := json.MarshalIndent(index, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(indexPath, data, 0644)
+	if err := os.WriteFile(indexPath+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(indexPath+".tmp", indexPath)
+}
+
+// lockIndex serialises a load-modify-save of the index with the other
+// goroutines of this process, through indexMu, and with other vx
+// processes, through a flock. It returns the function releasing both.
+func lockIndex(config types.Config) (func(), error) {
+	indexMu.Lock()
+	cacheDir := ExpandPath(config.Cache.Directory)
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		indexMu.Unlock()
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(cacheDir, indexLock), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		indexMu.Unlock()
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		indexMu.Unlock()
+		return nil, err
+	}
+	return func() {
+		file.Close()
+		indexMu.Unlock()
+	}, nil
 }
 
 // GetIndexPath returns the full path to the index.json file used to
@@ -56,6 +126,12 @@ func LoadIndex(config types.Config) (types.Index, error) {
 // index to disk using the provided config. Returns an error if loading
 // or saving the index fails.
 func AddToIndex(item types.DeletedItem, config types.Config) error {
+	unlock, err := lockIndex(config)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	index, err := LoadIndex(config)
 	if err != nil {
 		return err
@@ -69,6 +145,12 @@ func AddToIndex(item types.DeletedItem, config types.Config) error {
 // index and saves the updated index to disk. Returns an error if loading
 // or saving the index fails.
 func RemoveFromIndex(itemID string, config types.Config) error {
+	unlock, err := lockIndex(config)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	index, err := LoadIndex(config)
 	if err != nil {
 		return err
@@ -84,3 +166,100 @@ func RemoveFromIndex(itemID string, config types.Config) error {
 	index.Items = remainingItems
 	return SaveIndex(index, config)
 }
+
+// MatchesPattern reports whether a DeletedItem is selected by the given
+// pattern. A pattern selects an item when it equals the item ID or is
+// contained (case-insensitive) in the original path.
+func MatchesPattern(item types.DeletedItem, pattern string) bool {
+	if item.ID == pattern {
+		return true
+	}
+	return strings.Contains(strings.ToLower(item.OriginalPath), strings.ToLower(pattern))
+}
+
+// FindMatchingItems returns the items in the index selected by any of the
+// given patterns. Each item is returned at most once, in index order.
+func FindMatchingItems(index types.Index, patterns []string) []types.DeletedItem {
+	var matchingItems []types.DeletedItem
+	for _, item := range index.Items {
+		for _, pattern := range patterns {
+			if MatchesPattern(item, pattern) {
+				matchingItems = append(matchingItems, item)
+				break
+			}
+		}
+	}
+	return matchingItems
+}
+
+// FindItemsByID returns the items in the index whose ID is one of ids, in
+// index order. Unlike FindMatchingItems, nothing is matched against paths.
+func FindItemsByID(index types.Index, ids []string) []types.DeletedItem {
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+	var items []types.DeletedItem
+	for _, item := range index.Items {
+		if selected[item.ID] {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// SetPinned pins or unpins every item selected by the given patterns and
+// saves the index. until is only used when pinning; a zero value pins the
+// items until they are unpinned. Returns the updated items.
+func SetPinned(patterns []string, pinned bool, until time.Time, config types.Config) ([]types.DeletedItem, error) {
+	return setPinned(func(item types.DeletedItem) bool {
+		for _, pattern := range patterns {
+			if MatchesPattern(item, pattern) {
+				return true
+			}
+		}
+		return false
+	}, pinned, until, config)
+}
+
+// SetPinnedByID is SetPinned for the items whose ID is one of ids, without
+// matching paths.
+func SetPinnedByID(ids []string, pinned bool, until time.Time, config types.Config) ([]types.DeletedItem, error) {
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+	return setPinned(func(item types.DeletedItem) bool {
+		return selected[item.ID]
+	}, pinned, until, config)
+}
+
+func setPinned(match func(types.DeletedItem) bool, pinned bool, until time.Time, config types.Config) ([]types.DeletedItem, error) {
+	unlock, err := lockIndex(config)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	index, err := LoadIndex(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated []types.DeletedItem
+	for i, item := range index.Items {
+		if match(item) {
+			index.Items[i].Pinned = pinned
+			index.Items[i].PinnedUntil = time.Time{}
+			if pinned {
+				index.Items[i].PinnedUntil = until
+			}
+			updated = append(updated, index.Items[i])
+		}
+	}
+
+	if len(updated) == 0 {
+		return nil, nil
+	}
+	return updated, SaveIndex(index, config)
+}
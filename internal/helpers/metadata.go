@@ -0,0 +1,162 @@
+package helpers
+
+import (
+	"archive/tar"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// --- File Metadata ---
+
+// Kinds of metadata that can fail to carry over when a payload is copied
+// instead of renamed, as recorded in DeletedItem.MetadataLost.
+const (
+	MetadataTimes     = "times"
+	MetadataOwnership = "ownership"
+	MetadataXattrs    = "xattrs"
+	MetadataACL       = "acl"
+	MetadataSELinux   = "selinux"
+)
+
+// paxXattrPrefix is the PAX record prefix GNU tar and bsdtar use for
+// extended attributes.
+const paxXattrPrefix = "SCHILY.xattr."
+
+// fileMetadata is what a copy carries over besides the content: the full
+// mode, owner, access and modification times and extended attributes,
+// which include POSIX ACLs and SELinux labels.
+type fileMetadata struct {
+	Mode   os.FileMode       `json:"mode"`
+	UID    int               `json:"uid"`
+	GID    int               `json:"gid"`
+	ATime  time.Time         `json:"atime"`
+	MTime  time.Time         `json:"mtime"`
+	Xattrs map[string][]byte `json:"xattrs,omitempty"`
+}
+
+// readMetadata captures the metadata of path without following symlinks.
+// Read it before the content, which updates the access time.
+func readMetadata(path string, report *CopyReport) (*fileMetadata, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	meta := &fileMetadata{Mode: info.Mode(), MTime: info.ModTime(), ATime: info.ModTime()}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		meta.UID, meta.GID = int(stat.Uid), int(stat.Gid)
+		if atime, ok := accessTime(stat); ok {
+			meta.ATime = atime
+		} else {
+			report.lose(MetadataTimes)
+		}
+	}
+
+	names, err := listXattrs(path)
+	if err != nil {
+		if !unsupported(err) {
+			report.lose(MetadataXattrs)
+		}
+		return meta, nil
+	}
+	for _, name := range names {
+		value, err := getXattr(path, name)
+		if err != nil {
+			report.lose(xattrKind(name))
+			continue
+		}
+		if meta.Xattrs == nil {
+			meta.Xattrs = make(map[string][]byte)
+		}
+		meta.Xattrs[name] = value
+	}
+	return meta, nil
+}
+
+// metadataFromHeader returns the metadata stored in a tar header.
+func metadataFromHeader(header *tar.Header) *fileMetadata {
+	meta := &fileMetadata{
+		Mode:  header.FileInfo().Mode(),
+		UID:   header.Uid,
+		GID:   header.Gid,
+		ATime: header.AccessTime,
+		MTime: header.ModTime,
+	}
+	if meta.ATime.IsZero() {
+		meta.ATime = meta.MTime
+	}
+	for key, value := range header.PAXRecords {
+		if name, found := strings.CutPrefix(key, paxXattrPrefix); found {
+			if meta.Xattrs == nil {
+				meta.Xattrs = make(map[string][]byte)
+			}
+			meta.Xattrs[name] = []byte(value)
+		}
+	}
+	return meta
+}
+
+// addToHeader stores the metadata tar.FileInfoHeader leaves out: access
+// time and extended attributes. It needs the PAX format.
+func (m *fileMetadata) addToHeader(header *tar.Header) {
+	header.Format = tar.FormatPAX
+	header.AccessTime = m.ATime
+	for name, value := range m.Xattrs {
+		if header.PAXRecords == nil {
+			header.PAXRecords = make(map[string]string)
+		}
+		header.PAXRecords[paxXattrPrefix+name] = string(value)
+	}
+}
+
+// apply sets the metadata on path, recording in report whatever could not
+// be set. Ownership comes first since chown clears the setuid and setgid
+// bits, times come last since setting xattrs counts as a change.
+func (m *fileMetadata) apply(path string, report *CopyReport) {
+	if err := os.Lchown(path, m.UID, m.GID); err != nil {
+		// Unprivileged users can only give files to themselves
+		if info, statErr := os.Lstat(path); statErr != nil || !sameOwner(info, m) {
+			report.lose(MetadataOwnership)
+		}
+	}
+
+	isSymlink := m.Mode&os.ModeSymlink != 0
+	if !isSymlink {
+		os.Chmod(path, m.Mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+	}
+
+	for name, value := range m.Xattrs {
+		if err := setXattr(path, name, value); err != nil {
+			report.lose(xattrKind(name))
+		}
+	}
+
+	if err := setTimes(path, m.ATime, m.MTime, isSymlink); err != nil {
+		report.lose(MetadataTimes)
+	}
+}
+
+func sameOwner(info os.FileInfo, m *fileMetadata) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == m.UID && int(stat.Gid) == m.GID
+}
+
+// xattrKind classifies an extended attribute for CopyReport.
+func xattrKind(name string) string {
+	switch {
+	case strings.HasPrefix(name, "system.posix_acl_"):
+		return MetadataACL
+	case name == "security.selinux":
+		return MetadataSELinux
+	}
+	return MetadataXattrs
+}
+
+// unsupported reports whether err means the filesystem has no xattrs.
+func unsupported(err error) bool {
+	return errors.Is(err, unix.ENOTSUP)
+}
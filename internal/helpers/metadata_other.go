@@ -0,0 +1,43 @@
+//go:build !linux
+
+package helpers
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// errNoXattrs is returned on platforms where extended attributes are not
+// carried over, so that readMetadata records them as lost.
+var errNoXattrs = errors.New("extended attributes are only copied on Linux")
+
+// accessTime is not read outside Linux; the modification time stands in
+// and the times are recorded as lost.
+func accessTime(*syscall.Stat_t) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// setTimes sets the times of path. Symlinks cannot be changed without
+// following them and are left alone.
+func setTimes(path string, atime, mtime time.Time, isSymlink bool) error {
+	if isSymlink {
+		return errors.ErrUnsupported
+	}
+	return os.Chtimes(path, atime, mtime)
+}
+
+func setXattr(string, string, []byte) error {
+	return errNoXattrs
+}
+
+func clearXattrs(string) {}
+
+func listXattrs(string) ([]string, error) {
+	return nil, errNoXattrs
+}
+
+func getXattr(string, string) ([]byte, error) {
+	return nil, errNoXattrs
+}
@@ -0,0 +1,178 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"vanish/internal/types"
+)
+
+// --- Prometheus Metrics ---
+
+// Statuses of the vanish_cache_items metric.
+const (
+	metricActive  = "active"
+	metricExpired = "expired"
+	metricPinned  = "pinned"
+)
+
+// metricsStale is set when the index or the history changed since the
+// textfile was last written.
+var metricsStale atomic.Bool
+
+// WriteMetrics writes the cache metrics to w in the Prometheus text
+// exposition format, as read by node_exporter's textfile collector.
+func WriteMetrics(w io.Writer, config types.Config) error {
+	index, err := LoadIndex(config)
+	if err != nil {
+		return err
+	}
+	history, err := LoadHistory(config)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatMetrics(index, history, config))
+	return err
+}
+
+// formatMetrics renders the metrics of index and history. Every series is
+// labelled with the user, so that the textfiles of several users can sit
+// in the same collector directory.
+func formatMetrics(index types.Index, history types.History, config types.Config) []byte {
+	var b bytes.Buffer
+	userLabel := label("user", metricsUser())
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	sample := func(name, labels string, value int64) {
+		if labels != "" {
+			labels = "," + labels
+		}
+		fmt.Fprintf(&b, "%s{%s%s} %d\n", name, userLabel, labels, value)
+	}
+
+	var local, remote, expired int64
+	counts := make(map[string]int64)
+	for _, item := range index.Items {
+		if item.IsRemote() {
+			remote += item.Size
+		} else {
+			local += item.Size
+		}
+		status := metricActive
+		switch {
+		case item.IsPinned():
+			status = metricPinned
+		case IsExpired(item, config):
+			status = metricExpired
+			expired += item.Size
+		}
+		counts[item.ItemType()+"/"+status]++
+	}
+
+	metric("vanish_cache_size_bytes", "gauge", "Bytes held by cached items, in the local cache or the remote tier.")
+	sample("vanish_cache_size_bytes", `tier="local"`, local)
+	sample("vanish_cache_size_bytes", `tier="remote"`, remote)
+
+	metric("vanish_cache_items", "gauge", "Cached items by type and status.")
+	for _, kind := range []string{"file", "directory", "symlink"} {
+		for _, status := range []string{metricActive, metricExpired, metricPinned} {
+			sample("vanish_cache_items", label("type", kind)+","+label("status", status), counts[kind+"/"+status])
+		}
+	}
+
+	metric("vanish_cache_expired_bytes", "gauge", "Bytes of expired items that were not purged yet.")
+	sample("vanish_cache_expired_bytes", "", expired)
+
+	if config.Cache.MaxSize != "" {
+		if quota, err := ParseSize(config.Cache.MaxSize); err == nil {
+			metric("vanish_cache_quota_bytes", "gauge", "Local cache size quota set by cache.max_size.")
+			sample("vanish_cache_quota_bytes", "", quota)
+		}
+	}
+
+	total := history.Total
+	metric("vanish_operation_items_total", "counter", "Items deleted, restored and purged since the history started.")
+	sample("vanish_operation_items_total", `operation="delete"`, int64(total.Deleted.Items))
+	sample("vanish_operation_items_total", `operation="restore"`, int64(total.Restored.Items))
+	sample("vanish_operation_items_total", `operation="purge"`, int64(total.Purged.Items))
+
+	metric("vanish_operation_bytes_total", "counter", "Bytes deleted, restored and purged since the history started.")
+	sample("vanish_operation_bytes_total", `operation="delete"`, total.Deleted.Bytes)
+	sample("vanish_operation_bytes_total", `operation="restore"`, total.Restored.Bytes)
+	sample("vanish_operation_bytes_total", `operation="purge"`, total.Purged.Bytes)
+
+	metric("vanish_metrics_updated_timestamp_seconds", "gauge", "When these metrics were written.")
+	sample("vanish_metrics_updated_timestamp_seconds", "", time.Now().Unix())
+	return b.Bytes()
+}
+
+// labelEscaper escapes label values as the text exposition format wants:
+// only backslashes, double quotes and line feeds.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// label renders name="value".
+func label(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+// metricsUser names the owner of the cache for the user label.
+func metricsUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// FlushMetrics rewrites metrics.textfile, when set and when the index or
+// the history changed since it was last written. Commands call it once
+// they are done, so that a batch writes the textfile once rather than once
+// per item. Like the history, the textfile is only bookkeeping, so a
+// failure is logged and never fails the command.
+func FlushMetrics(config types.Config) {
+	if config.Metrics.Textfile == "" || !metricsStale.Swap(false) {
+		return
+	}
+	if err := writeMetricsFile(config); err != nil {
+		LogSimpleOperation("ERROR", fmt.Sprintf("Failed to write the metrics textfile: %v", err), config)
+	}
+}
+
+func writeMetricsFile(config types.Config) error {
+	index, err := LoadIndex(config)
+	if err != nil {
+		return err
+	}
+	history, err := LoadHistory(config)
+	if err != nil {
+		return err
+	}
+
+	// The collector must never see a half-written file, and only reads
+	// names ending in .prom
+	path := ExpandPath(config.Metrics.Textfile)
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+strings.TrimSuffix(filepath.Base(path), ".prom")+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(formatMetrics(index, history, config)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
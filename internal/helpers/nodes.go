@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// --- Hardlinks, Sparse and Special Files ---
+
+// paxSparse marks tar entries of sparse files, whose zero blocks are
+// written back as holes on extraction.
+const paxSparse = "VANISH.sparse"
+
+// sparseBlock is the granularity at which zeros become holes when
+// extracting sparse files.
+const sparseBlock = 4096
+
+// hardlinks remembers the first path seen for every inode with more than
+// one link during a copy, so that later paths to it become links again
+// instead of independent copies.
+type hardlinks map[[2]uint64]string
+
+// link returns the path recorded for the inode of info, recording path
+// when it is the first one seen.
+func (h hardlinks) link(info os.FileInfo, path string) (string, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink < 2 || info.IsDir() {
+		return "", false
+	}
+	key := [2]uint64{uint64(stat.Dev), uint64(stat.Ino)}
+	if first, seen := h[key]; seen {
+		return first, true
+	}
+	h[key] = path
+	return "", false
+}
+
+// isSpecial reports whether mode is a FIFO, socket or device node.
+func isSpecial(mode os.FileMode) bool {
+	return mode&(os.ModeNamedPipe|os.ModeSocket|os.ModeDevice|os.ModeCharDevice) != 0
+}
+
+// errSocket is returned for sockets, which only make sense bound to the
+// process that created them and are never copied.
+var errSocket = errors.New("sockets cannot be moved across filesystems")
+
+// copySpecial recreates the FIFO or device node at src at dst. Sockets
+// are refused with errSocket.
+func copySpecial(src, dst string, info os.FileInfo, report *CopyReport) error {
+	if info.Mode()&os.ModeSocket != 0 {
+		return errSocket
+	}
+	meta, err := readMetadata(src, report)
+	if err != nil {
+		return err
+	}
+	var rdev uint64
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		rdev = uint64(stat.Rdev)
+	}
+	if err := makeNode(dst, info.Mode(), rdev); err != nil {
+		return err
+	}
+	meta.apply(dst, report)
+	return nil
+}
+
+// writeSparse writes r to dst, seeking over blocks of zeros instead of
+// writing them so that they become holes.
+func writeSparse(dst *os.File, r io.Reader) error {
+	buf := make([]byte, sparseBlock)
+	zero := make([]byte, sparseBlock)
+	var size int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if bytes.Equal(buf[:n], zero[:n]) {
+				if _, err := dst.Seek(int64(n), io.SeekCurrent); err != nil {
+					return err
+				}
+			} else if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+			size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// Seeking past the end does not extend the file, a trailing hole does
+	return dst.Truncate(size)
+}
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// makeNode creates a FIFO or device node. Device nodes need root.
+func makeNode(path string, mode os.FileMode, rdev uint64) error {
+	var kind uint32
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		kind = unix.S_IFIFO
+	case mode&os.ModeCharDevice != 0:
+		kind = unix.S_IFCHR
+	case mode&os.ModeDevice != 0:
+		kind = unix.S_IFBLK
+	default:
+		return fmt.Errorf("cannot create %s: unsupported file type %s", path, mode.Type())
+	}
+	if err := unix.Mknod(path, kind|uint32(mode.Perm()), int(rdev)); err != nil {
+		return &os.PathError{Op: "mknod", Path: path, Err: err}
+	}
+	return nil
+}
+
+// isSparse reports whether the file uses fewer blocks than its size needs.
+func isSparse(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && info.Mode().IsRegular() && stat.Blocks*512 < info.Size()
+}
+
+// copySparse copies only the data regions of src, found with SEEK_DATA
+// and SEEK_HOLE, leaving holes in dst where src has them. It returns the
+// slowest copy method used. Holes count as copied in report.
+func copySparse(dst, src *os.File, size int64, report *CopyReport) (string, error) {
+	method := CopyFileRange
+	var offset int64
+	for offset < size {
+		data, err := src.Seek(offset, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			break // Only a hole is left
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := report.count(data - offset); err != nil {
+			return "", err
+		}
+		hole, err := src.Seek(data, unix.SEEK_HOLE)
+		if err != nil {
+			return "", err
+		}
+		used, err := copyRange(dst, src, data, hole-data, report)
+		if err != nil {
+			return "", err
+		}
+		if used == CopyReadWrite {
+			method = used
+		}
+		offset = hole
+	}
+	if err := report.count(size - offset); err != nil {
+		return "", err
+	}
+	return method, dst.Truncate(size)
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errNoNodes is returned outside Linux, where FIFOs and device nodes are
+// not recreated.
+var errNoNodes = errors.New("special files are only recreated on Linux")
+
+// makeNode refuses to create special files outside Linux.
+func makeNode(path string, mode os.FileMode, _ uint64) error {
+	return &os.PathError{Op: "mknod", Path: path, Err: fmt.Errorf("%w: %s", errNoNodes, mode.Type())}
+}
+
+// isSparse is always false outside Linux, where holes are not looked for
+// and sparse files are copied in full.
+func isSparse(os.FileInfo) bool {
+	return false
+}
+
+// copySparse copies src in full; it is not reached since isSparse is
+// always false.
+func copySparse(dst, src *os.File, size int64, report *CopyReport) (string, error) {
+	return copyRange(dst, src, 0, size, report)
+}
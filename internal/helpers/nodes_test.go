@@ -0,0 +1,106 @@
+package helpers
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sparseContent is a block of data, two blocks of zeros, a short run of
+// data and a trailing block of zeros.
+func sparseContent() []byte {
+	var b bytes.Buffer
+	b.Write(bytes.Repeat([]byte("a"), sparseBlock))
+	b.Write(make([]byte, 2*sparseBlock))
+	b.WriteString("tail")
+	b.Write(make([]byte, sparseBlock))
+	return b.Bytes()
+}
+
+// holesSupported reports whether files in dir can have holes.
+func holesSupported(t *testing.T, dir string) bool {
+	t.Helper()
+	file, err := os.CreateTemp(dir, "probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := file.Truncate(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return isSparse(info)
+}
+
+// checkFile fails unless path holds want, and holes when sparse is set.
+func checkFile(t *testing.T, path string, want []byte, sparse bool) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s holds %d bytes that differ from the %d written", filepath.Base(path), len(got), len(want))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sparse && !isSparse(info) {
+		t.Errorf("%s has no holes", filepath.Base(path))
+	}
+}
+
+func TestWriteSparse(t *testing.T) {
+	dir := t.TempDir()
+	want := sparseContent()
+	for _, data := range [][]byte{want, want[:sparseBlock+10], nil} {
+		dst, err := os.Create(filepath.Join(dir, "dst"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writeSparse(dst, bytes.NewReader(data))
+		dst.Close()
+		if err != nil {
+			t.Fatalf("writeSparse of %d bytes: %v", len(data), err)
+		}
+		checkFile(t, dst.Name(), data, len(data) == len(want) && holesSupported(t, dir))
+	}
+}
+
+func TestCopySparse(t *testing.T) {
+	dir := t.TempDir()
+	want := sparseContent()
+	src, err := os.Create(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	if err := writeSparse(src, bytes.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+	info, err := src.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := os.Create(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var copied int64
+	report := NewCopyReport(t.Context(), func(n int64) { copied += n })
+	_, err = copySparse(dst, src, info.Size(), report)
+	dst.Close()
+	if err != nil {
+		t.Fatalf("copySparse: %v", err)
+	}
+	checkFile(t, dst.Name(), want, isSparse(info))
+	if copied != int64(len(want)) {
+		t.Errorf("copySparse reported %d bytes, want %d", copied, len(want))
+	}
+}
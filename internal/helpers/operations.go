@@ -0,0 +1,270 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"vanish/internal/types"
+)
+
+// --- Cache Operations ---
+
+// DefaultJobs is the number of items deleted or restored at once when
+// cache.jobs is not set.
+const DefaultJobs = 4
+
+// RestoreItem moves a cached item back to its original location and drops
+// it from the index. It refuses to overwrite anything at the destination.
+// report, which may be nil, receives the bytes copied and can cancel the
+// restore, which then leaves the item in the cache and nothing at the
+// destination.
+func RestoreItem(item types.DeletedItem, report *CopyReport, config types.Config) error {
+	if err := report.err(); err != nil {
+		return err
+	}
+
+	// Check if cache file exists
+	store := StorageFor(item, config)
+	if _, err := store.Stat(item.CachePath); os.IsNotExist(err) {
+		return fmt.Errorf("cached file not found: %s", item.CachePath)
+	}
+
+	// Create directory for original path if needed
+	originalDir := filepath.Dir(item.OriginalPath)
+	if err := os.MkdirAll(originalDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", originalDir, err)
+	}
+
+	// Check if original path already exists
+	if _, err := os.Lstat(item.OriginalPath); !os.IsNotExist(err) {
+		return fmt.Errorf("destination already exists: %s", item.OriginalPath)
+	}
+
+	// Restore based on item type
+	var err error
+	if report == nil {
+		report = &CopyReport{}
+	}
+	report.expect(item.Size)
+	if item.Compressed {
+		// Restore compressed payload of any type, unpacking bundled
+		// archives from a temporary copy
+		archive := item.CachePath
+		if store.Name() != StorageDirectory {
+			archive = filepath.Join(ExpandPath(config.Cache.Directory), "."+item.ID+CompressedSuffix)
+			err = store.Get(item.CachePath, archive, report)
+		}
+		if err == nil {
+			// The payload has left the store, it must reach the destination
+			if store.Name() != StorageDirectory {
+				report.commit()
+			}
+			err = ExtractFromCache(archive, item.OriginalPath, report)
+		}
+	} else {
+		// Restore file, directory or symlink
+		err = store.Get(item.CachePath, item.OriginalPath, report)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to restore %s: %w", item.ItemType(), err)
+	}
+	report.finish()
+
+	// Remove from index, a failure is logged but does not undo the restore
+	if err := RemoveFromIndex(item.ID, config); err != nil {
+		LogSimpleOperation("ERROR", fmt.Sprintf("Failed to remove from index: %s", item.ID), config)
+	}
+
+	// Log the restore operation
+	recordHistory(HistoryRestored, []types.DeletedItem{item}, config)
+	if config.Logging.Enabled {
+		LogOperation("RESTORE", item, config)
+		if kinds := report.LostMetadata(); len(kinds) > 0 {
+			LogSimpleOperation("METADATA", fmt.Sprintf("Could not restore %s of %s", strings.Join(kinds, ", "), item.OriginalPath), config)
+		}
+		for _, path := range report.Skipped() {
+			LogSimpleOperation("SKIP", fmt.Sprintf("Could not restore special file %s", path), config)
+		}
+	}
+
+	return nil
+}
+
+// unstore brings an item that failed with cause after leaving filename
+// back there, so that it never ends up only in an unindexed corner of the
+// cache: from the store when cachePath is set, then out of its archive
+// when payload is one. It returns cause, saying where the item was left if
+// it could not be brought back.
+func unstore(filename, payload, cachePath string, store Storage, cause error) error {
+	if cachePath != "" {
+		if err := store.Get(cachePath, payload, nil); err != nil {
+			return fmt.Errorf("%v, and it stays unindexed at %s: %v", cause, cachePath, err)
+		}
+	}
+	if payload != filename {
+		if err := ExtractFromCache(payload, filename, nil); err != nil {
+			return fmt.Errorf("%v, and it stays unindexed at %s: %v", cause, payload, err)
+		}
+	}
+	return cause
+}
+
+// DeleteItem moves a file, directory, or symlink to the cache and records
+// it in the index. Permanent items, and items matched by a skip_cache
+// retention rule, are deleted directly and never reach the cache; the
+// returned flag reports whether that happened. report, which may be nil,
+// receives the bytes copied and can cancel the deletion, which then leaves
+// the item where it was.
+func DeleteItem(filename string, permanent bool, report *CopyReport, config types.Config) (types.DeletedItem, bool, error) {
+	if err := report.err(); err != nil {
+		return types.DeletedItem{}, false, err
+	}
+	if report == nil {
+		report = &CopyReport{}
+	}
+
+	// Ensure cache directory exists
+	cacheDir := ExpandPath(config.Cache.Directory)
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return types.DeletedItem{}, false, err
+	}
+
+	// Get file info using Lstat (doesn't follow symlinks)
+	stat, err := os.Lstat(filename)
+	if err != nil {
+		return types.DeletedItem{}, false, err
+	}
+
+	// Get absolute path
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		return types.DeletedItem{}, false, err
+	}
+
+	// Find the retention rule that applies to this item
+	size := stat.Size()
+	if stat.IsDir() {
+		size, _ = GetDirectorySize(filename)
+	}
+	rule := MatchRetentionRule(filename, stat, size, config)
+	report.expect(size)
+
+	// Generate unique ID and cache filename
+	now := time.Now()
+	id := newItemID(now)
+	timestamp := now.Format("2006-01-02-15-04-05")
+	baseFilename := filepath.Base(filename)
+	cacheFilename := fmt.Sprintf("%s-%s-%s", id, timestamp, baseFilename)
+
+	// Determine file type
+	isSymlink := stat.Mode()&os.ModeSymlink != 0
+	isDir := stat.IsDir()
+	fileCount := 0
+	linkTarget := ""
+
+	if isDir {
+		fileCount, _ = CountFilesInDirectory(filename)
+	}
+	if isSymlink {
+		linkTarget, _ = os.Readlink(filename)
+	}
+
+	// Bypass the cache for permanent items
+	if permanent || (rule != nil && rule.SkipCache) {
+		// Bypass the cache, the item cannot be restored
+		if err := os.RemoveAll(filename); err != nil {
+			return types.DeletedItem{}, false, fmt.Errorf("failed to delete %s permanently: %v", filename, err)
+		}
+		item := types.DeletedItem{
+			ID:           id,
+			OriginalPath: absPath,
+			DeleteDate:   now,
+			IsDirectory:  isDir,
+			IsSymlink:    isSymlink,
+			LinkTarget:   linkTarget,
+			FileCount:    fileCount,
+			Size:         size,
+		}
+		recordHistory(HistoryDeleted, []types.DeletedItem{item}, config)
+		if config.Logging.Enabled {
+			LogOperation("DELETE_PERMANENT", item, config)
+		}
+		report.finish()
+		return item, true, nil
+	}
+
+	// Matched a compress rule, store as a .tar.gz archive
+	payload := filename
+	if rule != nil && rule.Compress {
+		cacheFilename += CompressedSuffix
+		payload = filepath.Join(cacheDir, "."+cacheFilename)
+		if err := CompressToCache(filename, payload, report); err != nil {
+			return types.DeletedItem{}, false, fmt.Errorf("failed to compress %s: %w", filename, err)
+		}
+		// The original is gone, the archive must reach the store
+		report.commit()
+	}
+
+	// Hand the payload to the storage backend
+	store := storageForNew(size, config)
+	cachePath, err := store.Put(payload, cacheFilename, report)
+	if err != nil && store.Name() != StorageDirectory && report.err() == nil && !errors.Is(err, errNotPutBack) {
+		// Backends put the payload back when Put fails, fall back to the
+		// plain directory layout
+		store = storageByName(StorageDirectory, config)
+		cachePath, err = store.Put(payload, cacheFilename, report)
+	}
+	if err != nil {
+		if payload != filename && !errors.Is(err, errNotPutBack) {
+			err = unstore(filename, payload, "", nil, err)
+		}
+		return types.DeletedItem{}, false, err
+	}
+
+	// Create deleted item with all metadata
+	item := types.DeletedItem{
+		ID:           id,
+		OriginalPath: absPath,
+		DeleteDate:   now,
+		CachePath:    cachePath,
+		IsDirectory:  isDir,
+		IsSymlink:    isSymlink,
+		LinkTarget:   linkTarget,
+		FileCount:    fileCount,
+		Size:         size,
+		Compressed:   rule != nil && rule.Compress,
+		Sensitive:    rule != nil && rule.Sensitive,
+		MetadataLost: report.LostMetadata(),
+		Skipped:      report.Skipped(),
+		CopyMethod:   report.Method(),
+	}
+	if store.Name() != StorageDirectory {
+		item.Storage = store.Name()
+	}
+	if rule != nil && rule.Days > 0 {
+		item.ExpiresAt = now.Add(time.Duration(rule.Days) * 24 * time.Hour)
+	}
+
+	// Update index
+	if err := AddToIndex(item, config); err != nil {
+		return types.DeletedItem{}, false, unstore(filename, payload, cachePath, store, fmt.Errorf("failed to update index: %v", err))
+	}
+
+	report.finish()
+
+	// Log the operation
+	recordHistory(HistoryDeleted, []types.DeletedItem{item}, config)
+	if config.Logging.Enabled {
+		LogOperation("DELETE", item, config)
+		for _, path := range item.Skipped {
+			LogSimpleOperation("SKIP", fmt.Sprintf("Left special file %s out of the cache", path), config)
+		}
+	}
+
+	return item, false, nil
+}
@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vanish/internal/types"
+)
+
+func TestDeleteItemPutsBackOnIndexFailure(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+		var config types.Config
+		config.Cache.Directory = filepath.Join(dir, "cache")
+		config.Retention.Rules = []types.RetentionRule{{Match: "*.log", Compress: compress}}
+		// The index cannot be read while index.json is a directory
+		if err := os.MkdirAll(filepath.Join(config.Cache.Directory, "index.json"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeTree(t, filepath.Join(dir, "a.log"), map[string]string{"x": "kept", "sub/y": "too"})
+
+		if _, _, err := DeleteItem(filepath.Join(dir, "a.log"), false, nil, config); err == nil {
+			t.Fatalf("compress %v: DeleteItem succeeded without an index", compress)
+		}
+		checkTree(t, filepath.Join(dir, "a.log"), map[string]string{"x": "kept", "sub/y": "too"})
+		entries, err := os.ReadDir(config.Cache.Directory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if entry.Name() != "index.json" && entry.Name() != indexLock {
+				t.Errorf("compress %v: %s is left in the cache", compress, entry.Name())
+			}
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package helpers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"vanish/internal/types"
+)
+
+// --- Reading Payloads In Place ---
+
+// PayloadEntry is one file, directory or symlink of a cached payload.
+type PayloadEntry struct {
+	Path string      // Slash-separated, "." for the payload root
+	Mode fs.FileMode // Type and permission bits
+	Size int64       // Content size of a regular file
+	Link string      // Target of a symlink
+}
+
+// PayloadFunc is called by WalkPayload for each entry. content reads the
+// file content of regular files and is nil for anything else; it is only
+// valid during the call. Returning fs.SkipAll ends the walk early.
+type PayloadFunc func(entry PayloadEntry, content io.Reader) error
+
+// payloadWalker is implemented by the backends whose payloads can be read
+// where they are stored, without restoring them.
+type payloadWalker interface {
+	walk(ref string, fn PayloadFunc) error
+}
+
+// WalkPayload calls fn for every entry of item's payload, parents before
+// their contents, reading it straight from storage: compressed payloads
+// are decompressed on the fly and bundled or deduplicated ones are read
+// from the bundle or the blob store. Payloads in the remote tier are not
+// downloaded for this and return an error.
+func WalkPayload(item types.DeletedItem, config types.Config, fn PayloadFunc) error {
+	walker, ok := StorageFor(item, config).(payloadWalker)
+	if !ok {
+		return fmt.Errorf("the payload is in the %s tier, restore it to look inside", item.Storage)
+	}
+
+	err := walker.walk(item.CachePath, func(entry PayloadEntry, content io.Reader) error {
+		if !item.Compressed {
+			return fn(entry, content)
+		}
+		if entry.Path != "." || content == nil {
+			return fmt.Errorf("compressed payload is not an archive: %s", item.CachePath)
+		}
+		return walkArchive(content, fn)
+	})
+	if errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// PayloadSource describes where WalkPayload reads item's payload from.
+func PayloadSource(item types.DeletedItem) string {
+	var source string
+	switch item.Storage {
+	case StorageBundle:
+		archive, _, _ := parseBundleRef(item.CachePath)
+		source = "bundle " + filepath.Base(archive)
+	case StorageDedup:
+		source = "dedup store"
+	case StorageRemote:
+		source = "remote tier"
+	default:
+		source = "cache directory"
+	}
+	if item.Compressed {
+		source += ", gzip archive decoded on the fly"
+	}
+	return source
+}
+
+func (s *directoryStorage) walk(ref string, fn PayloadFunc) error {
+	return filepath.WalkDir(ref, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(ref, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		entry := PayloadEntry{Path: filepath.ToSlash(rel), Mode: info.Mode()}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if entry.Link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			entry.Size = info.Size()
+			file := &lazyFile{path: p}
+			defer file.Close()
+			return fn(entry, file)
+		}
+		return fn(entry, nil)
+	})
+}
+
+func (s *bundleStorage) walk(ref string, fn PayloadFunc) error {
+	archive, name, err := parseBundleRef(ref)
+	if err != nil {
+		return err
+	}
+	manifest, err := loadBundleManifest(archive)
+	if err != nil {
+		return err
+	}
+	if entry := manifest.Entries[name]; entry == nil || entry.Deleted {
+		return &os.PathError{Op: "walk", Path: ref, Err: os.ErrNotExist}
+	}
+
+	// A shared lock lets concurrent appends finish before reading
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH); err != nil {
+		return err
+	}
+
+	return walkTar(tar.NewReader(file), func(entryName string) (string, bool) {
+		entryName = strings.TrimSuffix(entryName, "/")
+		if entryName != name && !strings.HasPrefix(entryName, name+"/") {
+			return "", false
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(entryName, name), "/")
+		if rel == "" {
+			rel = "."
+		}
+		return rel, true
+	}, fn)
+}
+
+// bundleFunc is called by walkBundle for each entry, with the item it
+// belongs to.
+type bundleFunc func(item types.DeletedItem, entry PayloadEntry, content io.Reader) error
+
+// walkBundle is WalkPayload for all the items stored in archive, reading
+// it once rather than once per item. It returns the error of every item
+// that could not be walked; entries already passed to fn for such an item
+// should be dropped.
+func walkBundle(archive string, items []types.DeletedItem, fn bundleFunc) map[string]error {
+	failed := make(map[string]error)
+	failAll := func(err error) map[string]error {
+		for _, item := range items {
+			if failed[item.ID] == nil {
+				failed[item.ID] = err
+			}
+		}
+		return failed
+	}
+
+	manifest, err := loadBundleManifest(archive)
+	if err != nil {
+		return failAll(err)
+	}
+	byName := make(map[string]types.DeletedItem, len(items))
+	for _, item := range items {
+		_, name, err := parseBundleRef(item.CachePath)
+		if err != nil {
+			failed[item.ID] = err
+			continue
+		}
+		if entry := manifest.Entries[name]; entry == nil || entry.Deleted {
+			failed[item.ID] = &os.PathError{Op: "walk", Path: item.CachePath, Err: os.ErrNotExist}
+			continue
+		}
+		byName[name] = item
+	}
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return failAll(err)
+	}
+	defer file.Close()
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH); err != nil {
+		return failAll(err)
+	}
+
+	// Items that failed or asked to stop are not read any further
+	done := make(map[string]bool)
+	err = walkTar(tar.NewReader(file), func(entryName string) (string, bool) {
+		entryName = strings.TrimSuffix(entryName, "/")
+		name, _, _ := strings.Cut(entryName, "/")
+		_, ok := byName[name]
+		return entryName, ok && !done[name]
+	}, func(entry PayloadEntry, content io.Reader) error {
+		name, rel, _ := strings.Cut(entry.Path, "/")
+		if rel == "" {
+			rel = "."
+		}
+		item := byName[name]
+		entry.Path = rel
+		var err error
+		switch {
+		case !item.Compressed:
+			err = fn(item, entry, content)
+		case rel != "." || content == nil:
+			err = fmt.Errorf("compressed payload is not an archive: %s", item.CachePath)
+		default:
+			err = walkArchive(content, func(entry PayloadEntry, content io.Reader) error {
+				return fn(item, entry, content)
+			})
+		}
+		if err != nil {
+			done[name] = true
+			if !errors.Is(err, fs.SkipAll) {
+				failed[item.ID] = err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return failAll(err)
+	}
+	return failed
+}
+
+func (s *dedupStorage) walk(ref string, fn PayloadFunc) error {
+	manifest, err := loadDedupManifest(ref)
+	if err != nil {
+		return err
+	}
+
+	sizes := make(map[string]int64)
+	for _, e := range manifest.Entries {
+		entry := PayloadEntry{Path: e.Path, Mode: e.Mode, Size: e.Size, Link: e.Link}
+		switch {
+		case e.Hardlink != "":
+			entry.Size = sizes[e.Hardlink]
+		case e.Hash != "":
+			sizes[e.Path] = e.Size
+			file := &lazyFile{path: s.blobPath(e.Hash)}
+			err := fn(entry, file)
+			file.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(entry, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkArchive reads a gzipped archive written by CompressToCache.
+func walkArchive(r io.Reader, fn PayloadFunc) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	return walkTar(tar.NewReader(gz), func(name string) (string, bool) {
+		return path.Clean(strings.TrimSuffix(name, "/")), true
+	}, fn)
+}
+
+// walkTar calls fn for the entries of tr that rel maps to a payload path.
+// Hardlinks are reported as regular files without content.
+func walkTar(tr *tar.Reader, rel func(name string) (string, bool), fn PayloadFunc) error {
+	sizes := make(map[string]int64)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := rel(header.Name)
+		if !ok {
+			continue
+		}
+
+		entry := PayloadEntry{Path: name, Mode: header.FileInfo().Mode()}
+		var content io.Reader
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			entry.Link = header.Linkname
+		case tar.TypeLink:
+			entry.Mode = fs.FileMode(header.Mode).Perm()
+			if target, ok := rel(header.Linkname); ok {
+				entry.Size = sizes[target]
+			}
+		case tar.TypeReg:
+			entry.Size = header.Size
+			sizes[name] = header.Size
+			content = tr
+		}
+		if err := fn(entry, content); err != nil {
+			return err
+		}
+	}
+}
+
+// lazyFile opens the file at path on the first read, so that walking a
+// payload does not open every file the caller never reads.
+type lazyFile struct {
+	path string
+	file *os.File
+}
+
+func (f *lazyFile) Read(p []byte) (int, error) {
+	if f.file == nil {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return 0, err
+		}
+		f.file = file
+	}
+	return f.file.Read(p)
+}
+
+func (f *lazyFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
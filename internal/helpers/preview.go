@@ -0,0 +1,179 @@
+package helpers
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
+
+	"vanish/internal/types"
+)
+
+// --- Item Previews ---
+
+// Kinds of preview.
+const (
+	PreviewText    = "text"
+	PreviewBinary  = "binary"
+	PreviewTree    = "tree"
+	PreviewSymlink = "symlink"
+	PreviewOther   = "other"
+)
+
+const (
+	// previewBytes is how much of a file a preview reads.
+	previewBytes = 64 << 10
+	// previewEntries is how many entries of a directory a preview keeps.
+	previewEntries = 5000
+)
+
+// Preview is what a cached item holds, read from its payload.
+type Preview struct {
+	Kind      string
+	Name      string       // Base name of the original path, for syntax detection
+	Source    string       // Where the payload was read, see PayloadSource
+	Head      []byte       // Start of a file
+	Size      int64        // Full size of a file
+	FileType  string       // MIME type or description of a binary file
+	Tree      *PreviewNode // Contents of a directory
+	Truncated bool         // The directory had more entries than the tree holds
+	Link      string       // Target of a symlink
+	Resolved  string       // Where the target is looked up, relative to the original path
+	Resolves  bool         // Something exists at Resolved right now
+}
+
+// PreviewNode is an entry of a directory preview. Directories carry the
+// total size and file count of everything below them.
+type PreviewNode struct {
+	Name     string
+	Mode     fs.FileMode
+	Size     int64
+	Files    int
+	Link     string
+	Children []*PreviewNode
+}
+
+// PreviewItem reads enough of item's payload to show what it holds: the
+// start of a file, the tree of a directory or the target of a symlink.
+func PreviewItem(item types.DeletedItem, config types.Config) (Preview, error) {
+	preview := Preview{
+		Kind:   PreviewOther,
+		Name:   filepath.Base(item.OriginalPath),
+		Source: PayloadSource(item),
+	}
+	root := &PreviewNode{Name: preview.Name}
+	dirs := map[string]*PreviewNode{".": root}
+	entries := 0
+
+	err := WalkPayload(item, config, func(entry PayloadEntry, content io.Reader) error {
+		if entry.Path == "." {
+			root.Mode = entry.Mode
+			switch {
+			case entry.Mode&fs.ModeSymlink != 0:
+				preview.Kind = PreviewSymlink
+				preview.Link = entry.Link
+				preview.Resolved = entry.Link
+				if !filepath.IsAbs(entry.Link) {
+					preview.Resolved = filepath.Join(filepath.Dir(item.OriginalPath), entry.Link)
+				}
+				_, err := os.Stat(preview.Resolved)
+				preview.Resolves = err == nil
+				return fs.SkipAll
+			case entry.Mode.IsDir():
+				preview.Kind = PreviewTree
+				preview.Tree = root
+				return nil
+			case entry.Mode.IsRegular() && content != nil:
+				head, err := io.ReadAll(io.LimitReader(content, previewBytes))
+				if err != nil {
+					return err
+				}
+				preview.Head, preview.Size = head, entry.Size
+				preview.Kind = PreviewText
+				if !isText(head) {
+					preview.Kind = PreviewBinary
+					preview.FileType = describeContent(head)
+				}
+			}
+			return fs.SkipAll
+		}
+
+		if entries++; entries > previewEntries {
+			preview.Truncated = true
+			return fs.SkipAll
+		}
+		parent := dirs[path.Dir(entry.Path)]
+		if parent == nil {
+			return nil
+		}
+		node := &PreviewNode{Name: path.Base(entry.Path), Mode: entry.Mode, Size: entry.Size, Link: entry.Link}
+		if entry.Mode.IsRegular() {
+			node.Files = 1
+		}
+		parent.Children = append(parent.Children, node)
+		if entry.Mode.IsDir() {
+			dirs[entry.Path] = node
+		}
+		return nil
+	})
+	if err != nil {
+		return preview, err
+	}
+
+	if preview.Tree != nil {
+		preview.Tree.total()
+	}
+	return preview, nil
+}
+
+// total adds up the sizes and file counts below n and orders its children,
+// directories first, then by name.
+func (n *PreviewNode) total() {
+	for _, child := range n.Children {
+		if child.Mode.IsDir() {
+			child.total()
+		}
+		n.Size += child.Size
+		n.Files += child.Files
+	}
+	sort.Slice(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if a.Mode.IsDir() != b.Mode.IsDir() {
+			return a.Mode.IsDir()
+		}
+		return a.Name < b.Name
+	})
+}
+
+// isText reports whether head looks like the start of a UTF-8 text file.
+// A multi-byte character cut at the end of head does not count against it.
+func isText(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	for i := 0; i < utf8.UTFMax && len(head) > 0; i++ {
+		if utf8.Valid(head) {
+			return true
+		}
+		head = head[:len(head)-1]
+	}
+	return len(head) == 0
+}
+
+// describeContent names the type of a binary file from its first bytes.
+func describeContent(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("\x7fELF")):
+		return "ELF executable or library"
+	case len(head) > 262 && string(head[257:262]) == "ustar":
+		return "tar archive"
+	case bytes.HasPrefix(head, []byte("SQLite format 3\x00")):
+		return "SQLite database"
+	}
+	return http.DetectContentType(head)
+}
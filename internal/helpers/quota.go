@@ -0,0 +1,171 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"vanish/internal/types"
+)
+
+// --- Quota Helpers ---
+
+// EvictionPolicies lists the accepted values of cache.eviction.
+var EvictionPolicies = []string{"oldest", "largest", "lru"}
+
+// SelectEvictions decides which items must leave the cache so that it fits
+// within cache.max_size and cache.max_items. Pinned items and items whose ID
+// is in keep are never selected, so a deletion cannot evict what it just
+// cached. Items offloaded to the remote tier use no local space and are
+// neither counted nor selected. It returns the items to keep and the items
+// to evict, leaving the index untouched.
+func SelectEvictions(items []types.DeletedItem, config types.Config, keep map[string]bool) (kept, evicted []types.DeletedItem, err error) {
+	var maxSize int64
+	if config.Cache.MaxSize != "" {
+		maxSize, err = ParseSize(config.Cache.MaxSize)
+		if err != nil {
+			return items, nil, fmt.Errorf("cache.max_size: %v", err)
+		}
+	}
+	maxItems := config.Cache.MaxItems
+
+	var totalSize int64
+	count := 0
+	for _, item := range items {
+		if !item.IsRemote() {
+			totalSize += item.Size
+			count++
+		}
+	}
+	overQuota := func(count int, size int64) bool {
+		return (maxSize > 0 && size > maxSize) || (maxItems > 0 && count > maxItems)
+	}
+	if !overQuota(count, totalSize) {
+		return items, nil, nil
+	}
+
+	candidates := make([]types.DeletedItem, 0, len(items))
+	for _, item := range items {
+		if !keep[item.ID] && !item.IsPinned() && !item.IsRemote() {
+			candidates = append(candidates, item)
+		}
+	}
+	sortForEviction(candidates, config.Cache.Eviction)
+
+	evictIDs := make(map[string]bool)
+	for _, item := range candidates {
+		if !overQuota(count, totalSize) {
+			break
+		}
+		evictIDs[item.ID] = true
+		evicted = append(evicted, item)
+		count--
+		totalSize -= item.Size
+	}
+
+	for _, item := range items {
+		if !evictIDs[item.ID] {
+			kept = append(kept, item)
+		}
+	}
+	return kept, evicted, nil
+}
+
+// EnforceQuota evicts items until the cache fits within its quota, as
+// chosen by SelectEvictions, logging each one as EVICT. An item whose
+// payload cannot be removed stays in the index. It returns the evicted
+// items.
+func EnforceQuota(config types.Config, keep map[string]bool) ([]types.DeletedItem, error) {
+	unlock, err := lockIndex(config)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	index, err := LoadIndex(config)
+	if err != nil {
+		return nil, err
+	}
+
+	kept, selected, err := SelectEvictions(index.Items, config, keep)
+	if err != nil || len(selected) == 0 {
+		return nil, err
+	}
+	var evicted []types.DeletedItem
+	for _, item := range selected {
+		if err := RemoveCachedItem(item, config); err != nil && !os.IsNotExist(err) {
+			// Keep the entry so the payload left behind is not orphaned
+			LogSimpleOperation("ERROR", fmt.Sprintf("Failed to evict %s: %v", item.OriginalPath, err), config)
+			kept = append(kept, item)
+			continue
+		}
+		evicted = append(evicted, item)
+		LogOperation("EVICT", item, config)
+	}
+	if len(evicted) == 0 {
+		return nil, nil
+	}
+	recordHistory(HistoryPurged, evicted, config)
+
+	index.Items = kept
+	return evicted, SaveIndex(index, config)
+}
+
+// sortForEviction orders items so that the first one is evicted first.
+func sortForEviction(items []types.DeletedItem, policy string) {
+	switch policy {
+	case "largest":
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Size > items[j].Size
+		})
+	case "lru":
+		sort.SliceStable(items, func(i, j int) bool {
+			return lastUsed(items[i]).Before(lastUsed(items[j]))
+		})
+	default: // oldest
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].DeleteDate.Before(items[j].DeleteDate)
+		})
+	}
+}
+
+// lastUsed returns when an item was last looked at, falling back to its
+// delete date for items that were never accessed.
+func lastUsed(item types.DeletedItem) time.Time {
+	if item.LastAccessed.After(item.DeleteDate) {
+		return item.LastAccessed
+	}
+	return item.DeleteDate
+}
+
+// TouchItems records the current time as the last access of the items with
+// the given IDs. It feeds the "lru" eviction policy.
+func TouchItems(ids []string, config types.Config) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	unlock, err := lockIndex(config)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	index, err := LoadIndex(config)
+	if err != nil {
+		return err
+	}
+
+	touch := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		touch[id] = true
+	}
+
+	now := time.Now()
+	for i := range index.Items {
+		if touch[index.Items[i].ID] {
+			index.Items[i].LastAccessed = now
+		}
+	}
+	return saveIndex(index, config)
+}
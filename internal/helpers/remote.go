@@ -0,0 +1,290 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"vanish/internal/s3"
+	"vanish/internal/types"
+)
+
+// --- Remote Storage ---
+
+// StorageRemote is the backend name of items offloaded to the remote tier.
+const StorageRemote = "remote"
+
+// DefaultRemoteDays is used when remote.days is not set.
+const DefaultRemoteDays = 90
+
+// remoteStorage keeps payloads as gzipped tar objects in an S3-compatible
+// bucket. Uploads and downloads are staged in the cache directory.
+// References look like "s3://<bucket>/<key>".
+type remoteStorage struct {
+	client  *s3.Client
+	prefix  string
+	staging string
+}
+
+func newRemoteStorage(config types.Config) *remoteStorage {
+	remote := config.Remote
+	return &remoteStorage{
+		client: &s3.Client{
+			Endpoint:      remote.Endpoint,
+			Region:        remote.Region,
+			Bucket:        remote.Bucket,
+			AccessKey:     remote.AccessKey,
+			SecretKey:     remote.SecretKey,
+			SessionToken:  remote.SessionToken,
+			VirtualHosted: remote.VirtualHosted,
+		},
+		prefix:  remote.Prefix,
+		staging: ExpandPath(config.Cache.Directory),
+	}
+}
+
+func (s *remoteStorage) Name() string {
+	return StorageRemote
+}
+
+func (s *remoteStorage) Put(src, name string, report *CopyReport) (string, error) {
+	key := s.prefix + name + CompressedSuffix
+	archive := filepath.Join(s.staging, "."+name+".upload")
+
+	file, err := os.Create(archive)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(archive)
+	defer file.Close()
+
+	if err := writeArchive(src, file, report); err != nil {
+		return "", err
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if err := s.client.PutObject(report.context(), key, file, size); err != nil {
+		return "", fmt.Errorf("failed to upload %s: %v", name, err)
+	}
+
+	// Only drop the local copy once the upload succeeded
+	if err := os.RemoveAll(src); err != nil {
+		return "", err
+	}
+	return s.ref(key), nil
+}
+
+func (s *remoteStorage) Get(ref, dst string, report *CopyReport) error {
+	key, err := s.key(ref)
+	if err != nil {
+		return err
+	}
+	archive := filepath.Join(s.staging, "."+filepath.Base(key)+".download")
+
+	file, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive)
+
+	err = s.client.GetObject(report.context(), key, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if errors.Is(err, s3.ErrNotFound) {
+		return &os.PathError{Op: "get", Path: ref, Err: os.ErrNotExist}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", ref, err)
+	}
+
+	if err := extractArchive(archive, dst, report); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+
+	// The payload is back, a failed delete only leaves an orphaned object
+	s.client.DeleteObject(context.Background(), key)
+	return nil
+}
+
+// Delete removes the object. Remote copies cannot be overwritten in place,
+// so passes is ignored.
+func (s *remoteStorage) Delete(ref string, passes int) error {
+	key, err := s.key(ref)
+	if err != nil {
+		return err
+	}
+	return s.client.DeleteObject(context.Background(), key)
+}
+
+// Stat reports the size of the uploaded archive. The number of files is
+// not known without downloading it.
+func (s *remoteStorage) Stat(ref string) (StorageInfo, error) {
+	key, err := s.key(ref)
+	if err != nil {
+		return StorageInfo{}, err
+	}
+	size, err := s.client.HeadObject(context.Background(), key)
+	if errors.Is(err, s3.ErrNotFound) {
+		return StorageInfo{}, &os.PathError{Op: "stat", Path: ref, Err: os.ErrNotExist}
+	}
+	if err != nil {
+		return StorageInfo{}, err
+	}
+	return StorageInfo{Ref: ref, Size: size}, nil
+}
+
+func (s *remoteStorage) List() ([]string, error) {
+	objects, err := s.client.ListObjects(context.Background(), s.prefix)
+	var refs []string
+	for _, object := range objects {
+		refs = append(refs, s.ref(object.Key))
+	}
+	return refs, err
+}
+
+func (s *remoteStorage) ref(key string) string {
+	return "s3://" + s.client.Bucket + "/" + key
+}
+
+// key extracts the object key from a reference to this bucket.
+func (s *remoteStorage) key(ref string) (string, error) {
+	key, found := strings.CutPrefix(ref, "s3://"+s.client.Bucket+"/")
+	if !found || key == "" {
+		return "", fmt.Errorf("invalid remote reference: %s", ref)
+	}
+	return key, nil
+}
+
+// SelectOffloads returns the local, unpinned items due for the remote tier:
+// deleted more than remote.after_days ago, or at least remote.min_size
+// large. It returns nothing when the remote tier is disabled.
+func SelectOffloads(items []types.DeletedItem, config types.Config) []types.DeletedItem {
+	if !config.Remote.Enabled {
+		return nil
+	}
+	var minSize int64
+	if config.Remote.MinSize != "" {
+		minSize, _ = ParseSize(config.Remote.MinSize)
+	}
+	cutoff := time.Now().Add(-time.Duration(config.Remote.AfterDays) * 24 * time.Hour)
+
+	var selected []types.DeletedItem
+	for _, item := range items {
+		if item.IsRemote() || item.IsPinned() {
+			continue
+		}
+		old := config.Remote.AfterDays > 0 && item.DeleteDate.Before(cutoff)
+		large := minSize > 0 && item.Size >= minSize
+		if old || large {
+			selected = append(selected, item)
+		}
+	}
+	return selected
+}
+
+// OffloadItems uploads the payloads of items to the remote tier, removes
+// the local copies and marks the items remote in the index, logging each
+// one as OFFLOAD. Uploaded items expire remote.days later. An item that
+// fails to upload stays in the local cache; every item is tried and the
+// first error is returned with the items that made it.
+func OffloadItems(items []types.DeletedItem, config types.Config) ([]types.DeletedItem, error) {
+	if !config.Remote.Enabled {
+		return nil, fmt.Errorf("the remote tier is disabled, set remote.enabled in the config")
+	}
+	remote := newRemoteStorage(config)
+	cacheDir := ExpandPath(config.Cache.Directory)
+	days := config.Remote.Days
+	if days <= 0 {
+		days = DefaultRemoteDays
+	}
+
+	updated := make(map[string]types.DeletedItem)
+	var offloaded []types.DeletedItem
+	var firstErr error
+	for _, item := range items {
+		if item.IsRemote() {
+			continue
+		}
+		name := fmt.Sprintf("%s-%s", item.ID, filepath.Base(item.OriginalPath))
+		if item.Compressed {
+			name += CompressedSuffix
+		}
+
+		// Directory payloads are uploaded in place, others are staged first
+		local := StorageFor(item, config)
+		src := item.CachePath
+		report := &CopyReport{}
+		report.loseAll(item.MetadataLost)
+		if local.Name() != StorageDirectory {
+			src = filepath.Join(cacheDir, "."+name)
+			if err := local.Get(item.CachePath, src, report); err != nil {
+				LogSimpleOperation("ERROR", fmt.Sprintf("Failed to offload %s: %v", item.OriginalPath, err), config)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+		}
+
+		ref, err := remote.Put(src, name, report)
+		if err != nil {
+			LogSimpleOperation("ERROR", fmt.Sprintf("Failed to offload %s: %v", item.OriginalPath, err), config)
+			if firstErr == nil {
+				firstErr = err
+			}
+			if src != item.CachePath {
+				// The staged copy left its backend, keep it as a directory entry
+				if ref, err := storageByName(StorageDirectory, config).Put(src, name, report); err == nil {
+					item.CachePath, item.Storage = ref, ""
+					item.MetadataLost = report.LostMetadata()
+					item.Skipped = append(item.Skipped, report.Skipped()...)
+					updated[item.ID] = item
+				}
+			}
+			continue
+		}
+
+		item.CachePath = ref
+		item.Storage = StorageRemote
+		item.MetadataLost = report.LostMetadata()
+		item.Skipped = append(item.Skipped, report.Skipped()...)
+		item.ExpiresAt = time.Now().Add(time.Duration(days) * 24 * time.Hour)
+		updated[item.ID] = item
+		offloaded = append(offloaded, item)
+		LogOperation("OFFLOAD", item, config)
+	}
+
+	if len(updated) == 0 {
+		return offloaded, firstErr
+	}
+	unlock, err := lockIndex(config)
+	if err != nil {
+		return offloaded, err
+	}
+	defer unlock()
+	index, err := LoadIndex(config)
+	if err != nil {
+		return offloaded, err
+	}
+	for i, item := range index.Items {
+		if update, ok := updated[item.ID]; ok {
+			index.Items[i] = update
+		}
+	}
+	if err := SaveIndex(index, config); err != nil {
+		return offloaded, err
+	}
+	return offloaded, firstErr
+}
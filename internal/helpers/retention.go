@@ -0,0 +1,228 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"vanish/internal/types"
+)
+
+// --- Retention Helpers ---
+
+// MatchRetentionRule returns the first retention rule in config order that
+// matches the item at path, or nil when none does. size is the total size
+// of the item, including directory contents.
+func MatchRetentionRule(path string, info os.FileInfo, size int64, config types.Config) *types.RetentionRule {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+
+	for i := range config.Retention.Rules {
+		rule := &config.Retention.Rules[i]
+		if ruleMatches(rule, absPath, info, size) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func ruleMatches(rule *types.RetentionRule, absPath string, info os.FileInfo, size int64) bool {
+	if rule.Match != "" && !matchesNameOrPath(rule.Match, absPath) {
+		return false
+	}
+
+	if rule.Path != "" {
+		prefix := ExpandPath(rule.Path)
+		if absPath != prefix && !isWithin(absPath, prefix) {
+			return false
+		}
+	}
+
+	if rule.Type != "" && rule.Type != fileInfoType(info) {
+		return false
+	}
+
+	if rule.MinSize != "" {
+		if minSize, err := ParseSize(rule.MinSize); err != nil || size < minSize {
+			return false
+		}
+	}
+	if rule.MaxSize != "" {
+		if maxSize, err := ParseSize(rule.MaxSize); err != nil || size > maxSize {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesNameOrPath matches a glob against the name of absPath, or against
+// the whole path when the pattern contains a separator.
+func matchesNameOrPath(pattern, absPath string) bool {
+	subject := filepath.Base(absPath)
+	if strings.Contains(pattern, string(filepath.Separator)) {
+		subject = absPath
+		pattern = ExpandPath(pattern)
+	}
+	ok, _ := filepath.Match(pattern, subject)
+	return ok
+}
+
+// IsPermanentPath reports whether path matches one of the cache.permanent
+// patterns, meaning it is deleted directly instead of being cached.
+func IsPermanentPath(path string, config types.Config) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	for _, pattern := range config.Cache.Permanent {
+		if matchesNameOrPath(pattern, absPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileInfoType returns "symlink", "directory" or "file" for info.
+func fileInfoType(info os.FileInfo) string {
+	if info.Mode()&os.ModeSymlink != 0 {
+		return "symlink"
+	}
+	if info.IsDir() {
+		return "directory"
+	}
+	return "file"
+}
+
+// RetentionDays returns how many days an item matched by rule is kept.
+// A nil rule, or a rule without days, falls back to cache.days.
+func RetentionDays(rule *types.RetentionRule, config types.Config) int {
+	if rule != nil && rule.Days > 0 {
+		return rule.Days
+	}
+	return config.Cache.Days
+}
+
+// ExpiryDate returns when an item leaves the cache: its own expiry when a
+// retention rule set one at delete time, otherwise cache.days after it
+// was deleted.
+func ExpiryDate(item types.DeletedItem, config types.Config) time.Time {
+	if !item.ExpiresAt.IsZero() {
+		return item.ExpiresAt
+	}
+	return item.DeleteDate.Add(time.Duration(config.Cache.Days) * 24 * time.Hour)
+}
+
+// IsExpired reports whether an unpinned item is past its expiry date.
+func IsExpired(item types.DeletedItem, config types.Config) bool {
+	return !item.IsPinned() && time.Now().After(ExpiryDate(item, config))
+}
+
+// PurgeExpired removes every unpinned item past its expiry date from the
+// cache and the index, logging each one as CLEANUP. It returns the removed
+// items.
+func PurgeExpired(config types.Config) ([]types.DeletedItem, error) {
+	unlock, err := lockIndex(config)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	index, err := LoadIndex(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var remainingItems, expiredItems []types.DeletedItem
+	for _, item := range index.Items {
+		if IsExpired(item, config) {
+			if err := RemoveCachedItem(item, config); err != nil && item.IsRemote() && !os.IsNotExist(err) {
+				// Keep the entry so the bucket object is not orphaned
+				LogSimpleOperation("ERROR", fmt.Sprintf("Failed to delete remote copy of %s: %v", item.OriginalPath, err), config)
+				remainingItems = append(remainingItems, item)
+				continue
+			}
+			expiredItems = append(expiredItems, item)
+			LogOperation("CLEANUP", item, config)
+		} else {
+			remainingItems = append(remainingItems, item)
+		}
+	}
+	if len(expiredItems) == 0 {
+		return nil, nil
+	}
+
+	recordHistory(HistoryPurged, expiredItems, config)
+
+	index.Items = remainingItems
+	return expiredItems, SaveIndex(index, config)
+}
+
+// validRuleTypes lists the accepted values of a retention rule type.
+var validRuleTypes = []string{"file", "directory", "symlink"}
+
+// ValidateRetentionRules checks the retention rules for values the TOML
+// decoder cannot verify.
+func ValidateRetentionRules(rules []types.RetentionRule) error {
+	for i, rule := range rules {
+		name := fmt.Sprintf("retention.rules[%d]", i)
+		if rule.Name != "" {
+			name = fmt.Sprintf("retention rule %q", rule.Name)
+		}
+
+		if rule.Match == "" && rule.Path == "" && rule.Type == "" && rule.MinSize == "" && rule.MaxSize == "" {
+			return fmt.Errorf("%s: needs at least one of match, path, type, min_size or max_size", name)
+		}
+		if rule.Match != "" {
+			if _, err := filepath.Match(rule.Match, ""); err != nil {
+				return fmt.Errorf("%s: invalid match pattern %q", name, rule.Match)
+			}
+		}
+		if rule.Type != "" && !slices.Contains(validRuleTypes, rule.Type) {
+			return fmt.Errorf("%s: unknown type %q (options: %s)", name, rule.Type, strings.Join(validRuleTypes, ", "))
+		}
+		for _, size := range []string{rule.MinSize, rule.MaxSize} {
+			if size != "" {
+				if _, err := ParseSize(size); err != nil {
+					return fmt.Errorf("%s: %v", name, err)
+				}
+			}
+		}
+		if rule.Days < 0 {
+			return fmt.Errorf("%s: days cannot be negative", name)
+		}
+		if rule.SkipCache && (rule.Compress || rule.Sensitive) {
+			return fmt.Errorf("%s: skip_cache cannot be combined with compress or sensitive", name)
+		}
+	}
+	return nil
+}
+
+// DescribeRule returns a short, human-readable label for a retention rule.
+func DescribeRule(rule *types.RetentionRule) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	var parts []string
+	if rule.Match != "" {
+		parts = append(parts, rule.Match)
+	}
+	if rule.Path != "" {
+		parts = append(parts, rule.Path)
+	}
+	if rule.Type != "" {
+		parts = append(parts, rule.Type)
+	}
+	if rule.MinSize != "" {
+		parts = append(parts, ">="+rule.MinSize)
+	}
+	if rule.MaxSize != "" {
+		parts = append(parts, "<="+rule.MaxSize)
+	}
+	return strings.Join(parts, " ")
+}
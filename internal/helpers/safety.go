@@ -0,0 +1,235 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"vanish/internal/types"
+)
+
+// --- Safety Helpers ---
+
+// CheckPathSafety decides whether a path may be moved to the cache.
+// refused is set for targets that can never be deleted, such as the cache
+// itself or a directory containing it. protected is set for targets that
+// need --force-protected and a typed confirmation. reason describes why.
+func CheckPathSafety(path string, config types.Config) (refused, protected bool, reason string) {
+	target := resolvePath(path)
+	cacheDir := resolvePath(ExpandPath(config.Cache.Directory))
+
+	// Moving the cache (or anything holding it) into the cache is impossible
+	if target == cacheDir {
+		return true, false, "this is the vanish cache directory"
+	}
+	if isWithin(cacheDir, target) {
+		return true, false, "contains the vanish cache directory"
+	}
+	if isWithin(target, cacheDir) {
+		return true, false, "is inside the vanish cache directory"
+	}
+
+	if target == string(filepath.Separator) {
+		return false, true, "filesystem root"
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil && target == resolvePath(homeDir) {
+		return false, true, "home directory"
+	}
+
+	configDir := resolvePath(filepath.Dir(GetConfigPath()))
+	if target == configDir || isWithin(target, configDir) {
+		return false, true, "vanish config directory"
+	}
+
+	if cwd, err := os.Getwd(); err == nil {
+		cwd = resolvePath(cwd)
+		if target == cwd || isWithin(cwd, target) {
+			return false, true, "current working directory"
+		}
+	}
+
+	if IsMountPoint(target) {
+		return false, true, "mount point"
+	}
+
+	for _, pattern := range config.Safety.Protected {
+		if matchesProtectedPattern(target, resolvePath(ExpandPath(pattern))) {
+			return false, true, fmt.Sprintf("matches protected pattern %q", pattern)
+		}
+	}
+
+	return false, false, ""
+}
+
+// ErrNoSpace is wrapped by the error of CheckFreeSpace.
+var ErrNoSpace = errors.New("not enough free space")
+
+// CheckFreeSpace returns an error when the items that must be copied into
+// the cache do not fit in the free space left on its filesystem. Items on
+// the same filesystem as the cache are renamed and permanent items are
+// never cached, so neither needs any space.
+func CheckFreeSpace(infos []types.FileInfo, config types.Config) error {
+	cacheDir := ExpandPath(config.Cache.Directory)
+
+	var needed int64
+	for _, info := range infos {
+		if info.Exists && !info.Permanent && !SameFilesystem(info.Path, cacheDir) {
+			needed += info.Size
+		}
+	}
+	if needed == 0 {
+		return nil
+	}
+
+	free, err := FreeSpace(cacheDir)
+	if err != nil {
+		return nil // Not knowing the free space is not a reason to refuse
+	}
+	if needed > free {
+		return fmt.Errorf("%w in the cache filesystem: need %s, only %s available",
+			ErrNoSpace, FormatBytes(needed), FormatBytes(free))
+	}
+	return nil
+}
+
+// ConfirmationReasons lists why deleting infos needs a typed
+// confirmation: protected paths, and going past the safety limits. An
+// empty result means a plain y/n prompt is enough.
+func ConfirmationReasons(infos []types.FileInfo, config types.Config) []string {
+	var reasons []string
+	safety := config.Safety
+
+	itemCount := 0
+	var totalSize int64
+	for _, info := range infos {
+		if !info.Exists {
+			continue
+		}
+		itemCount++
+		totalSize += info.Size
+
+		if info.Protected {
+			reasons = append(reasons, fmt.Sprintf("%s is protected (%s)", info.Path, info.Reason))
+		}
+		if safety.MaxFilesInDir > 0 && info.IsDirectory && info.FileCount > safety.MaxFilesInDir {
+			reasons = append(reasons, fmt.Sprintf("%s contains %d files (limit %d)", info.Path, info.FileCount, safety.MaxFilesInDir))
+		}
+	}
+
+	if safety.MaxItems > 0 && itemCount > safety.MaxItems {
+		reasons = append(reasons, fmt.Sprintf("%d items selected (limit %d)", itemCount, safety.MaxItems))
+	}
+	if safety.MaxBytes != "" {
+		if maxBytes, err := ParseSize(safety.MaxBytes); err == nil && totalSize > maxBytes {
+			reasons = append(reasons, fmt.Sprintf("%s selected (limit %s)", FormatBytes(totalSize), FormatBytes(maxBytes)))
+		}
+	}
+
+	return reasons
+}
+
+// IsMountPoint reports whether path is a directory on a different device
+// than its parent directory.
+func IsMountPoint(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	parentInfo, err := os.Lstat(filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	parentStat, parentOk := parentInfo.Sys().(*syscall.Stat_t)
+	if !ok || !parentOk {
+		return false
+	}
+	return stat.Dev != parentStat.Dev
+}
+
+// resolvePath returns the absolute, symlink-free form of path. The final
+// element is not resolved so that a symlink is judged as the link itself.
+func resolvePath(path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	parent, base := filepath.Dir(absPath), filepath.Base(absPath)
+	if absPath == parent {
+		return absPath
+	}
+	if resolved, err := filepath.EvalSymlinks(parent); err == nil {
+		parent = resolved
+	}
+	return filepath.Join(parent, base)
+}
+
+// isWithin reports whether path lies strictly inside dir.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// matchesProtectedPattern reports whether target, or any directory above
+// it, matches the glob pattern. Protecting a directory therefore protects
+// everything inside it.
+func matchesProtectedPattern(target, pattern string) bool {
+	for path := target; ; path = filepath.Dir(path) {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+		if path == filepath.Dir(path) {
+			return false
+		}
+	}
+}
+
+// FreeSpace returns the number of bytes available to unprivileged users on
+// the filesystem holding path. A path that does not exist yet is measured
+// on its nearest existing parent.
+func FreeSpace(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(existingAncestor(path), &stat); err != nil {
+		return 0, err
+	}
+	return int64(uint64(stat.Bavail) * uint64(stat.Bsize)), nil
+}
+
+// SameFilesystem reports whether a and b live on the same device, in which
+// case moving between them is a rename that needs no extra space.
+func SameFilesystem(a, b string) bool {
+	infoA, errA := os.Lstat(existingAncestor(a))
+	infoB, errB := os.Lstat(existingAncestor(b))
+	if errA != nil || errB != nil {
+		return false
+	}
+
+	statA, okA := infoA.Sys().(*syscall.Stat_t)
+	statB, okB := infoB.Sys().(*syscall.Stat_t)
+	if !okA || !okB {
+		return false
+	}
+	return statA.Dev == statB.Dev
+}
+
+// existingAncestor returns path, or its nearest parent that exists.
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Lstat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
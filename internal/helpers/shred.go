@@ -0,0 +1,123 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+
+	"vanish/internal/types"
+)
+
+// --- Shred Helpers ---
+
+// DefaultShredPasses is used when cache.shred_passes is not set.
+const DefaultShredPasses = 3
+
+// ShredCaveat explains why shredding cannot be guaranteed everywhere.
+const ShredCaveat = "Overwriting in place cannot be guaranteed on copy-on-write filesystems " +
+	"(btrfs, ZFS, APFS), on SSDs with wear levelling, or when snapshots and backups exist."
+
+// ShouldShred reports whether removing item from the cache must overwrite
+// its payload first, either because it is flagged sensitive or because
+// cache.shred is enabled.
+func ShouldShred(item types.DeletedItem, config types.Config) bool {
+	return item.Sensitive || config.Cache.Shred
+}
+
+// ShredPasses returns the number of overwrite passes to use.
+func ShredPasses(config types.Config) int {
+	if config.Cache.ShredPasses > 0 {
+		return config.Cache.ShredPasses
+	}
+	return DefaultShredPasses
+}
+
+// ShredPath overwrites every regular file under path with random data the
+// given number of times, syncing after each pass, and then removes path.
+// Symlinks are removed without touching their targets.
+func ShredPath(path string, passes int) error {
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			return overwriteFile(p, info.Size(), passes)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+// overwriteFile writes size random bytes over the file passes times.
+func overwriteFile(path string, size int64, passes int) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		// Read-only payloads still have to be destroyed
+		if chmodErr := os.Chmod(path, 0600); chmodErr != nil {
+			return err
+		}
+		if file, err = os.OpenFile(path, os.O_WRONLY, 0); err != nil {
+			return err
+		}
+	}
+	defer file.Close()
+
+	for pass := 0; pass < passes; pass++ {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(file, rand.Reader, size); err != nil {
+			return err
+		}
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+	return file.Truncate(0)
+}
+
+// ShredItems overwrites and removes the payloads of the given cached items
+// and drops them from the index, whatever their pin or sensitive flag. It
+// stops at the first failure and returns the items destroyed so far.
+func ShredItems(items []types.DeletedItem, config types.Config) ([]types.DeletedItem, error) {
+	passes := ShredPasses(config)
+	shredIDs := make(map[string]bool, len(items))
+
+	var shredded []types.DeletedItem
+	var shredErr error
+	for _, item := range items {
+		if err := StorageFor(item, config).Delete(item.CachePath, passes); err != nil && !os.IsNotExist(err) {
+			shredErr = err
+			break
+		}
+		shredIDs[item.ID] = true
+		shredded = append(shredded, item)
+		LogOperation("SHRED", item, config)
+	}
+	recordHistory(HistoryPurged, shredded, config)
+
+	unlock, err := lockIndex(config)
+	if err != nil {
+		return shredded, err
+	}
+	defer unlock()
+	index, err := LoadIndex(config)
+	if err != nil {
+		return shredded, err
+	}
+	var remainingItems []types.DeletedItem
+	for _, item := range index.Items {
+		if !shredIDs[item.ID] {
+			remainingItems = append(remainingItems, item)
+		}
+	}
+	index.Items = remainingItems
+	if err := SaveIndex(index, config); err != nil {
+		return shredded, err
+	}
+	return shredded, shredErr
+}
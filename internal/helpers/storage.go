@@ -0,0 +1,193 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vanish/internal/types"
+)
+
+// --- Storage Backends ---
+
+// Storage backend names, as used by cache.storage and DeletedItem.Storage.
+const (
+	StorageDirectory = "directory"
+	StorageBundle    = "bundle"
+)
+
+// errNotPutBack is wrapped by the error of a Put that failed after taking
+// src apart and could not put it back together.
+var errNotPutBack = errors.New("the payload could not be put back")
+
+// StorageBackends lists the valid values of cache.storage.
+var StorageBackends = []string{StorageDirectory, StorageBundle, StorageDedup}
+
+// DefaultBundleMaxSize is used when cache.bundle_max_size is not set.
+const DefaultBundleMaxSize = "1MB"
+
+// Storage keeps item payloads in the cache. The index records, for every
+// item, the backend that holds its payload and the reference Put returned.
+type Storage interface {
+	// Name returns the backend name recorded in the index.
+	Name() string
+	// Put moves the file, directory or symlink at src into storage under
+	// name and returns the reference to store in the index. What could not
+	// be kept, and how content was copied, is recorded in report, which may
+	// be nil. When Put fails src is left as it was, unless the error wraps
+	// errNotPutBack.
+	Put(src, name string, report *CopyReport) (string, error)
+	// Get moves the payload behind ref back to dst, which must not exist,
+	// and removes it from storage, recording in report like Put.
+	Get(ref, dst string, report *CopyReport) error
+	// Delete removes the payload behind ref, overwriting it the given
+	// number of times first when passes is above zero.
+	Delete(ref string, passes int) error
+	// Stat describes the payload behind ref, or returns an error satisfying
+	// os.IsNotExist when it is gone.
+	Stat(ref string) (StorageInfo, error)
+	// List returns the references of every payload in storage.
+	List() ([]string, error)
+}
+
+// StorageInfo describes a stored payload.
+type StorageInfo struct {
+	Ref   string
+	Size  int64 // Bytes of file content
+	Files int   // Regular files in the payload
+}
+
+// StorageFor returns the backend holding item's payload.
+func StorageFor(item types.DeletedItem, config types.Config) Storage {
+	return storageByName(item.Storage, config)
+}
+
+// storageForNew returns the backend a new payload of the given size should
+// go to. Only payloads up to cache.bundle_max_size are bundled, larger ones
+// would make the daily archive slow to read back.
+func storageForNew(size int64, config types.Config) Storage {
+	if config.Cache.Storage == StorageDedup {
+		return storageByName(StorageDedup, config)
+	}
+	if config.Cache.Storage != StorageBundle {
+		return storageByName(StorageDirectory, config)
+	}
+	limit, err := ParseSize(config.Cache.BundleMaxSize)
+	if config.Cache.BundleMaxSize == "" || err != nil {
+		limit, _ = ParseSize(DefaultBundleMaxSize)
+	}
+	if size > limit {
+		return storageByName(StorageDirectory, config)
+	}
+	return storageByName(StorageBundle, config)
+}
+
+func storageByName(name string, config types.Config) Storage {
+	cacheDir := ExpandPath(config.Cache.Directory)
+	switch name {
+	case StorageBundle:
+		return &bundleStorage{dir: filepath.Join(cacheDir, "bundles")}
+	case StorageDedup:
+		return &dedupStorage{dir: filepath.Join(cacheDir, "dedup")}
+	case StorageRemote:
+		return newRemoteStorage(config)
+	}
+	return &directoryStorage{dir: cacheDir}
+}
+
+// PayloadExists reports whether the payload of item is still in storage.
+func PayloadExists(item types.DeletedItem, config types.Config) bool {
+	_, err := StorageFor(item, config).Stat(item.CachePath)
+	return err == nil
+}
+
+// directoryStorage keeps every payload as its own entry of the cache
+// directory. References are absolute paths.
+type directoryStorage struct {
+	dir string
+}
+
+func (s *directoryStorage) Name() string {
+	return StorageDirectory
+}
+
+func (s *directoryStorage) Put(src, name string, report *CopyReport) (string, error) {
+	dst := filepath.Join(s.dir, name)
+	stat, err := os.Lstat(src)
+	if err != nil {
+		return "", err
+	}
+
+	if stat.Mode()&os.ModeSymlink != 0 {
+		if err := moveSymlink(src, dst, report); err != nil {
+			return "", fmt.Errorf("failed to move symlink: %w", err)
+		}
+	} else if stat.IsDir() {
+		if err := moveDirectory(src, dst, report); err != nil {
+			return "", fmt.Errorf("failed to move directory: %w", err)
+		}
+	} else {
+		if err := moveFile(src, dst, report); err != nil {
+			return "", fmt.Errorf("failed to move file: %w", err)
+		}
+	}
+	return dst, nil
+}
+
+func (s *directoryStorage) Get(ref, dst string, report *CopyReport) error {
+	stat, err := os.Lstat(ref)
+	if err != nil {
+		return err
+	}
+
+	if stat.Mode()&os.ModeSymlink != 0 {
+		return restoreSymlink(ref, dst, report)
+	} else if stat.IsDir() {
+		return moveDirectory(ref, dst, report)
+	}
+	return moveFile(ref, dst, report)
+}
+
+func (s *directoryStorage) Delete(ref string, passes int) error {
+	if passes > 0 {
+		return ShredPath(ref, passes)
+	}
+	if _, err := os.Lstat(ref); err != nil {
+		return err
+	}
+	return os.RemoveAll(ref)
+}
+
+func (s *directoryStorage) Stat(ref string) (StorageInfo, error) {
+	stat, err := os.Lstat(ref)
+	if err != nil {
+		return StorageInfo{}, err
+	}
+	info := StorageInfo{Ref: ref, Size: stat.Size(), Files: 1}
+	if stat.IsDir() {
+		info.Size, _ = GetDirectorySize(ref)
+		info.Files, _ = CountFilesInDirectory(ref)
+	}
+	return info, nil
+}
+
+func (s *directoryStorage) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	for _, entry := range entries {
+		// Payload names start with the item ID, which skips the index,
+		// the bundle and log directories and temporary files
+		id, _, found := strings.Cut(entry.Name(), "-")
+		if !found || id == "" || strings.Trim(id, "0123456789") != "" {
+			continue
+		}
+		refs = append(refs, filepath.Join(s.dir, entry.Name()))
+	}
+	return refs, nil
+}
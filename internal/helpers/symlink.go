@@ -18,6 +18,31 @@ func IsSymlink(path string) (bool, error) {
 // MoveSymlink handles moving a symbolic link to cache
 // It reads the link target and recreates the symlink at the destination
 func MoveSymlink(src, dst string) error {
+	return moveSymlink(src, dst, nil)
+}
+
+func moveSymlink(src, dst string, report *CopyReport) error {
+	// Recreate the symlink at destination with the original's metadata
+	if err := copySymlink(src, dst, report); err != nil {
+		return err
+	}
+
+	// Remove the original symlink
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("failed to remove original symlink: %w", err)
+	}
+
+	return nil
+}
+
+// copySymlink recreates the symlink at src at dst, with its ownership,
+// times and extended attributes where possible.
+func copySymlink(src, dst string, report *CopyReport) error {
+	meta, err := readMetadata(src, report)
+	if err != nil {
+		return fmt.Errorf("failed to read symlink: %w", err)
+	}
+
 	// Read the link target
 	linkTarget, err := os.Readlink(src)
 	if err != nil {
@@ -29,11 +54,7 @@ func MoveSymlink(src, dst string) error {
 		return fmt.Errorf("failed to create symlink: %w", err)
 	}
 
-	// Remove the original symlink
-	if err := os.Remove(src); err != nil {
-		return fmt.Errorf("failed to remove original symlink: %w", err)
-	}
-
+	meta.apply(dst, report)
 	return nil
 }
 
@@ -60,12 +81,10 @@ func GetFileInfo(path string) (os.FileInfo, bool, error) {
 
 // RestoreSymlink restores a symbolic link from cache back to its original location
 func RestoreSymlink(cachePath, originalPath string) error {
-	// Read the link target from cache
-	linkTarget, err := os.Readlink(cachePath)
-	if err != nil {
-		return fmt.Errorf("failed to read cached symlink: %w", err)
-	}
+	return restoreSymlink(cachePath, originalPath, nil)
+}
 
+func restoreSymlink(cachePath, originalPath string, report *CopyReport) error {
 	// Create directory for original path if needed
 	originalDir := filepath.Dir(originalPath)
 	if err := os.MkdirAll(originalDir, 0755); err != nil {
@@ -73,7 +92,7 @@ func RestoreSymlink(cachePath, originalPath string) error {
 	}
 
 	// Recreate the symlink at original location
-	if err := os.Symlink(linkTarget, originalPath); err != nil {
+	if err := copySymlink(cachePath, originalPath, report); err != nil {
 		return fmt.Errorf("failed to restore symlink: %w", err)
 	}
 
@@ -0,0 +1,413 @@
+// Package s3 is a minimal client for S3-compatible object stores such as
+// AWS S3 and MinIO. It signs requests with AWS Signature Version 4 and
+// only covers what the vanish remote tier needs: put (in parts for large
+// objects), get, head, delete and list objects in a single bucket.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emptyHash is the SHA-256 of an empty payload.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+const (
+	// maxParts is the most parts a multipart upload may have.
+	maxParts = 10000
+	// maxObjectSize is the largest object S3 stores, 5 TiB.
+	maxObjectSize = 5 << 40
+)
+
+// partSize is the size of the parts of a multipart upload, raised for
+// objects that would need more than maxParts of them. Objects up to one
+// part are sent with a single PUT, which S3 limits to 5 GiB.
+var partSize int64 = 64 << 20
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Client talks to one bucket of an S3-compatible service.
+type Client struct {
+	Endpoint      string // Service URL, e.g. "https://s3.eu-west-1.amazonaws.com" or "http://localhost:9000"
+	Region        string
+	Bucket        string
+	AccessKey     string
+	SecretKey     string
+	SessionToken  string // Optional, for temporary credentials
+	VirtualHosted bool   // Address the bucket as <bucket>.<host> instead of <host>/<bucket>
+	HTTP          *http.Client
+}
+
+// Object describes an entry of a bucket listing.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Error is an error response from the service.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// PutObject uploads size bytes from body under key. body is read twice,
+// once to hash it for the signature and once to send it. Objects larger
+// than one part go up as a multipart upload.
+func (c *Client) PutObject(ctx context.Context, key string, body io.ReadSeeker, size int64) error {
+	if size > maxObjectSize {
+		return fmt.Errorf("s3: %d bytes is over the 5 TiB object size limit", size)
+	}
+	if size > partSize {
+		return c.putMultipart(ctx, key, body, size)
+	}
+	_, err := c.putPart(ctx, key, nil, body, 0, size)
+	return err
+}
+
+// putMultipart uploads an object in parts, aborting the upload if any
+// step fails so that the service does not keep the parts sent.
+func (c *Client) putMultipart(ctx context.Context, key string, body io.ReadSeeker, size int64) error {
+	resp, err := c.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, 0, emptyHash)
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadId string
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil || initiated.UploadId == "" {
+		return fmt.Errorf("s3: invalid multipart upload response: %v", err)
+	}
+	upload := url.Values{"uploadId": {initiated.UploadId}}
+
+	type part struct {
+		PartNumber int
+		ETag       string
+	}
+	var complete struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}
+	chunk := max(partSize, (size+maxParts-1)/maxParts)
+	for offset := int64(0); offset < size; offset += chunk {
+		number := len(complete.Parts) + 1
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": upload["uploadId"]}
+		etag, err := c.putPart(ctx, key, query, body, offset, min(chunk, size-offset))
+		if err != nil {
+			c.abortMultipart(ctx, key, upload)
+			return err
+		}
+		complete.Parts = append(complete.Parts, part{PartNumber: number, ETag: etag})
+	}
+
+	data, err := xml.Marshal(complete)
+	if err != nil {
+		c.abortMultipart(ctx, key, upload)
+		return err
+	}
+	resp, err = c.do(ctx, http.MethodPost, key, upload, io.NopCloser(bytes.NewReader(data)), int64(len(data)), hashHex(string(data)))
+	if err != nil {
+		c.abortMultipart(ctx, key, upload)
+		return err
+	}
+	defer resp.Body.Close()
+
+	// A completion that fails late still comes back as 200 OK
+	var result struct {
+		XMLName xml.Name
+		Code    string
+		Message string
+	}
+	if xml.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&result) == nil && result.XMLName.Local == "Error" {
+		c.abortMultipart(ctx, key, upload)
+		return &Error{StatusCode: resp.StatusCode, Code: result.Code, Message: result.Message}
+	}
+	return nil
+}
+
+// abortMultipart drops the parts of an upload, even once ctx is
+// cancelled.
+func (c *Client) abortMultipart(ctx context.Context, key string, upload url.Values) {
+	resp, err := c.do(context.WithoutCancel(ctx), http.MethodDelete, key, upload, nil, 0, emptyHash)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// putPart uploads size bytes of body from offset, as a whole object or as
+// the part of a multipart upload named in query, and returns its ETag.
+func (c *Client) putPart(ctx context.Context, key string, query url.Values, body io.ReadSeeker, offset, size int64) (string, error) {
+	if _, err := body.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	if _, err := io.CopyN(hash, body, size); err != nil {
+		return "", err
+	}
+	if _, err := body.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	resp, err := c.do(ctx, http.MethodPut, key, query, io.NopCloser(io.LimitReader(body, size)), size, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// GetObject downloads the object under key into w.
+func (c *Client) GetObject(ctx context.Context, key string, w io.Writer) error {
+	resp, err := c.do(ctx, http.MethodGet, key, nil, nil, 0, emptyHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// HeadObject returns the size of the object under key, or ErrNotFound.
+func (c *Client) HeadObject(ctx context.Context, key string) (int64, error) {
+	resp, err := c.do(ctx, http.MethodHead, key, nil, nil, 0, emptyHash)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+// DeleteObject removes the object under key. Deleting a missing object is
+// not an error.
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, nil, nil, 0, emptyHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ListObjects returns every object whose key starts with prefix, following
+// continuation tokens until the listing is complete.
+func (c *Client) ListObjects(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := c.do(ctx, http.MethodGet, "", query, nil, 0, emptyHash)
+		if err != nil {
+			return objects, err
+		}
+		var result struct {
+			IsTruncated           bool
+			NextContinuationToken string
+			Contents              []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return objects, fmt.Errorf("s3: invalid listing: %v", err)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, Object{Key: content.Key, Size: content.Size, LastModified: content.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends a signed request for key (empty for the bucket itself) and
+// returns the response, or an *Error for any non-2xx status.
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, body io.ReadCloser, size int64, payloadHash string) (*http.Response, error) {
+	endpoint, err := url.Parse(c.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", c.Endpoint)
+	}
+
+	host := endpoint.Host
+	path := "/" + c.Bucket
+	if c.VirtualHosted {
+		host = c.Bucket + "." + endpoint.Host
+		path = ""
+	}
+	path += "/" + key
+	escapedPath := uriEncode(path, false)
+	rawQuery := canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.Scheme+"://"+host+"/", body)
+	if err != nil {
+		return nil, err
+	}
+	// RawPath makes net/url send the path exactly as signed
+	req.URL.Path = path
+	req.URL.RawPath = escapedPath
+	req.URL.RawQuery = rawQuery
+	req.Host = host
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	c.sign(req, escapedPath, rawQuery, payloadHash, time.Now().UTC())
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodHead {
+		return nil, ErrNotFound
+	}
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	xml.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(apiErr)
+	if apiErr.Code == "NoSuchKey" {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return nil, apiErr
+}
+
+// sign adds the Signature Version 4 headers to req.
+func (c *Client) sign(req *http.Request, path, rawQuery, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		rawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashHex(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), date)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query sorted by key, as SigV4 requires.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes every byte except the unreserved characters,
+// and '/' too unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			b.WriteString("%" + strings.ToUpper(strconv.FormatUint(uint64(ch)|0x100, 16)[1:]))
+		}
+	}
+	return b.String()
+}
+
+func hashHex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,526 @@
+// Package server exposes the cache operations as a small JSON API over a
+// Unix socket, so that editors and file managers can delete to and restore
+// from the vanish cache without driving the TUI. Access control comes from
+// the socket permissions: only the owner can connect.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+	"vanish/pkg/vanish"
+)
+
+// pollInterval is how often the index is checked for changes made by other
+// vx processes.
+const pollInterval = 2 * time.Second
+
+// Event is sent to /events subscribers whenever the cache changes.
+type Event struct {
+	Type  string              `json:"type"` // "deleted", "restored", "purged" or "changed"
+	Time  time.Time           `json:"time"`
+	Items []types.DeletedItem `json:"items,omitempty"`
+}
+
+// Stats summarises the cache for the /stats endpoint.
+type Stats struct {
+	Items       int       `json:"items"`
+	Files       int       `json:"files"`
+	Directories int       `json:"directories"`
+	TotalSize   int64     `json:"total_size"`
+	Expired     int       `json:"expired"`
+	Pinned      int       `json:"pinned"`
+	Oldest      time.Time `json:"oldest,omitzero"`
+	Newest      time.Time `json:"newest,omitzero"`
+}
+
+// ItemError reports why a single path or pattern could not be processed.
+type ItemError struct {
+	Target string `json:"target"`
+	Error  string `json:"error"`
+}
+
+// Server serves the JSON API for one config.
+type Server struct {
+	config types.Config
+	trash  *vanish.Trash
+
+	subMu       sync.Mutex
+	subscribers map[chan Event]struct{}
+	indexMod    time.Time
+}
+
+// New returns a Server operating on the cache described by config.
+func New(config types.Config) *Server {
+	return &Server{
+		config:      config,
+		trash:       vanish.New(config),
+		subscribers: make(map[chan Event]struct{}),
+		indexMod:    indexModTime(config),
+	}
+}
+
+// DefaultSocketPath returns $XDG_RUNTIME_DIR/vanish.sock or, when
+// XDG_RUNTIME_DIR is not set, a socket in a private directory under the
+// user's cache directory.
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "vanish.sock")
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "vanish-run", "vanish.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("vanish-%d", os.Getuid()), "vanish.sock")
+}
+
+// ListenAndServe serves the API on the Unix socket at path until ctx is
+// cancelled. A missing directory for the socket is created private to the
+// user, and one that another user could swap the socket in is refused. A
+// stale socket left by a crashed server is replaced, but a live one is
+// never taken over.
+func (s *Server) ListenAndServe(ctx context.Context, path string) error {
+	if err := checkSocketDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("another server is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// The socket is created 0600 rather than chmodded after the fact, so
+	// no other user can connect in between
+	oldMask := syscall.Umask(0177)
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(oldMask)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	// Request contexts derive from ctx so that open event streams end on
+	// shutdown instead of holding it up
+	httpServer := &http.Server{
+		Handler:     s.Handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+	go s.watchIndex(ctx)
+
+	if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// checkSocketDir creates dir 0700 if it is missing, and refuses it unless
+// it belongs to the user or root and others cannot replace what is in it.
+func checkSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if uid := int(stat.Uid); uid != os.Getuid() && uid != 0 {
+		return fmt.Errorf("socket directory %s belongs to another user", dir)
+	}
+	if info.Mode().Perm()&0022 != 0 && info.Mode()&os.ModeSticky == 0 {
+		return fmt.Errorf("socket directory %s is writable by other users", dir)
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler implementing the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items", s.handleList)
+	mux.HandleFunc("GET /items/{pattern}", s.handleInfo)
+	mux.HandleFunc("POST /delete", s.handleDelete)
+	mux.HandleFunc("POST /restore", s.handleRestore)
+	mux.HandleFunc("POST /purge", s.handlePurge)
+	mux.HandleFunc("GET /stats", s.handleStats)
+	mux.HandleFunc("GET /events", s.handleEvents)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+		// Once per request, however many items it changed
+		s.trash.FlushMetrics()
+	})
+}
+
+// handleList returns every cached item, or the ones matching ?q=pattern.
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	var filter vanish.Filter
+	if pattern := r.URL.Query().Get("q"); pattern != "" {
+		filter.Patterns = []string{pattern}
+	}
+	items, err := s.trash.List(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": nonNil(items)})
+}
+
+// handleInfo returns the items matching a pattern, like vx --info. Being a
+// GET it changes nothing, so it does not count as an access for LRU
+// eviction the way --info does.
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	items, err := s.trash.List(vanish.Filter{Patterns: []string{r.PathValue("pattern")}})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(items) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no cached item matches %q", r.PathValue("pattern")))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+type deleteRequest struct {
+	Paths          []string `json:"paths"`
+	Permanent      bool     `json:"permanent"`
+	ForceProtected bool     `json:"force_protected"`
+	Confirm        string   `json:"confirm"`
+}
+
+// confirmPhrase is what vx asks to type before a deletion past the safety
+// limits, and what the confirm field of such a request must hold.
+const confirmPhrase = "delete"
+
+// handleDelete moves absolute paths to the cache. Refused paths are never
+// deleted, and neither are protected ones: unlike the command line, there
+// is nobody to ask. A deletion past the safety limits needs confirm set to
+// the phrase vx asks to type.
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	var req deleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %v", err))
+		return
+	}
+	if len(req.Paths) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("paths is required"))
+		return
+	}
+	if req.ForceProtected {
+		writeError(w, http.StatusForbidden, fmt.Errorf("protected paths cannot be deleted over the API, use vx --force-protected"))
+		return
+	}
+
+	var paths []string
+	var failures []ItemError
+	for _, path := range req.Paths {
+		if !filepath.IsAbs(path) {
+			failures = append(failures, ItemError{path, "path must be absolute"})
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	var deleted, permanent []types.DeletedItem
+	if len(paths) > 0 {
+		// A failure does not stop the others, each one is reported
+		opts := vanish.DeleteOptions{
+			Permanent: req.Permanent,
+			Confirmed: req.Confirm == confirmPhrase,
+			Progress: func(p vanish.Progress) {
+				if p.Err != nil {
+					failures = append(failures, ItemError{p.Path, p.Err.Error()})
+				}
+			},
+		}
+		items, err := s.trash.Delete(r.Context(), paths, opts)
+		switch {
+		case errors.Is(err, vanish.ErrNeedsConfirmation):
+			writeError(w, http.StatusForbidden, fmt.Errorf("%v; set \"confirm\": %q to go ahead", err, confirmPhrase))
+			return
+		case errors.Is(err, vanish.ErrNoSpace):
+			writeError(w, http.StatusInsufficientStorage, err)
+			return
+		}
+		for _, item := range items {
+			if item.CachePath == "" {
+				permanent = append(permanent, item)
+			} else {
+				deleted = append(deleted, item)
+			}
+		}
+	}
+
+	// Same housekeeping as the end of an interactive delete
+	keep := make([]string, len(deleted))
+	for i, item := range deleted {
+		keep[i] = item.ID
+	}
+	cleanup, _ := s.trash.Purge(vanish.PurgePolicy{Offload: true, Expired: true, EnforceQuota: true, Keep: keep})
+	expired, evicted := cleanup.Expired, cleanup.Evicted
+
+	s.publish("deleted", append(deleted, permanent...))
+	s.publish("purged", append(expired, evicted...))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"deleted":   nonNil(deleted),
+		"permanent": nonNil(permanent),
+		"offloaded": nonNil(cleanup.Offloaded),
+		"expired":   nonNil(expired),
+		"evicted":   nonNil(evicted),
+		"errors":    nonNil(failures),
+	})
+}
+
+type restoreRequest struct {
+	Patterns []string `json:"patterns"`
+}
+
+// handleRestore restores every cached item matching the patterns.
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	var req restoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %v", err))
+		return
+	}
+	if len(req.Patterns) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("patterns is required"))
+		return
+	}
+
+	items, err := s.trash.List(vanish.Filter{Patterns: req.Patterns})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// One call per item so that a failure does not stop the others
+	var restored []types.DeletedItem
+	var failures []ItemError
+	for _, item := range items {
+		if _, err := s.trash.Restore(r.Context(), []string{item.ID}, vanish.RestoreOptions{ByID: true}); err != nil {
+			failures = append(failures, ItemError{item.OriginalPath, err.Error()})
+			continue
+		}
+		restored = append(restored, item)
+	}
+
+	s.publish("restored", restored)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"restored": nonNil(restored),
+		"errors":   nonNil(failures),
+	})
+}
+
+type purgeRequest struct {
+	Expired bool `json:"expired"`
+	Days    *int `json:"days"`
+}
+
+// handlePurge removes expired items, or items older than a number of days.
+// Like vx purge --expired, items due for the remote tier are offloaded
+// first rather than purged.
+func (s *Server) handlePurge(w http.ResponseWriter, r *http.Request) {
+	var req purgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %v", err))
+		return
+	}
+	if req.Expired == (req.Days != nil) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("set exactly one of expired or days"))
+		return
+	}
+	if req.Days != nil && *req.Days < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("days cannot be negative"))
+		return
+	}
+
+	policy := vanish.PurgePolicy{Offload: req.Expired, Expired: req.Expired}
+	if req.Days != nil {
+		policy.DeletedBefore = time.Now().Add(-time.Duration(*req.Days) * 24 * time.Hour)
+	}
+	result, err := s.trash.Purge(policy)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	purged := append(result.Expired, result.Old...)
+
+	s.publish("purged", purged)
+	writeJSON(w, http.StatusOK, map[string]any{"purged": nonNil(purged), "offloaded": nonNil(result.Offloaded)})
+}
+
+// handleStats returns a summary of the cache.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	items, err := s.trash.List(vanish.Filter{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var stats Stats
+	for _, item := range items {
+		stats.Items++
+		stats.TotalSize += item.Size
+		if item.IsDirectory {
+			stats.Directories++
+		} else {
+			stats.Files++
+		}
+		if item.IsPinned() {
+			stats.Pinned++
+		}
+		if helpers.IsExpired(item, s.config) {
+			stats.Expired++
+		}
+		if stats.Oldest.IsZero() || item.DeleteDate.Before(stats.Oldest) {
+			stats.Oldest = item.DeleteDate
+		}
+		if item.DeleteDate.After(stats.Newest) {
+			stats.Newest = item.DeleteDate
+		}
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleEvents streams cache changes as server-sent events until the
+// client disconnects.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	events := make(chan Event, 16)
+	s.subMu.Lock()
+	s.subscribers[events] = struct{}{}
+	s.subMu.Unlock()
+	defer func() {
+		s.subMu.Lock()
+		delete(s.subscribers, events)
+		s.subMu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// publish sends an event to every subscriber. Slow subscribers miss events
+// rather than blocking operations.
+func (s *Server) publish(eventType string, items []types.DeletedItem) {
+	if len(items) == 0 {
+		return
+	}
+
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	s.indexMod = indexModTime(s.config)
+
+	event := Event{Type: eventType, Time: time.Now(), Items: items}
+	for subscriber := range s.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// watchIndex reports changes made by other vx processes, such as an
+// interactive delete or the purge timer, as "changed" events.
+func (s *Server) watchIndex(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modTime := indexModTime(s.config)
+
+			s.subMu.Lock()
+			changed := !modTime.Equal(s.indexMod)
+			s.indexMod = modTime
+			subscribers := make([]chan Event, 0, len(s.subscribers))
+			for subscriber := range s.subscribers {
+				subscribers = append(subscribers, subscriber)
+			}
+			s.subMu.Unlock()
+
+			if !changed {
+				continue
+			}
+			event := Event{Type: "changed", Time: time.Now()}
+			for _, subscriber := range subscribers {
+				select {
+				case subscriber <- event:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// indexModTime returns the modification time of the index file, or the
+// zero time when it does not exist yet.
+func indexModTime(config types.Config) time.Time {
+	info, err := os.Stat(helpers.GetIndexPath(config))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// nonNil turns a nil slice into an empty one so that it encodes as [].
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
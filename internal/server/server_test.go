@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+)
+
+// bucket records the objects uploaded to a fake S3 endpoint.
+type bucket struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (b *bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.Method != http.MethodPut {
+		http.Error(w, "unexpected "+r.Method, http.StatusBadRequest)
+		return
+	}
+	b.keys = append(b.keys, r.URL.Path)
+}
+
+// offloadConfig sends every item to the remote tier at endpoint.
+func offloadConfig(t *testing.T, endpoint string) types.Config {
+	var config types.Config
+	config.Cache.Directory = filepath.Join(t.TempDir(), "cache")
+	config.Cache.Days = 30
+	config.Remote.Enabled = true
+	config.Remote.Endpoint = endpoint
+	config.Remote.Region = "us-east-1"
+	config.Remote.Bucket = "bucket"
+	config.Remote.AccessKey = "key"
+	config.Remote.SecretKey = "secret"
+	config.Remote.MinSize = "1B"
+	config.Remote.Days = 30
+	return config
+}
+
+func serve(t *testing.T, s *Server, method, path, body string) map[string][]types.DeletedItem {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s %s: %d %s", method, path, rec.Code, rec.Body)
+	}
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	items := make(map[string][]types.DeletedItem)
+	for key, raw := range resp {
+		var list []types.DeletedItem
+		if json.Unmarshal(raw, &list) == nil {
+			items[key] = list
+		}
+	}
+	return items
+}
+
+func TestDeleteOffloads(t *testing.T) {
+	b := &bucket{}
+	ts := httptest.NewServer(b)
+	defer ts.Close()
+	config := offloadConfig(t, ts.URL)
+	file := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(file, []byte("due for the remote tier"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := serve(t, New(config), http.MethodPost, "/delete", `{"paths": [`+strconv.Quote(file)+`]}`)
+	if len(resp["offloaded"]) != 1 || len(resp["expired"]) != 0 {
+		t.Errorf("delete offloaded %d and expired %d items, want 1 and 0", len(resp["offloaded"]), len(resp["expired"]))
+	}
+	if len(b.keys) != 1 {
+		t.Errorf("uploaded %v, want one object", b.keys)
+	}
+}
+
+func TestPurgeExpiredOffloads(t *testing.T) {
+	b := &bucket{}
+	ts := httptest.NewServer(b)
+	defer ts.Close()
+	config := offloadConfig(t, ts.URL)
+	file := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(file, []byte("due for the remote tier"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := helpers.DeleteItem(file, false, nil, config); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := serve(t, New(config), http.MethodPost, "/purge", `{"expired": true}`)
+	if len(resp["offloaded"]) != 1 || len(resp["purged"]) != 0 {
+		t.Errorf("purge offloaded %d and purged %d items, want 1 and 0", len(resp["offloaded"]), len(resp["purged"]))
+	}
+	index, err := helpers.LoadIndex(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Items) != 1 || !index.Items[0].IsRemote() {
+		t.Errorf("index holds %+v, want the item in the remote tier", index.Items)
+	}
+}
+
+func TestListenCreatesPrivateSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "vanish.sock")
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error)
+	go func() { done <- New(types.Config{}).ListenAndServe(ctx, path) }()
+
+	var info os.FileInfo
+	for range 100 {
+		var err error
+		if info, err = os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("ListenAndServe: %v", err)
+	}
+	if info == nil || info.Mode().Perm() != 0600 {
+		t.Errorf("socket is %v, want mode 0600", info)
+	}
+	if dir, err := os.Stat(filepath.Dir(path)); err != nil || dir.Mode().Perm() != 0700 {
+		t.Errorf("socket directory is %v, %v, want mode 0700", dir, err)
+	}
+}
+
+func TestListenRefusesSharedDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	err := New(types.Config{}).ListenAndServe(t.Context(), filepath.Join(dir, "vanish.sock"))
+	if err == nil || !strings.Contains(err.Error(), "writable by other users") {
+		t.Errorf("ListenAndServe = %v, want the directory refused", err)
+	}
+}
+
+func TestInfoDoesNotTouch(t *testing.T) {
+	var config types.Config
+	config.Cache.Directory = filepath.Join(t.TempDir(), "cache")
+	config.Cache.Days = 30
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(file, []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := helpers.DeleteItem(file, false, nil, config); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := serve(t, New(config), http.MethodGet, "/items/notes.txt", "")
+	if len(resp["items"]) != 1 {
+		t.Fatalf("info returned %d items, want 1", len(resp["items"]))
+	}
+	index, err := helpers.LoadIndex(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !index.Items[0].LastAccessed.IsZero() {
+		t.Errorf("info recorded an access at %v", index.Items[0].LastAccessed)
+	}
+}
@@ -0,0 +1,89 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// typedConfirmPhrase must be typed to confirm a dangerous deletion.
+// It is required even when no_confirm or --noconfirm is set.
+const typedConfirmPhrase = "delete"
+
+// applySafetyPolicy marks protected items as refused unless the user
+// passed --force-protected.
+func (m *Model) applySafetyPolicy() {
+	for i, info := range m.FileInfos {
+		if info.Protected && !m.ForceProtected {
+			m.FileInfos[i].Exists = false
+			m.FileInfos[i].Refused = true
+			m.FileInfos[i].Reason = info.Reason + ", use --force-protected to delete it"
+		}
+	}
+}
+
+// deletesPermanently reports whether any item of the pending deletion
+// bypasses the cache and cannot be restored.
+func (m *Model) deletesPermanently() bool {
+	for _, info := range m.FileInfos {
+		if info.Exists && info.Permanent {
+			return true
+		}
+	}
+	return false
+}
+
+// handleTypedConfirmation processes key presses while the user types the
+// confirmation phrase.
+func (m *Model) handleTypedConfirmation(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC, tea.KeyEsc:
+		return m, tea.Quit
+	case tea.KeyEnter:
+		if strings.TrimSpace(m.TypedInput) != typedConfirmPhrase {
+			m.TypedError = fmt.Sprintf("Confirmation did not match, type '%s' exactly", typedConfirmPhrase)
+			m.TypedInput = ""
+			return m, nil
+		}
+		return m, m.startTransfer()
+	case tea.KeyBackspace:
+		if runes := []rune(m.TypedInput); len(runes) > 0 {
+			m.TypedInput = string(runes[:len(runes)-1])
+		}
+	case tea.KeySpace:
+		m.TypedInput += " "
+	case tea.KeyRunes:
+		m.TypedInput += string(msg.Runes)
+	}
+	return m, nil
+}
+
+func (m *Model) renderTypedConfirmation(content *strings.Builder, contentWidth int) {
+	m.renderDeleteConfirmation(content, contentWidth)
+	content.WriteString("\n\n")
+
+	warning := "⚠ This deletion needs an explicit confirmation:"
+	if !m.Config.UI.Progress.ShowEmoji {
+		warning = "WARNING: This deletion needs an explicit confirmation:"
+	}
+	content.WriteString(m.Styles.Warning.Render(warning))
+	content.WriteString("\n")
+	for _, reason := range m.TypedReasons {
+		content.WriteString(m.Styles.StatusBad.Render("  • " + reason))
+		content.WriteString("\n")
+	}
+
+	content.WriteString("\n")
+	content.WriteString(m.Styles.Question.Render(fmt.Sprintf("Type '%s' and press Enter to continue:", typedConfirmPhrase)))
+	content.WriteString("\n")
+	content.WriteString(m.Styles.Filename.UnsetUnderline().Render("> " + m.TypedInput + "█"))
+	content.WriteString("\n")
+
+	if m.TypedError != "" {
+		content.WriteString(m.Styles.StatusBad.Render(m.TypedError))
+		content.WriteString("\n")
+	}
+
+	content.WriteString(m.Styles.Help.Render("Press Esc to cancel"))
+}
@@ -0,0 +1,204 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+	"vanish/pkg/vanish"
+)
+
+// transferTick is how often the byte progress of a transfer is redrawn.
+const transferTick = 100 * time.Millisecond
+
+// transfer is a delete or restore running in the background. Items
+// finished are sent on events, followed by a TransferDoneMsg; the bytes
+// copied are added to bytes and read on every tick.
+type transfer struct {
+	cancel  context.CancelFunc
+	events  chan tea.Msg
+	bytes   atomic.Int64
+	handled map[string]bool // Paths deleted or IDs restored, or that failed
+}
+
+// startTransfer deletes or restores the confirmed items in the background
+// and returns the commands that follow its progress.
+func (m *Model) startTransfer() tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &transfer{cancel: cancel, handled: make(map[string]bool)}
+	m.transfer = t
+	m.Confirmed = true
+	m.StartedAt = time.Now()
+	m.BytesTotal, m.BytesDone = 0, 0
+	m.FilesTotal, m.FilesDone = 0, 0
+
+	trash, jobs, failFast := m.Trash, m.Jobs, m.FailFast
+	bytes := func(n int64) { t.bytes.Add(n) }
+	if m.Operation == "restore" {
+		m.State = "restoring"
+		ids := make([]string, len(m.RestoreItems))
+		for i, item := range m.RestoreItems {
+			ids[i] = item.ID
+			m.BytesTotal += item.Size
+			m.FilesTotal += itemFiles(item.IsDirectory, item.FileCount)
+		}
+		t.events = make(chan tea.Msg, len(ids)+1)
+		go func() {
+			_, err := trash.Restore(ctx, ids, vanish.RestoreOptions{
+				ByID:     true,
+				Jobs:     jobs,
+				FailFast: failFast,
+				Progress: func(p vanish.Progress) { t.events <- types.RestoreMsg{Item: p.Item, Err: p.Err} },
+				Bytes:    bytes,
+			})
+			t.events <- types.TransferDoneMsg{Err: err}
+		}()
+	} else {
+		m.State = "moving"
+		var paths []string
+		var inspected []vanish.PathInfo
+		for _, info := range m.FileInfos {
+			if info.Exists {
+				paths = append(paths, info.Path)
+				inspected = append(inspected, info)
+				m.BytesTotal += info.Size
+				m.FilesTotal += itemFiles(info.IsDirectory, info.FileCount)
+			}
+		}
+		opts := vanish.DeleteOptions{
+			Permanent:      m.Permanent,
+			ForceProtected: m.ForceProtected,
+			Confirmed:      len(m.TypedReasons) > 0,
+			Jobs:           jobs,
+			FailFast:       failFast,
+			Bytes:          bytes,
+			Inspected:      inspected, // Already walked before asking
+		}
+		t.events = make(chan tea.Msg, len(paths)+1)
+		opts.Progress = func(p vanish.Progress) {
+			t.events <- types.FileMoveMsg{Path: p.Path, Item: p.Item, Permanent: p.Err == nil && p.Item.CachePath == "", Err: p.Err}
+		}
+		go func() {
+			_, err := trash.Delete(ctx, paths, opts)
+			t.events <- types.TransferDoneMsg{Err: err}
+		}()
+	}
+
+	return tea.Batch(
+		m.Progress.SetPercent(0),
+		t.wait(),
+		tickTransfer(),
+	)
+}
+
+// wait returns the next event of the transfer.
+func (t *transfer) wait() tea.Cmd {
+	return func() tea.Msg {
+		return <-t.events
+	}
+}
+
+func tickTransfer() tea.Cmd {
+	return tea.Tick(transferTick, func(now time.Time) tea.Msg {
+		return types.TransferTickMsg(now)
+	})
+}
+
+// itemFiles returns the number of files an item accounts for.
+func itemFiles(isDirectory bool, fileCount int) int {
+	if isDirectory {
+		return fileCount
+	}
+	return 1
+}
+
+// transferFraction returns how much of the transfer is done, by bytes, or
+// by files when the items hold no data.
+func (m *Model) transferFraction() float64 {
+	if m.BytesTotal > 0 {
+		return min(1, float64(m.BytesDone)/float64(m.BytesTotal))
+	}
+	if m.FilesTotal > 0 {
+		return min(1, float64(m.FilesDone)/float64(m.FilesTotal))
+	}
+	return 0
+}
+
+// transferStats returns the bytes and files moved so far, the throughput
+// and the estimated time left.
+func (m *Model) transferStats() string {
+	stats := fmt.Sprintf("%s / %s • %d/%d files",
+		helpers.FormatBytes(m.BytesDone), helpers.FormatBytes(m.BytesTotal), m.FilesDone, m.FilesTotal)
+
+	elapsed := time.Since(m.StartedAt).Seconds()
+	if elapsed < 1 || m.BytesDone == 0 {
+		return stats
+	}
+	rate := float64(m.BytesDone) / elapsed
+	stats += fmt.Sprintf(" • %s/s", helpers.FormatBytes(int64(rate)))
+	if left := m.BytesTotal - m.BytesDone; left > 0 {
+		eta := time.Duration(float64(left) / rate * float64(time.Second))
+		stats += fmt.Sprintf(" • %s left", eta.Round(time.Second))
+	}
+	return stats
+}
+
+// collectSkipped records the items that were never attempted: paths that
+// did not pass the checks, and items left alone by a cancellation or
+// --fail-fast.
+func (m *Model) collectSkipped() {
+	reason := "not attempted, --fail-fast stopped at an earlier failure"
+	if m.Cancelled {
+		reason = "cancelled before it started"
+	}
+
+	if m.Operation == "restore" {
+		for _, item := range m.RestoreItems {
+			if !m.transfer.handled[item.ID] {
+				m.SkippedItems = append(m.SkippedItems, types.ItemOutcome{Path: item.OriginalPath, Reason: reason})
+			}
+		}
+		return
+	}
+	for _, info := range m.FileInfos {
+		switch {
+		case info.Refused:
+			m.SkippedItems = append(m.SkippedItems, types.ItemOutcome{Path: info.Path, Reason: "refused, " + info.Reason})
+		case !info.Exists:
+			m.SkippedItems = append(m.SkippedItems, types.ItemOutcome{Path: info.Path, Reason: "does not exist"})
+		case !m.transfer.handled[info.Path]:
+			m.SkippedItems = append(m.SkippedItems, types.ItemOutcome{Path: info.Path, Reason: reason})
+		}
+	}
+}
+
+// failureReason returns why an item failed, without the operation and
+// path that the done screen already shows.
+func failureReason(err error) string {
+	var pathErr *vanish.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// ExitCode returns the exit status of vx once the TUI has quit: 1 when
+// the operation failed as a whole or no item succeeded, 2 when only some
+// items failed or were skipped, and 0 otherwise.
+func (m *Model) ExitCode() int {
+	if m.State == "error" {
+		return 1
+	}
+	if m.State != "done" || (len(m.FailedItems) == 0 && len(m.SkippedItems) == 0) {
+		return 0
+	}
+	if len(m.ProcessedItems) == 0 {
+		return 1
+	}
+	return 2
+}
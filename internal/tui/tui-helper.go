@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"vanish/internal/helpers"
 	"vanish/internal/types"
 )
 
@@ -108,9 +109,43 @@ func (m *Model) appendValidFileInfo(listContent *strings.Builder, info types.Fil
 			listContent.WriteString(inlineInfoStyle.Render(" (empty)"))
 		}
 	}
+	if info.Protected {
+		listContent.WriteString(m.Styles.Warning.Render(fmt.Sprintf(" (protected: %s)", info.Reason)))
+	}
+	if info.Permanent {
+		listContent.WriteString(m.renderPermanentReason(info))
+	} else if info.Rule != nil {
+		listContent.WriteString(m.renderRuleEffect(info.Rule))
+	}
 	listContent.WriteString("\n")
 }
 
+// renderPermanentReason marks an item that bypasses the cache and says why,
+// so that unrecoverable items stand out before confirming.
+func (m *Model) renderPermanentReason(info types.FileInfo) string {
+	reason := "--permanent"
+	if !m.Permanent {
+		if info.Rule != nil && info.Rule.SkipCache {
+			reason = helpers.DescribeRule(info.Rule)
+		} else {
+			reason = "permanent pattern"
+		}
+	}
+	return m.Styles.StatusBad.Render(fmt.Sprintf(" (UNRECOVERABLE, not cached: %s)", reason))
+}
+
+// renderRuleEffect describes what the retention rule matching an item does
+// to it.
+func (m *Model) renderRuleEffect(rule *types.RetentionRule) string {
+	name := helpers.DescribeRule(rule)
+	effect := fmt.Sprintf("kept %d days", helpers.RetentionDays(rule, m.Config))
+	if rule.Compress {
+		effect += ", compressed"
+	}
+	inlineInfoStyle := m.Styles.Info.Border(lipgloss.Border{}).Padding(0)
+	return inlineInfoStyle.Render(fmt.Sprintf(" (%s: %s)", effect, name))
+}
+
 func (m *Model) appendInvalidFileInfo(listContent *strings.Builder, info types.FileInfo) {
 	// Use consistent spacing/width with valid files
 	icon := "ERR:"
@@ -121,7 +156,11 @@ func (m *Model) appendInvalidFileInfo(listContent *strings.Builder, info types.F
 	// Add proper spacing to align with valid file icons
 	listContent.WriteString(fmt.Sprintf("%-4s", icon))
 	listContent.WriteString(m.Styles.StatusBad.Render(info.Path))
-	listContent.WriteString(m.Styles.Warning.Render(" (does not exist)"))
+	if info.Refused {
+		listContent.WriteString(m.Styles.Warning.Render(fmt.Sprintf(" (refused: %s)", info.Reason)))
+	} else {
+		listContent.WriteString(m.Styles.Warning.Render(" (does not exist)"))
+	}
 	listContent.WriteString("\n")
 }
 
@@ -162,14 +201,47 @@ func (m *Model) renderInvalidFilesWarning(content *strings.Builder, invalidCount
 func (m *Model) renderDeleteSummary(content *strings.Builder, validCount, totalFileCount, contentWidth int) {
 	content.WriteString("\n")
 
+	// Retention rules can keep each item for its own number of days
+	var totalSize int64
+	permanentCount, cachedCount, minDays, maxDays := 0, 0, 0, 0
+	for _, info := range m.FileInfos {
+		if !info.Exists {
+			continue
+		}
+		totalSize += info.Size
+		if info.Permanent {
+			permanentCount++
+			continue
+		}
+		days := helpers.RetentionDays(info.Rule, m.Config)
+		if cachedCount == 0 || days < minDays {
+			minDays = days
+		}
+		maxDays = max(maxDays, days)
+		cachedCount++
+	}
+
 	infoText := fmt.Sprintf("Total items to delete: %d", validCount)
 	if totalFileCount > validCount {
 		infoText += fmt.Sprintf(" | Files affected: %d", totalFileCount)
 	}
-	infoText += fmt.Sprintf(" | Recoverable for %d days", m.Config.Cache.Days)
+	infoText += fmt.Sprintf(" | Size: %s", helpers.FormatBytes(totalSize))
+	switch {
+	case cachedCount == 0:
+	case minDays == maxDays:
+		infoText += fmt.Sprintf(" | Recoverable for %d days", minDays)
+	default:
+		infoText += fmt.Sprintf(" | Recoverable for %d to %d days", minDays, maxDays)
+	}
 
 	infoStyle := m.Styles.Info.MaxWidth(contentWidth).Align(lipgloss.Left)
 	content.WriteString(infoStyle.Render(infoText))
+
+	if permanentCount > 0 {
+		content.WriteString("\n")
+		warningStyle := m.Styles.StatusBad.Bold(true).MaxWidth(contentWidth)
+		content.WriteString(warningStyle.Render(fmt.Sprintf("⚠ %d item(s) will be deleted permanently and cannot be restored", permanentCount)))
+	}
 }
 
 func (m *Model) renderMovingState(content *strings.Builder, contentWidth int) {
@@ -183,45 +255,37 @@ func (m *Model) renderRestoringState(content *strings.Builder, contentWidth int)
 }
 
 func (m *Model) buildProgressStatusText(action, emoji string) string {
-	if m.CurrentIndex < len(m.FileInfos) {
-		currentFile := m.FileInfos[m.CurrentIndex]
-		fileType := m.getFileTypeString(currentFile.IsDirectory)
-
-		emojiPrefix := ""
-		if m.Config.UI.Progress.ShowEmoji {
-			emojiPrefix = emoji + " "
-		}
-
-		return fmt.Sprintf("%s%s %s '%s' to safe cache... (%d/%d)",
-			emojiPrefix, action, fileType, currentFile.Path, m.ProcessedFiles+1, m.TotalFiles)
+	if m.Cancelled {
+		return m.buildCancellingText()
 	}
 
-	fallback := fmt.Sprintf("%s files to safe cache...", action)
+	emojiPrefix := ""
 	if m.Config.UI.Progress.ShowEmoji {
-		fallback = emoji + " " + fallback
+		emojiPrefix = emoji + " "
 	}
-	return fallback
+	return fmt.Sprintf("%s%s items to safe cache... (%d/%d)",
+		emojiPrefix, action, m.ProcessedFiles, helpers.CountValidFiles(m.FileInfos))
 }
 
 func (m *Model) buildRestoreStatusText() string {
-	if m.CurrentIndex < len(m.RestoreItems) {
-		currentItem := m.RestoreItems[m.CurrentIndex]
-		fileType := m.getFileTypeString(currentItem.IsDirectory)
-
-		emojiPrefix := ""
-		if m.Config.UI.Progress.ShowEmoji {
-			emojiPrefix = "♻️ "
-		}
+	if m.Cancelled {
+		return m.buildCancellingText()
+	}
 
-		return fmt.Sprintf("%sRestoring %s '%s'... (%d/%d)",
-			emojiPrefix, fileType, currentItem.OriginalPath, m.ProcessedFiles+1, len(m.RestoreItems))
+	emojiPrefix := ""
+	if m.Config.UI.Progress.ShowEmoji {
+		emojiPrefix = "♻️ "
 	}
+	return fmt.Sprintf("%sRestoring items from cache... (%d/%d)",
+		emojiPrefix, m.ProcessedFiles, len(m.RestoreItems))
+}
 
-	fallback := "Restoring files from cache..."
+func (m *Model) buildCancellingText() string {
+	emojiPrefix := ""
 	if m.Config.UI.Progress.ShowEmoji {
-		fallback = "♻️ " + fallback
+		emojiPrefix = "⏹️ "
 	}
-	return fallback
+	return emojiPrefix + "Cancelling, waiting for the items in flight to finish or roll back..."
 }
 
 func (m *Model) renderProgressState(content *strings.Builder, statusText string, contentWidth int) {
@@ -232,6 +296,18 @@ func (m *Model) renderProgressState(content *strings.Builder, statusText string,
 	content.WriteString(statusStyle.Render(statusText))
 	content.WriteString("\n")
 	content.WriteString(m.Styles.Progress.Render(m.Progress.View()))
+	content.WriteString("\n")
+	content.WriteString(statusStyle.Render(m.transferStats()))
+
+	// Items finish out of order, show the latest one
+	if n := len(m.ProcessedItems); n > 0 {
+		content.WriteString("\n")
+		content.WriteString(statusStyle.Render("Last: " + m.ProcessedItems[n-1].OriginalPath))
+	}
+	if !m.Cancelled {
+		content.WriteString("\n")
+		content.WriteString(m.Styles.Help.Render("Press ctrl+c or 'q' to cancel"))
+	}
 }
 
 func (m *Model) renderCleanupState(content *strings.Builder) {
@@ -255,13 +331,21 @@ func (m *Model) renderSimpleProgressState(content *strings.Builder, emoji, messa
 }
 
 func (m *Model) renderDoneState(content *strings.Builder, contentWidth int) {
-	successMsg := m.buildSuccessMessage()
-	content.WriteString(m.Styles.Success.Render(successMsg))
+	switch {
+	case m.Cancelled:
+		content.WriteString(m.Styles.Warning.Render(m.buildCancelledMessage()))
+	case len(m.FailedItems) > 0 || len(m.SkippedItems) > 0:
+		content.WriteString(m.Styles.Warning.Render(m.buildPartialMessage()))
+	default:
+		content.WriteString(m.Styles.Success.Render(m.buildSuccessMessage()))
+	}
 	content.WriteString("\n")
 
 	if m.shouldShowItemDetails() {
 		m.renderItemDetails(content, contentWidth)
 	}
+	m.renderOutcomes(content, "Failed:", m.FailedItems, m.Styles.Error, contentWidth)
+	m.renderOutcomes(content, "Skipped:", m.SkippedItems, m.Styles.Warning, contentWidth)
 
 	content.WriteString(m.Styles.Progress.Render(m.Progress.View()))
 	content.WriteString("\n")
@@ -283,6 +367,9 @@ func (m *Model) buildSuccessMessage() string {
 		} else {
 			successMsg = "SUCCESS: All cached files cleared!"
 		}
+		if m.KeptPinned > 0 {
+			successMsg += fmt.Sprintf("\nKept %d pinned item(s), use --include-pinned to remove them", m.KeptPinned)
+		}
 	case "purge":
 		if m.Config.UI.Progress.ShowEmoji {
 			successMsg = fmt.Sprintf("✅ Purged %d old cached files!", m.ProcessedFiles)
@@ -298,6 +385,59 @@ func (m *Model) buildSuccessMessage() string {
 	return successMsg
 }
 
+func (m *Model) buildCancelledMessage() string {
+	prefix := "CANCELLED: "
+	if m.Config.UI.Progress.ShowEmoji {
+		prefix = "⏹️ "
+	}
+	if m.Operation == "restore" {
+		return fmt.Sprintf("%sRestored %d of %d item(s), the rest are still in the cache",
+			prefix, len(m.ProcessedItems), len(m.RestoreItems))
+	}
+	return fmt.Sprintf("%sMoved %d of %d item(s), the rest were left in place",
+		prefix, len(m.ProcessedItems), helpers.CountValidFiles(m.FileInfos))
+}
+
+func (m *Model) buildPartialMessage() string {
+	prefix := "WARNING: "
+	if m.Config.UI.Progress.ShowEmoji {
+		prefix = "⚠️ "
+	}
+	action := "Processed"
+	if m.Operation == "restore" {
+		action = "Restored"
+	}
+	total := len(m.ProcessedItems) + len(m.FailedItems) + len(m.SkippedItems)
+	return fmt.Sprintf("%s%s %d of %d item(s): %d failed, %d skipped",
+		prefix, action, len(m.ProcessedItems), total, len(m.FailedItems), len(m.SkippedItems))
+}
+
+// renderOutcomes lists items that failed or were skipped, with the reason.
+func (m *Model) renderOutcomes(content *strings.Builder, title string, outcomes []types.ItemOutcome, titleStyle lipgloss.Style, contentWidth int) {
+	if len(outcomes) == 0 {
+		return
+	}
+	infoStyle := m.Styles.Info.
+		Border(lipgloss.Border{}).
+		Padding(0).
+		MaxWidth(contentWidth)
+
+	if !strings.HasSuffix(content.String(), "\n") {
+		content.WriteString("\n")
+	}
+	content.WriteString(titleStyle.Border(lipgloss.Border{}).Padding(0).Render(title))
+	content.WriteString("\n")
+	maxItems := 5
+	for i, outcome := range outcomes {
+		if i >= maxItems {
+			content.WriteString(infoStyle.Render(fmt.Sprintf("... and %d more item(s)", len(outcomes)-maxItems)))
+			content.WriteString("\n")
+			break
+		}
+		content.WriteString(fmt.Sprintf("  • %s %s\n", m.Styles.Filename.Render(outcome.Path), infoStyle.Render("("+outcome.Reason+")")))
+	}
+}
+
 func (m *Model) shouldShowItemDetails() bool {
 	return (m.Operation == "delete" || m.Operation == "restore") && len(m.ProcessedItems) > 0
 }
@@ -308,6 +448,55 @@ func (m *Model) renderItemDetails(content *strings.Builder, contentWidth int) {
 
 	if m.Operation == "delete" && len(m.ProcessedItems) > 0 {
 		m.renderDeletionInfo(content, contentWidth)
+		m.renderCleanupSummary(content, contentWidth)
+	}
+}
+
+func (m *Model) renderCleanupSummary(content *strings.Builder, contentWidth int) {
+	infoStyle := m.Styles.Info.
+		Border(lipgloss.Border{}).
+		Padding(0).
+		MaxWidth(contentWidth)
+
+	if len(m.ExpiredItems) > 0 {
+		content.WriteString(infoStyle.Render(fmt.Sprintf("Cleaned up %d expired item(s)", len(m.ExpiredItems))))
+		content.WriteString("\n")
+	}
+
+	if len(m.OffloadedItems) > 0 {
+		var offloadedSize int64
+		for _, item := range m.OffloadedItems {
+			offloadedSize += item.Size
+		}
+		content.WriteString(infoStyle.Render(fmt.Sprintf("Moved %d item(s) (%s) to remote storage",
+			len(m.OffloadedItems), helpers.FormatBytes(offloadedSize))))
+		content.WriteString("\n")
+	}
+	if m.OffloadErr != nil {
+		content.WriteString(m.Styles.Warning.Render(fmt.Sprintf("Some items could not be offloaded and stay local: %v", m.OffloadErr)))
+		content.WriteString("\n")
+	}
+
+	if len(m.EvictedItems) == 0 {
+		return
+	}
+
+	var evictedSize int64
+	for _, item := range m.EvictedItems {
+		evictedSize += item.Size
+	}
+	content.WriteString(m.Styles.Warning.Render(fmt.Sprintf("Evicted %d item(s) (%s) to stay within the cache quota:",
+		len(m.EvictedItems), helpers.FormatBytes(evictedSize))))
+	content.WriteString("\n")
+
+	maxItems := 5
+	for i, item := range m.EvictedItems {
+		if i >= maxItems {
+			content.WriteString(infoStyle.Render(fmt.Sprintf("... and %d more item(s)", len(m.EvictedItems)-maxItems)))
+			content.WriteString("\n")
+			break
+		}
+		content.WriteString(fmt.Sprintf("  • %s %s\n", item.OriginalPath, infoStyle.Render("("+helpers.FormatBytes(item.Size)+")")))
 	}
 }
 
@@ -323,9 +512,20 @@ func (m *Model) buildItemDetailsText() string {
 		if m.Operation == "restore" {
 			detailsBuilder.WriteString(fmt.Sprintf("• %s ← %s\n",
 				m.Styles.Filename.Render(item.OriginalPath), "cache"))
-		} else {
+		} else if item.CachePath == "" {
 			detailsBuilder.WriteString(fmt.Sprintf("• %s → %s\n",
-				m.Styles.Filename.Render(item.OriginalPath), filepath.Base(item.CachePath)))
+				m.Styles.Filename.Render(item.OriginalPath), "deleted permanently"))
+		} else {
+			// Each item keeps until its own expiry, and crossing a
+			// filesystem shows how the content was copied
+			note := "recoverable until " + helpers.ExpiryDate(item, m.Config).Format("2006-01-02")
+			if item.CopyMethod != "" {
+				note = "copied via " + item.CopyMethod + ", " + note
+			}
+			inlineInfoStyle := m.Styles.Info.Border(lipgloss.Border{}).Padding(0)
+			detailsBuilder.WriteString(fmt.Sprintf("• %s → %s %s\n",
+				m.Styles.Filename.Render(item.OriginalPath), filepath.Base(item.CachePath),
+				inlineInfoStyle.Render("("+note+")")))
 		}
 	}
 
@@ -339,15 +539,37 @@ func (m *Model) buildItemDetailsText() string {
 }
 
 func (m *Model) renderDeletionInfo(content *strings.Builder, contentWidth int) {
-	deleteAfter := m.ProcessedItems[0].DeleteDate.Add(time.Duration(m.Config.Cache.Days) * 24 * time.Hour)
+	// Retention rules can give each item its own expiry, report the earliest
+	var deleteAfter time.Time
+	differ := false
+	for _, item := range m.ProcessedItems {
+		if item.CachePath == "" {
+			continue
+		}
+		expiry := helpers.ExpiryDate(item, m.Config)
+		differ = differ || !deleteAfter.IsZero() && !expiry.Equal(deleteAfter)
+		if deleteAfter.IsZero() || expiry.Before(deleteAfter) {
+			deleteAfter = expiry
+		}
+	}
 	infoStyle := m.Styles.Info.
 		Border(lipgloss.Border{}).
 		Padding(0).
 		MaxWidth(contentWidth)
 
 	content.WriteString("\n")
-	content.WriteString(infoStyle.Render(fmt.Sprintf("Will be permanently deleted after: %s", deleteAfter.Format("2006-01-02 15:04:05"))))
-	content.WriteString("\n")
+	if !deleteAfter.IsZero() {
+		text := "Will be permanently deleted after: %s"
+		if differ {
+			text = "Earliest permanent deletion: %s, later for the others"
+		}
+		content.WriteString(infoStyle.Render(fmt.Sprintf(text, deleteAfter.Format("2006-01-02 15:04:05"))))
+		content.WriteString("\n")
+	}
+	if len(m.PermanentItems) > 0 {
+		content.WriteString(m.Styles.Warning.Render(fmt.Sprintf("%d item(s) deleted permanently", len(m.PermanentItems))))
+		content.WriteString("\n")
+	}
 }
 
 func (m *Model) renderErrorState(content *strings.Builder) {
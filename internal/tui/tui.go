@@ -2,9 +2,10 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,13 +15,13 @@ import (
 	"vanish/internal/config"
 	"vanish/internal/helpers"
 	"vanish/internal/types"
+	"vanish/pkg/vanish"
 )
 
 // Model defines the state and data used by the TUI.
 type Model struct {
 	Filenames      []string
 	FileInfos      []types.FileInfo
-	CurrentIndex   int
 	State          string
 	Progress       progress.Model
 	ProgressVal    float64
@@ -35,6 +36,31 @@ type Model struct {
 	NoConfirm      bool
 	Operation      string // "delete", "restore", "clear", "purge"
 	RestoreItems   []types.DeletedItem
+	ForceProtected bool                // Allow deleting protected paths after a typed confirmation
+	TypedInput     string              // Text typed so far in the "typing" state
+	TypedError     string              // Shown when the typed confirmation did not match
+	TypedReasons   []string            // Why the typed confirmation is required
+	Permanent      bool                // Delete every item without caching (--permanent)
+	PermanentItems []types.DeletedItem // Deleted without caching
+	IncludePinned  bool                // Let --clear remove pinned items too
+	KeptPinned     int                 // Pinned items left behind by --clear
+	ExpiredItems   []types.DeletedItem // Removed by cleanup after a delete
+	EvictedItems   []types.DeletedItem // Evicted by cleanup to honour the quota
+	OffloadedItems []types.DeletedItem // Uploaded to the remote tier by cleanup
+	OffloadErr     error               // First upload failure during cleanup
+	Trash          *vanish.Trash       // Performs the cache operations
+	Jobs           int                 // Items moved at once, cache.jobs when zero (--jobs)
+	BytesTotal     int64               // Payload bytes of the items being moved
+	BytesDone      int64               // Payload bytes moved so far
+	FilesTotal     int                 // Files in the items being moved
+	FilesDone      int                 // Files in the items moved so far
+	StartedAt      time.Time           // When moving started, for throughput and ETA
+	Cancelled      bool                // Stopped with ctrl+c, the remaining items were left alone
+	FailFast       bool                // Stop at the first failure instead of moving the other items (--fail-fast)
+	FailedItems    []types.ItemOutcome // Items that could not be moved, with the reason
+	SkippedItems   []types.ItemOutcome // Items never attempted, with the reason
+
+	transfer *transfer // Delete or restore running in the background
 }
 
 // InitialModel initializes and returns a new Model with configuration, progress, styles, and file info prepared.
@@ -63,6 +89,7 @@ func InitialModel(filenames []string, operation string, noConfirm bool) (*Model,
 		ProcessedItems: make([]types.DeletedItem, 0),
 		TotalFiles:     len(filenames),
 		NoConfirm:      noConfirm,
+		Trash:          vanish.New(cfg),
 	}, nil
 }
 
@@ -74,23 +101,23 @@ func (m *Model) Init() tea.Cmd {
 		m.State = "clearing"
 		return tea.Batch(
 			m.Progress.SetPercent(0.1),
-			helpers.ClearAllCache(m.Config),
+			helpers.ClearAllCache(m.Config, m.IncludePinned),
 		)
 	case "purge":
 		m.State = "purging"
 		return tea.Batch(
 			m.Progress.SetPercent(0.1),
-			helpers.PurgeOldFiles(m.Config, m.Filenames[0]),
+			purgeOldFiles(m.Trash, m.Filenames[0]),
 		)
 	case "restore":
 		m.State = "checking"
 		return tea.Batch(
-			helpers.CheckRestoreItems(m.Filenames, m.Config),
+			checkRestoreItems(m.Trash, m.Filenames),
 			m.Progress.SetPercent(0.1),
 		)
 	default: // delete
 		return tea.Batch(
-			helpers.CheckFilesExist(m.Filenames),
+			helpers.CheckFilesExist(m.Filenames, m.Config),
 			m.Progress.SetPercent(0.1),
 		)
 	}
@@ -102,22 +129,23 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.State == "typing" {
+			return m.handleTypedConfirmation(msg)
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
+			// Let the items in flight finish or roll back before quitting
+			if m.transfer != nil {
+				if !m.Cancelled {
+					m.Cancelled = true
+					m.transfer.cancel()
+				}
+				return m, nil
+			}
 			return m, tea.Quit
 		case "y", "Y":
 			if m.State == "confirming" {
-				m.Confirmed = true
-				if m.Operation == "restore" {
-					m.State = "restoring"
-				} else {
-					m.State = "moving"
-				}
-				m.CurrentIndex = 0
-				return m, tea.Batch(
-					m.Progress.SetPercent(0.3),
-					processNextItem(m),
-				)
+				return m, m.startTransfer()
 			}
 		case "n", "N":
 			if m.State == "confirming" {
@@ -131,6 +159,12 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case types.FilesExistMsg:
 		m.FileInfos = msg.FileInfos
+		m.applySafetyPolicy()
+		if m.Permanent {
+			for i := range m.FileInfos {
+				m.FileInfos[i].Permanent = true
+			}
+		}
 		validFiles := 0
 		for _, info := range m.FileInfos {
 			if info.Exists {
@@ -141,17 +175,32 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if validFiles == 0 {
 			m.State = "error"
 			m.ErrorMsg = "No valid files or directories found"
+			for _, info := range m.FileInfos {
+				if info.Refused {
+					m.ErrorMsg += fmt.Sprintf("\n  %s: refused, %s", info.Path, info.Reason)
+				}
+			}
 			return m, nil
 		}
 
-		if m.NoConfirm {
-			m.Confirmed = true
-			m.State = "moving"
-			m.CurrentIndex = 0
-			return m, tea.Batch(
-				m.Progress.SetPercent(0.3),
-				processNextItem(m),
-			)
+		if err := helpers.CheckFreeSpace(m.FileInfos, m.Config); err != nil {
+			m.State = "error"
+			m.ErrorMsg = fmt.Sprintf("Refusing to delete: %v", err)
+			return m, nil
+		}
+
+		// Typed confirmation cannot be skipped with no_confirm
+		if reasons := helpers.ConfirmationReasons(m.FileInfos, m.Config); len(reasons) > 0 {
+			m.TypedReasons = reasons
+			m.State = "typing"
+			return m, m.Progress.SetPercent(0.2)
+		}
+
+		// Permanent deletions always ask, whether from --permanent, a
+		// permanent pattern or a skip_cache rule, so that no_confirm cannot
+		// make them silent
+		if m.NoConfirm && !m.deletesPermanently() {
+			return m, m.startTransfer()
 		}
 		m.State = "confirming"
 		return m, m.Progress.SetPercent(0.2)
@@ -165,82 +214,81 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		if m.NoConfirm {
-			m.Confirmed = true
-			m.State = "restoring"
-			m.CurrentIndex = 0
-			return m, tea.Batch(
-				m.Progress.SetPercent(0.3),
-				processNextItem(m),
-			)
+			return m, m.startTransfer()
 		}
 		m.State = "confirming"
 		return m, m.Progress.SetPercent(0.2)
 
 	case types.FileMoveMsg:
+		m.transfer.handled[msg.Path] = true
+		m.ProcessedFiles++
 		if msg.Err != nil {
-			m.State = "error"
-			m.ErrorMsg = fmt.Sprintf("Error processing item: %v", msg.Err)
-			return m, nil
+			m.FailedItems = append(m.FailedItems, types.ItemOutcome{Path: msg.Path, Reason: failureReason(msg.Err)})
+			return m, m.transfer.wait()
 		}
-
-		if msg.Item.ID != "" {
-			m.ProcessedItems = append(m.ProcessedItems, msg.Item)
-			m.ProcessedFiles++
+		m.ProcessedItems = append(m.ProcessedItems, msg.Item)
+		m.FilesDone += itemFiles(msg.Item.IsDirectory, msg.Item.FileCount)
+		if msg.Permanent {
+			m.PermanentItems = append(m.PermanentItems, msg.Item)
 		}
+		return m, m.transfer.wait()
 
-		// Find the next valid file index, starting from current + 1
-		nextIndex := helpers.FindNextValidFile(m.FileInfos, m.CurrentIndex+1)
-
-		// Update progress based on processed files vs total valid files
-		validFileCount := helpers.CountValidFiles(m.FileInfos)
-		progressPercent := 0.3 + (float64(m.ProcessedFiles)/float64(validFileCount))*0.4
-
-		// Check if we have more valid items to process
-		if nextIndex != -1 {
-			m.CurrentIndex = nextIndex
-			return m, tea.Batch(
-				m.Progress.SetPercent(progressPercent),
-				processNextItem(m),
-			)
+	case types.RestoreMsg:
+		m.transfer.handled[msg.Item.ID] = true
+		m.ProcessedFiles++
+		if msg.Err != nil {
+			m.FailedItems = append(m.FailedItems, types.ItemOutcome{Path: msg.Item.OriginalPath, Reason: failureReason(msg.Err)})
+			return m, m.transfer.wait()
 		}
+		m.ProcessedItems = append(m.ProcessedItems, msg.Item)
+		m.FilesDone += itemFiles(msg.Item.IsDirectory, msg.Item.FileCount)
+		return m, m.transfer.wait()
 
-		// All items processed, move to cleanup
-		m.State = "cleanup"
+	case types.TransferTickMsg:
+		if m.transfer == nil {
+			return m, nil
+		}
+		m.BytesDone = m.transfer.bytes.Load()
 		return m, tea.Batch(
-			m.Progress.SetPercent(0.7),
-			cleanupOldFiles(m.Config),
+			m.Progress.SetPercent(m.transferFraction()),
+			tickTransfer(),
 		)
 
-	case types.RestoreMsg:
-		if msg.Err != nil {
+	case types.TransferDoneMsg:
+		m.BytesDone = m.transfer.bytes.Load()
+		// Items that all finished before the cancellation took effect
+		m.Cancelled = m.Cancelled && errors.Is(msg.Err, context.Canceled)
+		m.collectSkipped()
+		m.transfer = nil
+
+		// A failure no item reported, such as an unreadable index
+		if msg.Err != nil && len(m.FailedItems) == 0 && !m.Cancelled {
 			m.State = "error"
-			m.ErrorMsg = fmt.Sprintf("Error restoring item: %v", msg.Err)
+			if m.Operation == "restore" {
+				m.ErrorMsg = fmt.Sprintf("Error restoring items: %v", msg.Err)
+			} else {
+				m.ErrorMsg = fmt.Sprintf("Error processing items: %v", msg.Err)
+			}
 			return m, nil
 		}
 
-		if msg.Item.ID != "" {
-			m.ProcessedItems = append(m.ProcessedItems, msg.Item)
-			m.ProcessedFiles++
+		if m.Cancelled || m.Operation == "restore" || len(m.ProcessedItems) == 0 {
+			m.State = "done"
+			return m, m.Progress.SetPercent(m.transferFraction())
 		}
 
-		m.CurrentIndex++
-
-		// Update progress
-		progressPercent := 0.3 + (float64(m.CurrentIndex)/float64(len(m.RestoreItems)))*0.4
-
-		// Check if we have more items to restore
-		if m.CurrentIndex < len(m.RestoreItems) {
-			return m, tea.Batch(
-				m.Progress.SetPercent(progressPercent),
-				processNextItem(m),
-			)
-		}
-
-		// All items restored
-		m.State = "done"
-		return m, m.Progress.SetPercent(1.0)
+		// All items processed, move to cleanup
+		m.State = "cleanup"
+		return m, tea.Batch(
+			m.Progress.SetPercent(1.0),
+			cleanupOldFiles(m.Trash, m.ProcessedItems),
+		)
 
 	case types.CleanupMsg:
+		m.ExpiredItems = msg.Expired
+		m.EvictedItems = msg.Evicted
+		m.OffloadedItems = msg.Offloaded
+		m.OffloadErr = msg.OffloadErr
 		m.State = "done"
 		return m, m.Progress.SetPercent(1.0)
 
@@ -250,6 +298,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.ErrorMsg = fmt.Sprintf("Error clearing cache: %v", msg.Err)
 			return m, nil
 		}
+		m.KeptPinned = msg.KeptPinned
 		m.State = "done"
 		return m, m.Progress.SetPercent(1.0)
 
@@ -298,6 +347,8 @@ func (m *Model) View() string {
 		m.renderCheckingState(&content)
 	case "confirming":
 		m.renderConfirmingState(&content, contentWidth)
+	case "typing":
+		m.renderTypedConfirmation(&content, contentWidth)
 	case "moving":
 		m.renderMovingState(&content, contentWidth)
 	case "restoring":
@@ -317,219 +368,61 @@ func (m *Model) View() string {
 	return m.Styles.Root.Render(content.String())
 }
 
-func processNextItem(m *Model) tea.Cmd {
-	if m.Operation == "restore" {
-		if m.CurrentIndex >= len(m.RestoreItems) {
-			return nil
-		}
-		return restoreFromCache(m.RestoreItems[m.CurrentIndex], m.Config)
-	}
-	// Make sure we have a valid index
-	if m.CurrentIndex < 0 || m.CurrentIndex >= len(m.FileInfos) {
-		return nil
-	}
-	// Make sure the file at current index exists
-	if !m.FileInfos[m.CurrentIndex].Exists {
-		return nil
-	}
-	return moveFileToCache(m.FileInfos[m.CurrentIndex].Path, m.Config)
-}
-
-// restoreFromCache restores a deleted item from cache back to its original location
-func restoreFromCache(item types.DeletedItem, config types.Config) tea.Cmd {
+// checkRestoreItems looks up the cached items matching the patterns.
+func checkRestoreItems(trash *vanish.Trash, patterns []string) tea.Cmd {
 	return func() tea.Msg {
-		// Check if cache file exists
-		if _, err := os.Lstat(item.CachePath); os.IsNotExist(err) {
-			return types.RestoreMsg{Err: fmt.Errorf("cached file not found: %s", item.CachePath)}
-		}
-
-		// Create directory for original path if needed
-		originalDir := filepath.Dir(item.OriginalPath)
-		if err := os.MkdirAll(originalDir, 0755); err != nil {
-			return types.RestoreMsg{Err: fmt.Errorf("failed to create directory %s: %v", originalDir, err)}
-		}
-
-		// Check if original path already exists
-		if _, err := os.Lstat(item.OriginalPath); !os.IsNotExist(err) {
-			return types.RestoreMsg{Err: fmt.Errorf("destination already exists: %s", item.OriginalPath)}
-		}
-
-		// Restore based on item type
-		var err error
-		if item.IsSymlink {
-			// Restore symlink
-			err = helpers.RestoreSymlink(item.CachePath, item.OriginalPath)
-		} else if item.IsDirectory {
-			// Restore directory
-			err = helpers.MoveDirectory(item.CachePath, item.OriginalPath)
-		} else {
-			// Restore regular file
-			err = helpers.MoveFile(item.CachePath, item.OriginalPath)
-		}
-
+		items, err := trash.List(vanish.Filter{Patterns: patterns})
 		if err != nil {
-			return types.RestoreMsg{Err: fmt.Errorf("failed to restore %s: %v", item.ItemType(), err)}
-		}
-
-		// Remove from index
-		if err := helpers.RemoveFromIndex(item.ID, config); err != nil {
-			// Log error but don't fail the restore
-			if config.Logging.Enabled {
-				logDir := helpers.ExpandPath(config.Logging.Directory)
-				if err := os.MkdirAll(logDir, 0755); err == nil {
-					logPath := filepath.Join(logDir, "vanish.log")
-					if logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
-	defer logFile.Close()
-
-	if _, err := logFile.WriteString(fmt.Sprintf("%s ERROR Failed to remove from index: %s\n",
-		time.Now().Format("2006-01-02 15:04:05"), item.ID)); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to write to log file: %v\n", err)
-	}
-}
-
-				}
-			}
-		}
-
-		// Log the restore operation
-		if config.Logging.Enabled {
-			helpers.LogOperation("RESTORE", item, config)
+			return types.ErrorMsg(fmt.Sprintf("Error loading index: %v", err))
 		}
-
-		return types.RestoreMsg{Item: item, Err: nil}
+		return types.RestoreItemsMsg{Items: items}
 	}
 }
 
-// moveFileToCache moves a file, directory, or symlink to the cache
-func moveFileToCache(filename string, config types.Config) tea.Cmd {
+// purgeOldFiles removes unpinned items deleted more than daysStr days ago.
+func purgeOldFiles(trash *vanish.Trash, daysStr string) tea.Cmd {
 	return func() tea.Msg {
-		// Ensure cache directory exists
-		cacheDir := helpers.ExpandPath(config.Cache.Directory)
-		if err := os.MkdirAll(cacheDir, 0755); err != nil {
-			return types.FileMoveMsg{Err: err}
-		}
-
-		// Get file info using Lstat (doesn't follow symlinks)
-		stat, err := os.Lstat(filename)
+		days, err := strconv.Atoi(daysStr)
 		if err != nil {
-			return types.FileMoveMsg{Err: err}
+			return types.PurgeMsg{Err: fmt.Errorf("invalid days value: %s", daysStr)}
 		}
 
-		// Get absolute path
-		absPath, err := filepath.Abs(filename)
+		result, err := trash.Purge(vanish.PurgePolicy{
+			DeletedBefore: time.Now().Add(-time.Duration(days) * 24 * time.Hour),
+		})
 		if err != nil {
-			return types.FileMoveMsg{Err: err}
-		}
-
-		// Generate unique ID and cache filename
-		now := time.Now()
-		id := fmt.Sprintf("%d", now.UnixNano())
-		timestamp := now.Format("2006-01-02-15-04-05")
-		baseFilename := filepath.Base(filename)
-		cacheFilename := fmt.Sprintf("%s-%s-%s", id, timestamp, baseFilename)
-		cachePath := filepath.Join(cacheDir, cacheFilename)
-
-		// Determine file type
-		isSymlink := stat.Mode()&os.ModeSymlink != 0
-		isDir := stat.IsDir()
-		fileCount := 0
-		size := stat.Size()
-		linkTarget := ""
-
-		// Handle different file types
-		if isSymlink {
-			// Handle symbolic link
-			linkTarget, err = os.Readlink(filename)
-			if err != nil {
-				return types.FileMoveMsg{Err: fmt.Errorf("failed to read symlink: %v", err)}
-			}
-
-			if err := helpers.MoveSymlink(filename, cachePath); err != nil {
-				return types.FileMoveMsg{Err: fmt.Errorf("failed to move symlink: %v", err)}
-			}
-
-			// For symlinks, size is typically small (just the link itself)
-			size = stat.Size()
-
-		} else if isDir {
-			// Handle directory
-			fileCount, _ = helpers.CountFilesInDirectory(filename)
-			size, _ = helpers.GetDirectorySize(filename)
-
-			if err := helpers.MoveDirectory(filename, cachePath); err != nil {
-				return types.FileMoveMsg{Err: fmt.Errorf("failed to move directory: %v", err)}
-			}
-
-		} else {
-			// Handle regular file
-			if err := helpers.MoveFile(filename, cachePath); err != nil {
-				return types.FileMoveMsg{Err: fmt.Errorf("failed to move file: %v", err)}
-			}
-		}
-
-		// Create deleted item with all metadata
-		item := types.DeletedItem{
-			ID:           id,
-			OriginalPath: absPath,
-			DeleteDate:   now,
-			CachePath:    cachePath,
-			IsDirectory:  isDir,
-			IsSymlink:    isSymlink,
-			LinkTarget:   linkTarget,
-			FileCount:    fileCount,
-			Size:         size,
-		}
-
-		// Update index
-		if err := helpers.AddToIndex(item, config); err != nil {
-			return types.FileMoveMsg{Err: fmt.Errorf("failed to update index: %v", err)}
-		}
-
-		// Log the operation
-		if config.Logging.Enabled {
-			helpers.LogOperation("DELETE", item, config)
+			return types.PurgeMsg{Err: err}
 		}
-
-		return types.FileMoveMsg{Item: item, Err: nil}
+		return types.PurgeMsg{PurgedCount: len(result.Old), Err: nil}
 	}
 }
 
-func cleanupOldFiles(config types.Config) tea.Cmd {
+// cleanupOldFiles offloads items due for the remote tier, removes items
+// past their expiry date (their retention rule, or cache.days), then
+// evicts items until the cache fits within its quota. Items in keep (the
+// ones just deleted) are never evicted.
+func cleanupOldFiles(trash *vanish.Trash, keep []types.DeletedItem) tea.Cmd {
 	return func() tea.Msg {
-		cutoffDays := time.Duration(config.Cache.Days) * 24 * time.Hour
-		cutoff := time.Now().Add(-cutoffDays)
-
-		index, err := helpers.LoadIndex(config)
+		keepIDs := make([]string, len(keep))
+		for i, item := range keep {
+			keepIDs[i] = item.ID
+		}
+		result, err := trash.Purge(vanish.PurgePolicy{
+			Offload:      true,
+			Expired:      true,
+			EnforceQuota: true,
+			Keep:         keepIDs,
+		})
 		if err != nil {
-			return types.ErrorMsg(fmt.Sprintf("Error loading index: %v", err))
-		}
-
-		var remainingItems []types.DeletedItem
-		for _, item := range index.Items {
-			if item.DeleteDate.Before(cutoff) {
-				// Remove the actual file or directory
-				if item.IsDirectory {
-					os.RemoveAll(item.CachePath)
-				} else {
-					os.Remove(item.CachePath)
-				}
-
-				// Log cleanup
-				if config.Logging.Enabled {
-					helpers.LogOperation("CLEANUP", item, config)
-				}
-			} else {
-				remainingItems = append(remainingItems, item)
-			}
+			return types.ErrorMsg(fmt.Sprintf("Error cleaning up the cache: %v", err))
 		}
 
-		// Update index
-		index.Items = remainingItems
-		if err := helpers.SaveIndex(index, config); err != nil {
-			return types.ErrorMsg(fmt.Sprintf("Error updating index: %v", err))
+		return types.CleanupMsg{
+			Expired:    result.Expired,
+			Evicted:    result.Evicted,
+			Offloaded:  result.Offloaded,
+			OffloadErr: result.OffloadErr,
 		}
-
-		return types.CleanupMsg{}
 	}
 }
 
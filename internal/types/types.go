@@ -11,10 +11,49 @@ import (
 // Config holds the user configuration loaded from the config file.
 type Config struct {
 	Cache struct {
-		Directory string `toml:"directory"`
-		Days      int    `toml:"days"`
-		NoConfirm bool   `toml:"no_confirm"`
+		Directory     string   `toml:"directory"`
+		Days          int      `toml:"days"`
+		NoConfirm     bool     `toml:"no_confirm"`
+		MaxSize       string   `toml:"max_size"`        // Cache size quota, e.g. "20GB", "" disables
+		MaxItems      int      `toml:"max_items"`       // Item count quota, 0 disables
+		Eviction      string   `toml:"eviction"`        // "oldest", "largest", "lru"
+		Permanent     []string `toml:"permanent"`       // Glob patterns of items deleted without caching
+		Shred         bool     `toml:"shred"`           // Overwrite every payload before removing it
+		ShredPasses   int      `toml:"shred_passes"`    // Overwrite passes used when shredding
+		Storage       string   `toml:"storage"`         // "directory" or "bundle"
+		BundleMaxSize string   `toml:"bundle_max_size"` // Largest payload packed into a bundle
+		Jobs          int      `toml:"jobs"`            // Items deleted or restored at once
 	} `toml:"cache"`
+	Safety struct {
+		Protected     []string `toml:"protected"`        // Glob patterns that need --force-protected
+		MaxItems      int      `toml:"max_items"`        // Items per deletion before a typed confirmation, 0 disables
+		MaxBytes      string   `toml:"max_bytes"`        // Total size before a typed confirmation, e.g. "10GB", "" disables
+		MaxFilesInDir int      `toml:"max_files_in_dir"` // Files inside one directory before a typed confirmation, 0 disables
+	} `toml:"safety"`
+	Retention struct {
+		Rules []RetentionRule `toml:"rules"` // Evaluated in order, first match wins
+	} `toml:"retention"`
+	Remote struct {
+		Enabled       bool   `toml:"enabled"`
+		Endpoint      string `toml:"endpoint"`       // e.g. "https://s3.eu-west-1.amazonaws.com" or "http://localhost:9000"
+		Region        string `toml:"region"`         // Signing region, "us-east-1" for MinIO
+		Bucket        string `toml:"bucket"`         // Bucket receiving the payloads
+		Prefix        string `toml:"prefix"`         // Key prefix of uploaded payloads
+		AccessKey     string `toml:"access_key"`     // Credentials used to sign requests
+		SecretKey     string `toml:"secret_key"`     // Credentials used to sign requests
+		SessionToken  string `toml:"session_token"`  // Optional, for temporary credentials
+		VirtualHosted bool   `toml:"virtual_hosted"` // Use <bucket>.<host> URLs instead of <host>/<bucket>
+		AfterDays     int    `toml:"after_days"`     // Upload items deleted this many days ago, 0 disables
+		MinSize       string `toml:"min_size"`       // Upload items at least this large, "" disables
+		Days          int    `toml:"days"`           // Days an uploaded item is kept before cleanup deletes it
+	} `toml:"remote"`
+	Preview struct {
+		Highlight bool   `toml:"highlight"` // Syntax highlighting of text files
+		Style     string `toml:"style"`     // Chroma style used for highlighting, e.g. "monokai"
+	} `toml:"preview"`
+	Metrics struct {
+		Textfile string `toml:"textfile"` // Prometheus .prom file rewritten after every operation, "" disables
+	} `toml:"metrics"`
 	Logging struct {
 		Enabled   bool   `toml:"enabled"`
 		Directory string `toml:"directory"`
@@ -45,6 +84,21 @@ type Config struct {
 	} `toml:"ui"`
 }
 
+// RetentionRule overrides how long, and how, matching items are kept.
+// Every condition that is set must match for the rule to apply.
+type RetentionRule struct {
+	Name      string `toml:"name"`       // Optional label shown in the TUI
+	Match     string `toml:"match"`      // Glob on the name, or on the full path if it contains "/"
+	Path      string `toml:"path"`       // Path prefix, e.g. "~/Documents"
+	Type      string `toml:"type"`       // "file", "directory" or "symlink"
+	MinSize   string `toml:"min_size"`   // Only items at least this large, e.g. "1GB"
+	MaxSize   string `toml:"max_size"`   // Only items at most this large
+	Days      int    `toml:"days"`       // Retention in days, 0 keeps cache.days
+	Compress  bool   `toml:"compress"`   // Store the payload as a .tar.gz archive
+	SkipCache bool   `toml:"skip_cache"` // Delete permanently instead of caching
+	Sensitive bool   `toml:"sensitive"`  // Shred the payload when it leaves the cache
+}
+
 // DeletedItem represents an item that has been moved to cache
 type DeletedItem struct {
 	ID           string    `json:"id"`
@@ -56,6 +110,16 @@ type DeletedItem struct {
 	LinkTarget   string    `json:"link_target,omitempty"` // Only populated for symlinks
 	FileCount    int       `json:"file_count,omitempty"`
 	Size         int64     `json:"size"`
+	LastAccessed time.Time `json:"last_accessed,omitzero"`  // Last --info view, used by LRU eviction
+	Pinned       bool      `json:"pinned,omitempty"`        // Skipped by cleanup, purge and eviction
+	PinnedUntil  time.Time `json:"pinned_until,omitzero"`   // Zero means pinned until unpinned
+	ExpiresAt    time.Time `json:"expires_at,omitzero"`     // Set by a retention rule, zero uses cache.days
+	Compressed   bool      `json:"compressed,omitempty"`    // Payload is a .tar.gz archive
+	Sensitive    bool      `json:"sensitive,omitempty"`     // Shred the payload when it leaves the cache
+	Storage      string    `json:"storage,omitempty"`       // Backend holding the payload, empty means "directory", "remote" means offloaded
+	MetadataLost []string  `json:"metadata_lost,omitempty"` // Metadata kinds the cache copy could not keep, e.g. "ownership", "xattrs"
+	Skipped      []string  `json:"skipped,omitempty"`       // Special files left out of the cache copy, e.g. sockets
+	CopyMethod   string    `json:"copy_method,omitempty"`   // Slowest way content was copied into the cache, empty when renamed
 }
 
 // Index represents the global index file
@@ -63,13 +127,42 @@ type Index struct {
 	Items []DeletedItem `json:"items"`
 }
 
+// History aggregates what happened to the cache, day by day
+type History struct {
+	Since time.Time              `json:"since"` // First recorded operation
+	Total HistoryDay             `json:"total"` // Every day since, without directories and extensions
+	Days  map[string]*HistoryDay `json:"days"`  // Keyed by local date, "2006-01-02"
+}
+
+// HistoryDay counts the items deleted, restored and purged on one day
+type HistoryDay struct {
+	Deleted      HistoryCount            `json:"deleted"`
+	Restored     HistoryCount            `json:"restored"`
+	Purged       HistoryCount            `json:"purged"`         // Purged, expired, evicted, shredded or cleared
+	RestoreDelay int64                   `json:"restore_delay"`  // Seconds restored items spent in the cache, summed
+	Dirs         map[string]HistoryCount `json:"dirs,omitempty"` // Deletions by original directory
+	Exts         map[string]HistoryCount `json:"exts,omitempty"` // Deletions by extension
+}
+
+// HistoryCount is a number of items and their total size
+type HistoryCount struct {
+	Items int   `json:"items"`
+	Bytes int64 `json:"bytes"`
+}
+
 // FileInfo holds information about a file to be deleted
 type FileInfo struct {
 	Path        string
 	IsDirectory bool
 	FileCount   int
+	Size        int64
 	Exists      bool
 	Error       string
+	Rule        *RetentionRule // Retention rule that applies, nil for the default
+	Permanent   bool           // Deleted without caching, cannot be restored
+	Protected   bool           // Needs --force-protected and a typed confirmation
+	Refused     bool           // Can never be deleted, e.g. the cache directory itself
+	Reason      string         // Why the path is protected or refused
 }
 
 // ThemeStyles holds all the styled components used in the TUI
@@ -107,8 +200,10 @@ type RestoreItemsMsg struct {
 
 // FileMoveMsg represents the result of a file move operation.
 type FileMoveMsg struct {
-	Item DeletedItem
-	Err  error
+	Path      string // Path deleted, set when the move failed too
+	Item      DeletedItem
+	Permanent bool // Deleted without caching, Item is not in the index
+	Err       error
 }
 
 // RestoreMsg represents the result of restoring a deleted item.
@@ -117,12 +212,35 @@ type RestoreMsg struct {
 	Err  error
 }
 
+// ItemOutcome is an item of a delete or restore that did not succeed,
+// with the reason shown on the done screen.
+type ItemOutcome struct {
+	Path   string
+	Reason string
+}
+
+// TransferDoneMsg is sent once a delete or restore running in the
+// background has processed its items, failed or been cancelled.
+type TransferDoneMsg struct {
+	Err error
+}
+
+// TransferTickMsg asks the TUI to refresh the byte progress of a delete
+// or restore running in the background.
+type TransferTickMsg time.Time
+
 // CleanupMsg indicates that a cleanup action has occurred.
-type CleanupMsg struct{}
+type CleanupMsg struct {
+	Expired    []DeletedItem // Removed because their retention period ended
+	Evicted    []DeletedItem // Removed to keep the cache within its quota
+	Offloaded  []DeletedItem // Uploaded to the remote tier
+	OffloadErr error         // First upload failure, those items stay local
+}
 
 // ClearMsg represents the result of clearing cached files.
 type ClearMsg struct {
-	Err error
+	KeptPinned int // Pinned items left in the cache
+	Err        error
 }
 
 // PurgeMsg contains information about files purged from the cache.
@@ -134,6 +252,18 @@ type PurgeMsg struct {
 // ErrorMsg is a generic error message used across the application.
 type ErrorMsg string
 
+// IsPinned reports whether the item is currently pinned. A pin with a
+// PinnedUntil date lapses once that date has passed.
+func (item DeletedItem) IsPinned() bool {
+	return item.Pinned && (item.PinnedUntil.IsZero() || time.Now().Before(item.PinnedUntil))
+}
+
+// IsRemote reports whether the item's payload was offloaded to the remote
+// tier and is no longer in the local cache.
+func (item DeletedItem) IsRemote() bool {
+	return item.Storage == "remote"
+}
+
 // ItemType returns a human-readable string describing the item type
 func (item DeletedItem) ItemType() string {
 	if item.IsSymlink {
@@ -8,6 +8,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"vanish/cmd/commands"
 	"vanish/internal/config"
+	"vanish/internal/helpers"
 	"vanish/internal/tui"
 )
 
@@ -38,10 +39,18 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error initializing: %v", err)
 	}
+	m.ForceProtected = parsed.ForceProtected
+	m.IncludePinned = parsed.IncludePinned
+	m.Permanent = parsed.Permanent
+	m.Jobs = parsed.Jobs
+	m.FailFast = parsed.FailFast
 
 	p := tea.NewProgram(m)
 
 	if _, err := p.Run(); err != nil {
 		log.Fatalf("Error running program: %v", err)
 	}
+	helpers.FlushMetrics(cfg)
+	// 2 tells scripts that some items failed or were skipped
+	os.Exit(m.ExitCode())
 }
@@ -0,0 +1,49 @@
+package vanish
+
+import (
+	"errors"
+
+	"vanish/internal/helpers"
+)
+
+// Sentinel errors returned, wrapped in a *PathError, by Trash operations.
+// Test for them with errors.Is.
+var (
+	// ErrNotFound means a path to delete does not exist, or no cached item
+	// matches a selector.
+	ErrNotFound = errors.New("not found")
+	// ErrRefused means a path can never be deleted, such as the cache
+	// directory itself or a directory containing it.
+	ErrRefused = errors.New("refused")
+	// ErrProtected means a path needs DeleteOptions.ForceProtected.
+	ErrProtected = errors.New("protected")
+	// ErrNeedsConfirmation means a deletion includes a protected path or
+	// goes past the safety limits, and needs DeleteOptions.Confirmed.
+	ErrNeedsConfirmation = errors.New("needs an explicit confirmation")
+	// ErrNoSpace means the items to copy into the cache do not fit in the
+	// free space of its filesystem.
+	ErrNoSpace = helpers.ErrNoSpace
+	// ErrExists means something already exists where an item would be
+	// restored.
+	ErrExists = errors.New("destination already exists")
+	// ErrMissingPayload means an indexed item has no payload in the cache.
+	ErrMissingPayload = errors.New("cached payload missing")
+	// ErrRemoteDisabled means an offload was requested while the remote
+	// tier is not enabled in the config.
+	ErrRemoteDisabled = errors.New("remote tier disabled")
+)
+
+// PathError records the operation and path that failed.
+type PathError struct {
+	Op   string // "delete", "restore" or "offload"
+	Path string // Path being deleted, or original path being restored
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
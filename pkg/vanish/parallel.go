@@ -0,0 +1,115 @@
+package vanish
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// runParallel calls do for the items 0 to n-1, in that order, on up to
+// jobs goroutines, then report with the outcome of each item that
+// succeeded or failed, one call at a time. Items with overlapping paths
+// never run at the same time. A failure does not stop the other items
+// unless failFast is set: then no new item starts and the context of the
+// items in flight is cancelled. Items cut short by cancellation roll back
+// and are not reported, like the items that never started; those failing
+// for another reason meanwhile still are. It returns the failures joined
+// in item order, followed by ctx.Err() when the caller cancelled.
+func runParallel(ctx context.Context, jobs int, paths []string, failFast bool,
+	do func(ctx context.Context, i int) error, report func(i int, err error)) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		next atomic.Int64
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+	)
+	failures := make([]error, len(paths))
+	locks := newPathLocks()
+	for range max(1, min(jobs, len(paths))) {
+		wg.Go(func() {
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(paths) || ctx.Err() != nil {
+					return
+				}
+				locks.lock(paths[i])
+				err := do(ctx, i)
+				locks.unlock(paths[i])
+				if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+					continue // Rolled back, not a failure of its own
+				}
+
+				mu.Lock()
+				failures[i] = err
+				report(i, err)
+				if err != nil && failFast {
+					cancel()
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	return errors.Join(append(failures, parent.Err())...)
+}
+
+// pathLocks keeps items at the same path, or at a path inside another,
+// from being processed at the same time, so that parallel runs end the
+// same way as sequential ones.
+type pathLocks struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	busy map[string]bool
+}
+
+func newPathLocks() *pathLocks {
+	l := &pathLocks{busy: make(map[string]bool)}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// lock waits until no path overlapping path is busy, then marks it busy.
+func (l *pathLocks) lock(path string) {
+	path = cleanPath(path)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.overlaps(path) {
+		l.cond.Wait()
+	}
+	l.busy[path] = true
+}
+
+func (l *pathLocks) unlock(path string) {
+	l.mu.Lock()
+	delete(l.busy, cleanPath(path))
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+func (l *pathLocks) overlaps(path string) bool {
+	for busy := range l.busy {
+		if busy == path || within(path, busy) || within(busy, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether path is inside dir.
+func within(path, dir string) bool {
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+func cleanPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
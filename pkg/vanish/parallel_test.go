@@ -0,0 +1,149 @@
+package vanish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunParallelReportsEveryItem(t *testing.T) {
+	paths := []string{"/a", "/b", "/c", "/d"}
+	errB := errors.New("b failed")
+
+	var mu sync.Mutex
+	reported := make(map[int]error)
+	err := runParallel(context.Background(), 2, paths, false, func(ctx context.Context, i int) error {
+		if i == 1 {
+			return errB
+		}
+		return nil
+	}, func(i int, err error) {
+		mu.Lock()
+		reported[i] = err
+		mu.Unlock()
+	})
+
+	if !errors.Is(err, errB) {
+		t.Errorf("runParallel = %v, want %v", err, errB)
+	}
+	if len(reported) != len(paths) {
+		t.Errorf("reported %d items, want %d", len(reported), len(paths))
+	}
+	if reported[1] != errB {
+		t.Errorf("item 1 reported %v, want %v", reported[1], errB)
+	}
+}
+
+func TestRunParallelFailFast(t *testing.T) {
+	errFirst := errors.New("first failed")
+	errSecond := errors.New("second failed while cancelled")
+
+	tests := []struct {
+		name string
+		// second is what the item in flight returns once cancelled
+		second   func(ctx context.Context) error
+		want     []error
+		reported []int
+	}{
+		{
+			name:     "rolled back",
+			second:   func(ctx context.Context) error { return fmt.Errorf("copy stopped: %w", ctx.Err()) },
+			want:     []error{errFirst},
+			reported: []int{0},
+		},
+		{
+			name:     "failed on its own",
+			second:   func(ctx context.Context) error { return errSecond },
+			want:     []error{errFirst, errSecond},
+			reported: []int{0, 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			paths := []string{"/a", "/b", "/c"}
+			started := make(chan struct{})
+			var reported []int
+			err := runParallel(context.Background(), 2, paths, true, func(ctx context.Context, i int) error {
+				switch i {
+				case 0:
+					<-started
+					return errFirst
+				case 1:
+					close(started)
+					<-ctx.Done()
+					return test.second(ctx)
+				}
+				t.Errorf("item %d started after the failure", i)
+				return nil
+			}, func(i int, err error) {
+				reported = append(reported, i)
+			})
+
+			for _, want := range test.want {
+				if !errors.Is(err, want) {
+					t.Errorf("runParallel = %v, want it to include %v", err, want)
+				}
+			}
+			if errors.Is(err, context.Canceled) {
+				t.Errorf("runParallel = %v, the rolled back item must not be a failure", err)
+			}
+			slices.Sort(reported)
+			if !slices.Equal(reported, test.reported) {
+				t.Errorf("reported items %v, want %v", reported, test.reported)
+			}
+		})
+	}
+}
+
+func TestRunParallelCallerCancels(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	paths := []string{"/a", "/b", "/c"}
+	var reported int
+	err := runParallel(ctx, 1, paths, false, func(ctx context.Context, i int) error {
+		if i == 0 {
+			cancel()
+			return ctx.Err()
+		}
+		t.Errorf("item %d started after the caller cancelled", i)
+		return nil
+	}, func(i int, err error) {
+		reported++
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("runParallel = %v, want %v", err, context.Canceled)
+	}
+	if reported != 0 {
+		t.Errorf("reported %d items, want none", reported)
+	}
+}
+
+func TestRunParallelOverlappingPaths(t *testing.T) {
+	paths := []string{"/tmp/x", "/tmp/x/y", "/tmp/x", "/tmp/z"}
+	var mu sync.Mutex
+	running := make(map[string]bool)
+	err := runParallel(context.Background(), len(paths), paths, false, func(ctx context.Context, i int) error {
+		mu.Lock()
+		for path := range running {
+			if path == paths[i] || within(path, paths[i]) || within(paths[i], path) {
+				t.Errorf("%s runs with %s", paths[i], path)
+			}
+		}
+		running[paths[i]] = true
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		delete(running, paths[i])
+		mu.Unlock()
+		return nil
+	}, func(i int, err error) {})
+	if err != nil {
+		t.Errorf("runParallel = %v", err)
+	}
+}
@@ -0,0 +1,445 @@
+// Package vanish lets Go programs use the vanish cache directly: delete to
+// it, restore from it, list it and purge it, with the same retention,
+// safety and logging rules as the vx command. The vx TUI, CLI and socket
+// API are all built on it.
+package vanish
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"vanish/internal/config"
+	"vanish/internal/helpers"
+	"vanish/internal/types"
+)
+
+// Config is the vanish configuration, as read from vanish.toml.
+type Config = types.Config
+
+// Item is an entry of the cache index.
+type Item = types.DeletedItem
+
+// PathInfo is what is known about a path before it is deleted: its size,
+// file count and safety status.
+type PathInfo = types.FileInfo
+
+// Progress describes one finished step of a Delete or Restore.
+type Progress struct {
+	Done  int    // Items processed so far, including this one
+	Total int    // Items in the whole operation
+	Path  string // Path deleted, or original path restored
+	Item  Item   // Resulting index entry, or the item that failed to restore
+	Err   error  // Why the item failed, a *PathError; nil when it succeeded
+}
+
+// ProgressFunc is called after each item of a Delete or Restore, whether
+// it succeeded or failed. Calls are never concurrent, but items finish in
+// no particular order.
+type ProgressFunc func(Progress)
+
+// BytesFunc is called with the size of each chunk of payload a Delete or
+// Restore copies, concurrently from every item in flight. Items that are
+// renamed rather than copied count their whole size at once.
+type BytesFunc func(n int64)
+
+// DeleteOptions tunes Delete.
+type DeleteOptions struct {
+	// Permanent deletes the items without caching them. They cannot be
+	// restored. Items matching cache.permanent or a skip_cache rule are
+	// always deleted permanently.
+	Permanent bool
+	// ForceProtected allows deleting protected paths such as $HOME. They
+	// also need Confirmed.
+	ForceProtected bool
+	// Confirmed tells that a person explicitly confirmed this deletion, as
+	// with the phrase vx asks to type. Without it, deleting protected paths
+	// or more than safety.max_items, safety.max_bytes or, in a directory,
+	// safety.max_files_in_dir is refused.
+	Confirmed bool
+	// Jobs is the number of items deleted at once, cache.jobs when zero.
+	Jobs int
+	// FailFast stops at the first failure instead of deleting the other
+	// items.
+	FailFast bool
+	// Progress, when set, is called after each item.
+	Progress ProgressFunc
+	// Bytes, when set, is called as payload bytes are copied.
+	Bytes BytesFunc
+	// Inspected, when it holds one entry per path in the same order, is
+	// what the caller already found out about the paths. The safety
+	// limits and the free space check use it instead of walking the
+	// paths again.
+	Inspected []PathInfo
+}
+
+// RestoreOptions tunes Restore.
+type RestoreOptions struct {
+	// ByID selects the items whose ID is exactly one of the selector's,
+	// without matching paths. Programs restoring items they listed should
+	// set it, so that an ID never selects an item whose path contains it.
+	ByID bool
+	// Into, when set, restores the items into this directory, under their
+	// base names, instead of at their original paths.
+	Into string
+	// Jobs is the number of items restored at once, cache.jobs when zero.
+	Jobs int
+	// FailFast stops at the first failure instead of restoring the other
+	// items.
+	FailFast bool
+	// Progress, when set, is called after each item.
+	Progress ProgressFunc
+	// Bytes, when set, is called as payload bytes are copied.
+	Bytes BytesFunc
+}
+
+// Filter selects items for List. The zero Filter selects every item.
+type Filter struct {
+	Patterns []string // Item IDs or case-insensitive path substrings, any may match
+	Expired  bool     // Only items past their expiry date
+	Pinned   bool     // Only pinned items
+}
+
+// PurgePolicy selects what Purge removes. Pinned items are never removed.
+type PurgePolicy struct {
+	Offload       bool      // Upload items due for the remote tier first, a no-op when it is disabled
+	Expired       bool      // Items past their expiry date
+	DeletedBefore time.Time // Items deleted before this time, zero disables
+	EnforceQuota  bool      // Evict items until the cache fits in its quota
+	Keep          []string  // IDs that quota eviction must not select
+	IDs           []string  // Items with these IDs, whatever their age
+}
+
+// PurgeResult lists what Purge removed, by reason, and what it offloaded.
+type PurgeResult struct {
+	Offloaded  []Item // Uploaded to the remote tier, with their new CachePath
+	Expired    []Item // Past their expiry date
+	Old        []Item // Deleted before PurgePolicy.DeletedBefore
+	Evicted    []Item // Evicted to enforce the quota
+	Selected   []Item // Chosen by PurgePolicy.IDs
+	OffloadErr error  // First upload failure; those items stay in the local cache
+}
+
+// Trash is a handle on the vanish cache. Its methods are safe for
+// concurrent use; operations that rewrite the index are serialised.
+type Trash struct {
+	config Config
+	mu     sync.Mutex
+}
+
+// New returns a Trash using the given configuration.
+func New(config Config) *Trash {
+	return &Trash{config: config}
+}
+
+// Open returns a Trash using the user's vanish.toml, creating it with
+// defaults if it does not exist.
+func Open() (*Trash, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg), nil
+}
+
+// Config returns the configuration the Trash was created with.
+func (t *Trash) Config() Config {
+	return t.config
+}
+
+// FlushMetrics rewrites the metrics textfile set by metrics.textfile if
+// the cache changed since it was last written. Operations only mark it
+// stale, so call it once a batch of them is done.
+func (t *Trash) FlushMetrics() {
+	helpers.FlushMetrics(t.config)
+}
+
+// Delete moves each path to the cache, applying retention rules, and
+// returns the resulting items in the order of paths. Items deleted
+// permanently have an empty CachePath.
+//
+// The paths are first checked together, as vx does before asking: if they
+// need a typed confirmation and opts.Confirmed is not set, Delete returns
+// an error wrapping ErrNeedsConfirmation, and if what must be copied does
+// not fit in the cache filesystem, one wrapping ErrNoSpace. Nothing is
+// deleted then.
+//
+// Up to opts.Jobs items are moved at once. A path that fails does not stop
+// the others unless opts.FailFast is set; the error joins the *PathError
+// of every failure, followed by ctx.Err() when ctx was cancelled. Items
+// being moved when the run stops are rolled back, so that every path is
+// left either fully in the cache or untouched.
+func (t *Trash) Delete(ctx context.Context, paths []string, opts DeleteOptions) ([]Item, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkDelete(paths, opts); err != nil {
+		return nil, err
+	}
+
+	results := make([]*Item, len(paths))
+	done := 0
+	err := runParallel(ctx, t.jobs(opts.Jobs), paths, opts.FailFast, func(ctx context.Context, i int) error {
+		item, err := t.deleteOne(ctx, paths[i], opts)
+		if err == nil {
+			results[i] = &item
+		}
+		return err
+	}, func(i int, err error) {
+		done++
+		if opts.Progress != nil {
+			progress := Progress{Done: done, Total: len(paths), Path: paths[i], Err: err}
+			if results[i] != nil {
+				progress.Item = *results[i]
+			}
+			opts.Progress(progress)
+		}
+	})
+	return collect(results), err
+}
+
+// checkDelete applies the safety limits and the free space check to the
+// paths of a Delete as a whole. Paths that deleteOne refuses anyway are
+// left out.
+func (t *Trash) checkDelete(paths []string, opts DeleteOptions) error {
+	var infos []PathInfo
+	if len(opts.Inspected) == len(paths) {
+		infos = slices.Clone(opts.Inspected)
+	} else {
+		infos = helpers.InspectPaths(paths, t.config)
+	}
+	for i, info := range infos {
+		if info.Protected && !opts.ForceProtected {
+			infos[i].Exists = false
+		}
+		if opts.Permanent {
+			infos[i].Permanent = true
+		}
+	}
+
+	if reasons := helpers.ConfirmationReasons(infos, t.config); len(reasons) > 0 && !opts.Confirmed {
+		return fmt.Errorf("%w: %s", ErrNeedsConfirmation, strings.Join(reasons, "; "))
+	}
+	return helpers.CheckFreeSpace(infos, t.config)
+}
+
+// deleteOne checks and deletes a single path for Delete.
+func (t *Trash) deleteOne(ctx context.Context, path string, opts DeleteOptions) (Item, error) {
+	if _, err := os.Lstat(path); err != nil {
+		return Item{}, &PathError{Op: "delete", Path: path, Err: ErrNotFound}
+	}
+	refused, protected, reason := helpers.CheckPathSafety(path, t.config)
+	if refused {
+		return Item{}, &PathError{Op: "delete", Path: path, Err: fmt.Errorf("%w: %s", ErrRefused, reason)}
+	}
+	if protected && !opts.ForceProtected {
+		return Item{}, &PathError{Op: "delete", Path: path, Err: fmt.Errorf("%w: %s", ErrProtected, reason)}
+	}
+
+	permanent := opts.Permanent || helpers.IsPermanentPath(path, t.config)
+	report := helpers.NewCopyReport(ctx, opts.Bytes)
+	item, _, err := helpers.DeleteItem(path, permanent, report, t.config)
+	if err != nil {
+		return Item{}, &PathError{Op: "delete", Path: path, Err: err}
+	}
+	return item, nil
+}
+
+// Restore moves every cached item matching the selector (item IDs or
+// case-insensitive path substrings, or only IDs with opts.ByID) back to
+// its original location, and returns the restored items in index order.
+// Up to opts.Jobs items are moved at once. An item that fails does not
+// stop the others unless opts.FailFast is set; the error joins the
+// *PathError of every failure, followed by ctx.Err() when ctx was
+// cancelled. Items being moved when the run stops are rolled back, so that
+// every item is left either fully restored or in the cache.
+func (t *Trash) Restore(ctx context.Context, selector []string, opts RestoreOptions) ([]Item, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	index, err := helpers.LoadIndex(t.config)
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	if opts.ByID {
+		items = helpers.FindItemsByID(index, selector)
+	} else {
+		items = helpers.FindMatchingItems(index, selector)
+	}
+	if len(items) == 0 {
+		return nil, &PathError{Op: "restore", Path: fmt.Sprint(selector), Err: ErrNotFound}
+	}
+
+	if opts.Into != "" {
+		into, err := filepath.Abs(opts.Into)
+		if err != nil {
+			return nil, err
+		}
+		for i := range items {
+			items[i].OriginalPath = filepath.Join(into, filepath.Base(items[i].OriginalPath))
+		}
+	}
+
+	paths := make([]string, len(items))
+	for i, item := range items {
+		paths[i] = item.OriginalPath
+	}
+	results := make([]*Item, len(items))
+	done := 0
+	err = runParallel(ctx, t.jobs(opts.Jobs), paths, opts.FailFast, func(ctx context.Context, i int) error {
+		err := t.restoreOne(ctx, items[i], opts)
+		if err == nil {
+			results[i] = &items[i]
+		}
+		return err
+	}, func(i int, err error) {
+		done++
+		if opts.Progress != nil {
+			opts.Progress(Progress{Done: done, Total: len(items), Path: items[i].OriginalPath, Item: items[i], Err: err})
+		}
+	})
+	return collect(results), err
+}
+
+// restoreOne checks and restores a single item for Restore.
+func (t *Trash) restoreOne(ctx context.Context, item Item, opts RestoreOptions) error {
+	if !helpers.PayloadExists(item, t.config) {
+		return &PathError{Op: "restore", Path: item.OriginalPath, Err: ErrMissingPayload}
+	}
+	if _, err := os.Lstat(item.OriginalPath); !os.IsNotExist(err) {
+		return &PathError{Op: "restore", Path: item.OriginalPath, Err: ErrExists}
+	}
+	report := helpers.NewCopyReport(ctx, opts.Bytes)
+	if err := helpers.RestoreItem(item, report, t.config); err != nil {
+		return &PathError{Op: "restore", Path: item.OriginalPath, Err: err}
+	}
+	return nil
+}
+
+// jobs returns the number of items to process at once.
+func (t *Trash) jobs(jobs int) int {
+	if jobs > 0 {
+		return jobs
+	}
+	if t.config.Cache.Jobs > 0 {
+		return t.config.Cache.Jobs
+	}
+	return helpers.DefaultJobs
+}
+
+// collect returns the items that completed, in their original order.
+func collect(results []*Item) []Item {
+	var items []Item
+	for _, item := range results {
+		if item != nil {
+			items = append(items, *item)
+		}
+	}
+	return items
+}
+
+// Offload uploads cached items to the remote tier and removes their local
+// copies. With no selector, the items due by remote.after_days or
+// remote.min_size are offloaded; otherwise every local item matching the
+// selector is, pinned or not. It returns the offloaded items and the first
+// upload failure, or ErrRemoteDisabled.
+func (t *Trash) Offload(ctx context.Context, selector []string) ([]Item, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.config.Remote.Enabled {
+		return nil, ErrRemoteDisabled
+	}
+	index, err := helpers.LoadIndex(t.config)
+	if err != nil {
+		return nil, err
+	}
+
+	items := helpers.SelectOffloads(index.Items, t.config)
+	if len(selector) > 0 {
+		items = helpers.FindMatchingItems(index, selector)
+		if len(items) == 0 {
+			return nil, &PathError{Op: "offload", Path: fmt.Sprint(selector), Err: ErrNotFound}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return helpers.OffloadItems(items, t.config)
+}
+
+// List returns the cached items selected by filter, in index order.
+func (t *Trash) List(filter Filter) ([]Item, error) {
+	index, err := helpers.LoadIndex(t.config)
+	if err != nil {
+		return nil, err
+	}
+
+	items := index.Items
+	if len(filter.Patterns) > 0 {
+		items = helpers.FindMatchingItems(index, filter.Patterns)
+	}
+
+	var selected []Item
+	for _, item := range items {
+		if filter.Expired && !helpers.IsExpired(item, t.config) {
+			continue
+		}
+		if filter.Pinned && !item.IsPinned() {
+			continue
+		}
+		selected = append(selected, item)
+	}
+	return selected, nil
+}
+
+// Purge removes the items selected by policy from the cache and the index.
+// Items due for the remote tier are offloaded first, then expired items
+// are removed, then the ones chosen by ID, then old ones, then the quota
+// is enforced on what is left.
+// A failed upload does not stop the purge, it is reported in OffloadErr.
+func (t *Trash) Purge(policy PurgePolicy) (PurgeResult, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var result PurgeResult
+	var err error
+	if policy.Offload && t.config.Remote.Enabled {
+		index, err := helpers.LoadIndex(t.config)
+		if err != nil {
+			return result, err
+		}
+		result.Offloaded, result.OffloadErr = helpers.OffloadItems(helpers.SelectOffloads(index.Items, t.config), t.config)
+	}
+	if policy.Expired {
+		if result.Expired, err = helpers.PurgeExpired(t.config); err != nil {
+			return result, err
+		}
+	}
+	if len(policy.IDs) > 0 {
+		if result.Selected, err = helpers.PurgeItems(policy.IDs, t.config); err != nil {
+			return result, err
+		}
+	}
+	if !policy.DeletedBefore.IsZero() {
+		if result.Old, err = helpers.PurgeDeletedBefore(policy.DeletedBefore, t.config); err != nil {
+			return result, err
+		}
+	}
+	if policy.EnforceQuota {
+		keep := make(map[string]bool, len(policy.Keep))
+		for _, id := range policy.Keep {
+			keep[id] = true
+		}
+		if result.Evicted, err = helpers.EnforceQuota(t.config, keep); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}